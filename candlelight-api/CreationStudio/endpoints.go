@@ -310,11 +310,10 @@ func GenerateJSON(w http.ResponseWriter, r *http.Request) {
 			EnforceTurnOrder:       true,
 		},
 		Sparks: Sparks.Sparks{
-			Dealer: Sparks.Dealer{
-				Enabled:   true,
+			Sparks.NewSpark(Sparks.SparkType_Dealer, Sparks.Dealer{
 				NumToDeal: 5,
 				DeckToUse: deckId,
-			},
+			}),
 		},
 		MaxPlayers: 4,
 		Views: []Game.View{
@@ -1,23 +1,40 @@
 package Lobby
 
-import "candlelight-models/Session"
+import (
+	"candlelight-models/Session"
+	"encoding/json"
+	"net/http"
+	"time"
+)
 
 // The different types of messages the server might send to a client connected via websocket.
 const (
-	WebsocketMessage_Changelog = "Changelog"
-	WebsocketMessage_Close     = "Close"
-	WebsocketMessage_Error     = "Error"
-	WebsocketMessage_GameOver  = "GameOver"
-	WebsocketMessage_GameState = "GameState"
-	WebsocketMessage_LobbyInfo = "LobbyInfo"
+	WebsocketMessage_ActionAccepted = "ActionAccepted"
+	WebsocketMessage_Changelog      = "Changelog"
+	WebsocketMessage_ChatHistory    = "ChatHistory"
+	WebsocketMessage_ChatMessage    = "ChatMessage"
+	WebsocketMessage_Close          = "Close"
+	WebsocketMessage_Error          = "Error"
+	WebsocketMessage_GameOver       = "GameOver"
+	WebsocketMessage_GameState      = "GameState"
+	WebsocketMessage_LegalActions   = "LegalActions"
+	WebsocketMessage_LobbyInfo      = "LobbyInfo"
+	WebsocketMessage_PlayerPresence = "PlayerPresence"
+	WebsocketMessage_RoundProgress  = "RoundProgress"
+	WebsocketMessage_TurnWarning    = "TurnWarning"
 )
 
 // A message sent from the server to a client. The frontend can check [Type] to determine how to parse the object in [Data]
 type WebsocketMessage struct {
 	//One of the above constants. That constant will tell you which of the below structs is found in the [Data] field
 	Type string `json:"type"`
-	//One of the below structs, a Changelog, or a GameState. Its exact type is recorded in [Type]
+	//One of the below structs, a Changelog, a ChatMessage, or a GameState. Its exact type is recorded in [Type]
 	Data any `json:"data"`
+	//This message's place in the room's sequence. Every message sent to the whole room is numbered, one higher than the last.
+	//Messages sent to a single player (i.e. Errors) aren't part of the sequence and leave this empty
+	Seq int64 `json:"seq,omitempty"`
+	//The requestId of the client message this is a direct reply to, if that message had one. Only set on messages sent to a single player
+	RequestId string `json:"requestId,omitempty"`
 }
 
 // A message containing a Player's assigned ID and the details of the lobby after they've joined it, whether by hosting it or joining a pre-existing lobby.
@@ -27,11 +44,15 @@ type LobbyInfo struct {
 	LobbyInfo Session.Lobby `json:"lobbyInfo"`
 }
 
-// I know SocketError and SocketClose have the same exact structure, but I've separated them for both clarity in the code and in case we end up wanting to put additional (unique) data in one or both
-
-// If some message from a client causes any error, one of these is sent back to the client
+// If some message from a client causes any error, one of these is sent back to the client. The same structure is used as the body of any
+// error response from the Lobby's HTTP endpoints
 type SocketError struct {
+	//One of the Session.ErrorCode constants. Stable, so the frontend can react to (or localize) the error without parsing [Message]
+	Code string `json:"code"`
+	//A description of the error, suitable for displaying directly to the player
 	Message string `json:"message"`
+	//Any Ids or values relevant to the error, i.e. the Id of the card that couldn't be found
+	Details map[string]string `json:"details,omitempty"`
 }
 
 // If a connection is about to be closed by the server, it will send a SocketClose, followed by immediately closing the connection
@@ -41,3 +62,52 @@ type SocketClose struct {
 
 type GameOver struct {
 }
+
+// Sent only to the player who submitted an action, once it has been applied. Everyone (including that player) also receives the resulting Changelog
+type ActionAccepted struct {
+	//The sequence number of the Changelog broadcast for this action. 0 for a commitment that didn't complete its round, since nothing is broadcast yet
+	ChangelogSeq int64 `json:"changelogSeq"`
+}
+
+// Sent only to the player who asked for it, listing every action they could submit right now. See Engine.LegalActions
+type LegalActions struct {
+	Actions []Session.SubmittedAction `json:"actions"`
+}
+
+// Sent only to the player whose turn it is, shortly before they run out of time. See GameRules.TurnTimeLimit
+type TurnWarning struct {
+	//When their turn will be ended for them
+	Deadline time.Time `json:"deadline"`
+	//How many seconds they had left when the warning was sent, rounded up
+	SecondsLeft int `json:"secondsLeft"`
+}
+
+// Sent to a player who has just rejoined a Room, containing the most recent chat messages they're allowed to see, oldest first.
+// This replaces any chat the client already has
+type ChatHistory struct {
+	Messages []Session.ChatMessage `json:"messages"`
+}
+
+// Sent to everyone in a Room when a player's connection is lost (or they disconnect), and again when they reconnect
+type PlayerPresence struct {
+	PlayerId  string `json:"playerId"`
+	Connected bool   `json:"connected"`
+}
+
+// Converts [err] into a SocketError. Any error that isn't a Session.GameError is an internal one not meant for players, so it's sent as a generic Internal error instead
+func newSocketError(err error) SocketError {
+	gameError := Session.AsGameError(err)
+	return SocketError{
+		Code:    gameError.Code,
+		Message: gameError.Message,
+		Details: gameError.Details,
+	}
+}
+
+// Responds to an HTTP request with [err] as a JSON SocketError and the given [status]
+func httpError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(newSocketError(err))
+}
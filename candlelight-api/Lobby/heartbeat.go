@@ -0,0 +1,60 @@
+package Lobby
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// How long the server waits between heartbeats, and how long it's willing to wait to hear back from a client.
+// Every client is sent a ping every [PingInterval]. If nothing (a pong or any other message) is heard from the client within [PongWait],
+// its connection is considered dead and is closed, freeing the player up to rejoin
+type heartbeatConfig struct {
+	//How often to ping each client. Must be shorter than PongWait
+	PingInterval time.Duration
+	//How long a connection may go without the server hearing anything from it
+	PongWait time.Duration
+	//How long a single write to a client may take before the connection is considered dead
+	WriteWait time.Duration
+}
+
+// The heartbeat settings used for every connection. Loaded from the environment on startup
+var heartbeat = loadHeartbeatConfig()
+
+// How long a host may stay disconnected before the lobby is handed to another player. Set with CANDLELIGHT_HOST_GRACE_PERIOD (default 60s)
+var hostGracePeriod = getDurationFromEnv("CANDLELIGHT_HOST_GRACE_PERIOD", 60*time.Second)
+
+// Reads the heartbeat settings from the environment. Each can be set with a Go duration string (i.e. "30s"):
+//   - CANDLELIGHT_PING_INTERVAL (default 25s)
+//   - CANDLELIGHT_PONG_WAIT (default 60s)
+//   - CANDLELIGHT_WRITE_WAIT (default 10s)
+func loadHeartbeatConfig() heartbeatConfig {
+	config := heartbeatConfig{
+		PingInterval: getDurationFromEnv("CANDLELIGHT_PING_INTERVAL", 25*time.Second),
+		PongWait:     getDurationFromEnv("CANDLELIGHT_PONG_WAIT", 60*time.Second),
+		WriteWait:    getDurationFromEnv("CANDLELIGHT_WRITE_WAIT", 10*time.Second),
+	}
+
+	//If we ping less often than we expect to hear back, healthy clients would be timed out between pings
+	if config.PingInterval >= config.PongWait {
+		log.Printf("WARNING: Ping interval {%s} is not shorter than pong wait {%s}. Using a ping interval of {%s} instead", config.PingInterval, config.PongWait, config.PongWait*9/10)
+		config.PingInterval = config.PongWait * 9 / 10
+	}
+
+	return config
+}
+
+// Returns the duration stored in the environment variable [name], or [fallback] if it isn't set or isn't a valid, positive duration
+func getDurationFromEnv(name string, fallback time.Duration) time.Duration {
+	environ := os.Getenv(name)
+	if environ == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(environ)
+	if err != nil || duration <= 0 {
+		log.Printf("WARNING: Could not use {%s} for %s. Using default of {%s}", environ, name, fallback)
+		return fallback
+	}
+	return duration
+}
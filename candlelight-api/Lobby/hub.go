@@ -0,0 +1,581 @@
+package Lobby
+
+import (
+	"candlelight-ruleengine/Engine"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// How many outbound messages can be waiting to be written to a single client. If a client falls this far behind,
+// it's considered too slow and disconnected so that it can't hold up the rest of its Room
+const clientSendBufferSize = 256
+
+// How many times to try handing a new connection to a Room before giving up. A Room can stop (because its last player on this server left)
+// just as someone else connects, in which case a fresh Room is started and we try again
+const maxConnectAttempts = 3
+
+// Tracks every Room with a player connected to this server. Every server running the API has its own Hub, and Hubs on different servers
+// coordinate through Redis: everything sent to a room is published to it, and every Hub with players in that room delivers it to its own players.
+// This means any server can accept joins, actions and rejoins for any Lobby, no matter which server it was hosted on
+type Hub struct {
+	//Every Room with a player connected to this server, keyed by room code
+	rooms map[string]*Room
+	//Mutex to access rooms in a thread-safe manner
+	roomsMutex sync.Mutex
+}
+
+// Creates an empty Hub. Most code should use the package-level handlers, which share a single Hub
+func NewHub() *Hub {
+	return &Hub{
+		rooms: make(map[string]*Room),
+	}
+}
+
+// The Hub used by the package-level handlers
+var defaultHub = NewHub()
+
+// What's actually published to a room. Every server with players in the room receives it and acts on it for its own players
+type roomEnvelope struct {
+	//If set, only this player's connection is affected. Otherwise, every connection in the room is
+	Target string `json:"target,omitempty"`
+	//The message to deliver. May be empty if the envelope only closes connections
+	Message *WebsocketMessage `json:"message,omitempty"`
+	//If set, spectators are sent this instead of [Message], i.e. a GameState without anyone's hand in it
+	SpectatorMessage *WebsocketMessage `json:"spectatorMessage,omitempty"`
+	//If set, the affected connections are sent a Close message with this reason (after [Message]), then closed
+	CloseReason string `json:"closeReason,omitempty"`
+	//If set, the Room stops once the envelope has been handled, i.e. because the game has ended
+	EndRoom bool `json:"endRoom,omitempty"`
+	//If set, the affected connections stop waiting for a seat and are treated as players from then on, i.e. because the host let them take over a seat
+	Seated bool `json:"seated,omitempty"`
+}
+
+// A single websocket connection belonging to a player in a Room. Each Client has exactly one goroutine reading from
+// [conn] (readPump) and exactly one writing to it (writePump), which is all gorilla/websocket allows
+type Client struct {
+	room     *Room
+	playerId string
+	//Whether this connection belongs to a spectator rather than a player. Spectators are sent the spectator version of anything that has one,
+	//and can't do anything but disconnect
+	spectator bool
+	//Whether this connection belongs to someone waiting for the host to let them take over a seat. Until then, they're treated as a spectator
+	claimingSeat bool
+	conn         *websocket.Conn
+	//Outbound messages waiting to be written to [conn]. Only the Room's event loop may send on or close this channel
+	send chan WebsocketMessage
+	//The heartbeat settings in effect when this connection was opened
+	heartbeat heartbeatConfig
+	//Identifies this particular connection in Redis, so that it isn't confused with another connection for the same player
+	connectionId string
+	//Sequence number of the latest numbered message queued for this client. Used to avoid sending the same message twice
+	lastSeq int64
+}
+
+// A Client waiting to be tracked by its Room, along with anything that should happen right before it is
+type registration struct {
+	client *Client
+	//Run on the event loop right before [client] starts being tracked, so nothing sent to the Room can slip in between. May be nil
+	welcome func(client *Client)
+}
+
+// A message read from a Client, waiting to be processed by its Room's event loop
+type clientMessage struct {
+	client  *Client
+	message []byte
+}
+
+// Every connection on this server for a single Lobby. A Room's state is owned entirely by its event loop (see loop), so nothing outside
+// of that goroutine should ever touch [clients] directly. Instead, hand work to the loop through the Room's channels
+type Room struct {
+	hub      *Hub
+	roomCode string
+	//Connected clients, keyed by playerId
+	clients    map[string]*Client
+	register   chan registration
+	unregister chan *Client
+	inbound    chan clientMessage
+	//Everything published to this room by any server, including this one
+	subscription *Engine.RoomSubscription
+	//Closed once the event loop has stopped, so that anything waiting on the Room knows to give up
+	done chan struct{}
+	//Set by the event loop when the Room should stop after finishing whatever it's currently doing
+	closing bool
+	//Whether anyone has connected to this Room yet. Once they have, the Room stops as soon as nobody on this server is connected anymore
+	started bool
+	//How long the host may stay disconnected before someone else is made host, as of when the Room was started
+	hostGracePeriod time.Duration
+	//Turn timers that have gone off, so the turn can be ended in between messages from players rather than alongside them
+	turnAlarms chan turnAlarm
+	//The deadline [turnTimers] are set for, if any. See scheduleTurnTimer
+	turnDeadline time.Time
+	//The timers for the warning and end of the turn due at [turnDeadline]
+	turnTimers []*time.Timer
+}
+
+// Returns this server's Room for [roomCode], creating it (subscribing to the room and starting its event loop) if there isn't one yet
+func (hub *Hub) getOrCreateRoom(roomCode string) (*Room, error) {
+	hub.roomsMutex.Lock()
+	defer hub.roomsMutex.Unlock()
+
+	if room, exists := hub.rooms[roomCode]; exists {
+		return room, nil
+	}
+
+	subscription, err := Engine.SubscribeToRoom(roomCode)
+	if err != nil {
+		return nil, err
+	}
+
+	room := &Room{
+		hub:          hub,
+		roomCode:     roomCode,
+		clients:      make(map[string]*Client),
+		register:     make(chan registration),
+		unregister:   make(chan *Client),
+		inbound:      make(chan clientMessage),
+		subscription: subscription,
+		done:         make(chan struct{}),
+		turnAlarms:   make(chan turnAlarm),
+
+		hostGracePeriod: hostGracePeriod,
+	}
+	hub.rooms[roomCode] = room
+	go room.loop()
+
+	return room, nil
+}
+
+// Hands [conn], belonging to [playerId], to this server's Room for [roomCode] and starts its read/write pumps. [spectator] marks connections that may
+// only watch the game. [welcome] (which may be nil) is run on the Room's event loop just before the connection starts being tracked. Returns false
+// (and closes [conn]) if the connection couldn't be handed off
+func (hub *Hub) connect(roomCode string, playerId string, spectator bool, conn *websocket.Conn, welcome func(client *Client)) bool {
+	for attempt := 0; attempt < maxConnectAttempts; attempt++ {
+		room, err := hub.getOrCreateRoom(roomCode)
+		if err != nil {
+			log.Printf("Error starting room {%s} for player {%s}: %s", roomCode, playerId, err)
+			break
+		}
+
+		if room.addClient(newClient(room, playerId, spectator, conn), welcome) {
+			return true
+		}
+	}
+
+	conn.Close()
+	return false
+}
+
+// The Room's event loop. Every change to the Room's clients, every message from them and every message published to the room is handled here, one at a time
+func (room *Room) loop() {
+	defer close(room.done)
+
+	for !room.closing {
+		select {
+		case registration := <-room.register:
+			room.registerClient(registration)
+		case client := <-room.unregister:
+			//Only drop the client if it's still the player's current connection. It may have already been replaced or closed
+			if room.clients[client.playerId] == client {
+				log.Printf("Connection for player {%s} in room {%s} has ended", client.playerId, room.roomCode)
+				room.dropClient(client)
+				room.lostConnection(client)
+			}
+		case inbound := <-room.inbound:
+			//Ignore anything from a connection that's no longer being tracked
+			if room.clients[inbound.client.playerId] != inbound.client {
+				continue
+			}
+			room.processMessage(inbound.client, inbound.message)
+		case published, ok := <-room.subscription.Messages:
+			if !ok {
+				log.Printf("Lost subscription to room {%s}. Closing its connections so they can reconnect", room.roomCode)
+				room.close()
+				continue
+			}
+			room.deliver(published)
+		case alarm := <-room.turnAlarms:
+			if next := alarm.sound(room.roomCode); next != nil {
+				room.scheduleTurnTimer(*next)
+			}
+		}
+
+		//Nobody on this server is in the room anymore, so stop listening to it. If someone connects again, a new Room is started
+		if room.started && len(room.clients) == 0 {
+			room.close()
+		}
+	}
+
+	for _, client := range room.clients {
+		room.dropClient(client)
+	}
+
+	room.hub.roomsMutex.Lock()
+	if room.hub.rooms[room.roomCode] == room {
+		delete(room.hub.rooms, room.roomCode)
+	}
+	room.hub.roomsMutex.Unlock()
+
+	room.subscription.Close()
+}
+
+// Starts tracking [client] in the Room and starts its read/write pumps. If given, [welcome] is run on the event loop just before [client] is tracked,
+// i.e. to queue up their first messages without missing anything sent to the Room meanwhile. Returns false if the Room has already stopped
+func (room *Room) addClient(client *Client, welcome func(client *Client)) bool {
+	select {
+	case room.register <- registration{client: client, welcome: welcome}:
+	case <-room.done:
+		return false
+	}
+
+	go client.writePump()
+	go client.readPump()
+	return true
+}
+
+// ===================== Everything below here must only be called from the event loop =====================
+
+// Starts tracking the registering client, letting everyone know if they've just come back
+func (room *Room) registerClient(registration registration) {
+	client := registration.client
+	if registration.welcome != nil {
+		registration.welcome(client)
+	}
+
+	//A player only ever has one connection. If they somehow already have one, the old one is replaced
+	if existing, exists := room.clients[client.playerId]; exists {
+		room.dropClient(existing)
+	}
+	room.clients[client.playerId] = client
+	room.started = true
+
+	if err := Engine.TrackConnection(room.roomCode, client.playerId, client.connectionId, client.heartbeat.PongWait); err != nil {
+		log.Printf("Error tracking connection for player {%s} in room {%s}: %s", client.playerId, room.roomCode, err)
+	}
+
+	if reconnected, _ := Engine.MarkPlayerConnected(room.roomCode, client.playerId); reconnected {
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_PlayerPresence, Data: PlayerPresence{PlayerId: client.playerId, Connected: true}})
+	}
+}
+
+// Acts on an envelope published to the room, for whichever of the affected players are connected to this server
+func (room *Room) deliver(published Engine.RoomMessage) {
+	envelope := roomEnvelope{}
+	if err := json.Unmarshal(published.Message, &envelope); err != nil {
+		log.Printf("Error decoding message published to room {%s}: %s", room.roomCode, err)
+		return
+	}
+
+	targets := []*Client{}
+	if envelope.Target != "" {
+		if client, exists := room.clients[envelope.Target]; exists {
+			targets = append(targets, client)
+		}
+	} else {
+		for _, client := range room.clients {
+			targets = append(targets, client)
+		}
+	}
+
+	for _, client := range targets {
+		if envelope.Seated {
+			client.spectator = false
+			client.claimingSeat = false
+		}
+
+		toSend := envelope.Message
+		if client.spectator && envelope.SpectatorMessage != nil {
+			toSend = envelope.SpectatorMessage
+		}
+		if toSend != nil {
+			message := *toSend
+			message.Seq = published.Seq
+			room.queue(client, message)
+		}
+		if envelope.CloseReason != "" {
+			room.closeClient(client, envelope.CloseReason)
+		}
+	}
+
+	if envelope.EndRoom {
+		room.close()
+	}
+}
+
+// Queues [message] to be written to [client]. If the client is too far behind to accept it, they're disconnected
+func (room *Room) queue(client *Client, message WebsocketMessage) {
+	//Anything no longer being tracked has already had its channel closed
+	if room.clients[client.playerId] != client {
+		return
+	}
+
+	//Numbered messages the client has already been given (i.e. replayed to them when they rejoined) are skipped
+	if message.Seq != 0 {
+		if message.Seq <= client.lastSeq {
+			return
+		}
+		client.lastSeq = message.Seq
+	}
+
+	select {
+	case client.send <- message:
+	default:
+		log.Printf("Player {%s} in room {%s} is not keeping up with messages. Disconnecting them", client.playerId, room.roomCode)
+		room.dropClient(client)
+		client.conn.Close()
+		room.lostConnection(client)
+	}
+}
+
+// Lets everyone know [client]'s connection is gone. Players are marked as disconnected so they can rejoin, while spectators are removed from the Lobby
+// and anyone waiting for a seat gives up on it
+func (room *Room) lostConnection(client *Client) {
+	if client.claimingSeat {
+		room.withdrawSeatRequest(client.playerId)
+		return
+	}
+	if client.spectator {
+		room.removeSpectator(client.playerId)
+		return
+	}
+	room.markDisconnected(client.playerId)
+}
+
+// Removes [spectatorId] from the Lobby and lets everyone know
+func (room *Room) removeSpectator(spectatorId string) {
+	updatedLobby, err := Engine.LeaveAsSpectator(room.roomCode, spectatorId)
+	if err != nil {
+		log.Printf("Error removing spectator {%s} from room {%s}: %s", spectatorId, room.roomCode, err)
+		return
+	}
+	room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+}
+
+// Takes back [playerId]'s request to take over a seat and lets everyone know
+func (room *Room) withdrawSeatRequest(playerId string) {
+	updatedLobby, err := Engine.WithdrawSeatRequest(room.roomCode, playerId)
+	if err != nil {
+		log.Printf("Error withdrawing {%s}'s request for a seat in room {%s}: %s", playerId, room.roomCode, err)
+		return
+	}
+	room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+}
+
+// Tells everyone still connected that [playerId] has lost their connection, unless they've already been told. If they're the host and
+// haven't come back once the grace period is up, the lobby is handed to someone else
+func (room *Room) markDisconnected(playerId string) {
+	if changed, _ := Engine.MarkPlayerDisconnected(room.roomCode, playerId); changed {
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_PlayerPresence, Data: PlayerPresence{PlayerId: playerId, Connected: false}})
+		time.AfterFunc(room.hostGracePeriod, func() { migrateHost(room.roomCode, playerId) })
+	}
+}
+
+// Forgets that [playerId] was ever disconnected, i.e. because they've been removed from the Lobby entirely
+func (room *Room) forgetPlayer(playerId string) {
+	Engine.MarkPlayerConnected(room.roomCode, playerId)
+}
+
+// Gives [message] the room's next sequence number and sends it to every player in the room, on every server. Returns the assigned sequence number
+func (room *Room) sendToAll(message WebsocketMessage) int64 {
+	return publishToRoom(room.roomCode, roomEnvelope{Message: &message}, true)
+}
+
+// Like sendToAll, but spectators are sent [spectatorMessage] instead of [message]. Both share the same sequence number
+func (room *Room) sendToAllProjected(message WebsocketMessage, spectatorMessage WebsocketMessage) int64 {
+	return publishToRoom(room.roomCode, roomEnvelope{Message: &message, SpectatorMessage: &spectatorMessage}, true)
+}
+
+// Queues [message] for the player with the given [playerId], if they're connected to this server. Meant for replies to a message that player just sent
+func (room *Room) sendTo(playerId string, message WebsocketMessage) {
+	if client, exists := room.clients[playerId]; exists {
+		room.queue(client, message)
+	}
+}
+
+// Sends [message] to the player with the given [playerId], whichever server they're connected to. Unlike sendToAll, the message isn't numbered,
+// since no one else will receive it
+func (room *Room) sendToPlayer(playerId string, message WebsocketMessage) {
+	publishToRoom(room.roomCode, roomEnvelope{Target: playerId, Message: &message}, false)
+}
+
+// Queues an Error message describing [err] for the player with the given [playerId], tagged with the [requestId] of the message that caused it (if any)
+func (room *Room) sendError(playerId string, requestId string, err error) {
+	room.sendTo(playerId, WebsocketMessage{
+		Type:      WebsocketMessage_Error,
+		Data:      newSocketError(err),
+		RequestId: requestId,
+	})
+}
+
+// Closes [playerId]'s connection with the given [reason], whichever server they're connected to
+func (room *Room) closePlayer(playerId string, reason string) {
+	publishToRoom(room.roomCode, roomEnvelope{Target: playerId, CloseReason: reason}, false)
+}
+
+// Lets [playerId]'s connection stop waiting for a seat and act as a player, whichever server they're connected to
+func (room *Room) seatPlayer(playerId string) {
+	publishToRoom(room.roomCode, roomEnvelope{Target: playerId, Seated: true}, false)
+}
+
+// Closes every connection in the room with the given [reason] and stops the room, on every server
+func (room *Room) endRoom(reason string) {
+	publishToRoom(room.roomCode, roomEnvelope{CloseReason: reason, EndRoom: true}, false)
+}
+
+// Sends [client] a Close message with the given [reason], then stops tracking them. Their connection is closed once the Close message has been written
+func (room *Room) closeClient(client *Client, reason string) {
+	room.queue(client, WebsocketMessage{
+		Type: WebsocketMessage_Close,
+		Data: SocketClose{
+			Message: reason,
+		},
+	})
+	room.dropClient(client)
+}
+
+// Stops tracking [client]. Their write pump will finish writing anything already queued, then close the connection
+func (room *Room) dropClient(client *Client) {
+	if room.clients[client.playerId] != client {
+		return
+	}
+	delete(room.clients, client.playerId)
+	close(client.send)
+
+	if err := Engine.UntrackConnection(room.roomCode, client.playerId, client.connectionId); err != nil {
+		log.Printf("Error untracking connection for player {%s} in room {%s}: %s", client.playerId, room.roomCode, err)
+	}
+}
+
+// Marks the Room to stop once the event loop finishes its current work. Any remaining clients are dropped
+func (room *Room) close() {
+	room.closing = true
+}
+
+// Makes someone else host of the room with [roomCode] if [playerId] is still its host and still disconnected, then lets everyone know. Doesn't need
+// the Room's event loop, so it works even if nobody is left in the Room on this server
+func migrateHost(roomCode string, playerId string) {
+	updatedLobby, changed, err := Engine.MigrateHostIfDisconnected(roomCode, playerId)
+	if err != nil {
+		log.Printf("Error migrating host of room {%s}: %s", roomCode, err)
+		return
+	}
+	if changed {
+		publishToRoom(roomCode, roomEnvelope{
+			Message: &WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}},
+		}, true)
+	}
+}
+
+// Publishes [envelope] to the room with [roomCode]. If [sequenced] is set, the envelope's message is numbered and kept for replaying, and its
+// sequence number is returned. Safe to call from anywhere, since it doesn't touch any Room's state
+func publishToRoom(roomCode string, envelope roomEnvelope, sequenced bool) int64 {
+	if envelope.Message != nil && envelope.Message.Type == "" {
+		log.Println("WARNING: Websocket message being sent has no Type set! Frontend will likely not know how to handle the message!")
+	}
+
+	asJson, err := json.Marshal(envelope)
+	if err != nil {
+		log.Printf("Error encoding message for room {%s}: %s", roomCode, err)
+		return 0
+	}
+
+	seq, err := Engine.PublishRoomMessage(roomCode, asJson, sequenced)
+	if err != nil {
+		log.Printf("Error publishing message to room {%s}: %s", roomCode, err)
+	}
+	return seq
+}
+
+// ===================== Client pumps =====================
+
+// Queues [message] for a client that isn't being tracked yet, i.e. from a welcome function. Once tracked, use room.queue instead
+func (client *Client) preload(message WebsocketMessage) {
+	client.send <- message
+	if message.Seq > client.lastSeq {
+		client.lastSeq = message.Seq
+	}
+}
+
+// Reads messages from the client's connection and hands them to the Room's event loop until the connection fails or is closed.
+// If nothing is heard from the client (not even a pong) within [heartbeat].PongWait, the read fails and the connection is treated as dead
+func (client *Client) readPump() {
+	defer func() {
+		select {
+		case client.room.unregister <- client:
+		case <-client.room.done:
+		}
+	}()
+
+	client.conn.SetReadDeadline(time.Now().Add(client.heartbeat.PongWait))
+	client.conn.SetPongHandler(func(string) error {
+		client.stillAlive()
+		return nil
+	})
+
+	for {
+		_, message, err := client.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		client.stillAlive()
+
+		select {
+		case client.room.inbound <- clientMessage{client: client, message: message}:
+		case <-client.room.done:
+			return
+		}
+	}
+}
+
+// Pushes back the client's read deadline and keeps its connection tracked, since we've just heard from it
+func (client *Client) stillAlive() {
+	client.conn.SetReadDeadline(time.Now().Add(client.heartbeat.PongWait))
+	Engine.RefreshConnection(client.room.roomCode, client.playerId, client.connectionId, client.heartbeat.PongWait)
+}
+
+// Writes every message queued for the client to its connection, pinging it every [heartbeat].PingInterval in between.
+// Once the Room closes [client].send (or a write fails), the connection is closed
+func (client *Client) writePump() {
+	ticker := time.NewTicker(client.heartbeat.PingInterval)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-client.send:
+			if !ok {
+				return
+			}
+			client.conn.SetWriteDeadline(time.Now().Add(client.heartbeat.WriteWait))
+			if err := client.conn.WriteJSON(message); err != nil {
+				log.Printf("Error sending message to player {%s}. Closing their connection: %s", client.playerId, err)
+				return
+			}
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(client.heartbeat.WriteWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				log.Printf("Error pinging player {%s}. Closing their connection: %s", client.playerId, err)
+				return
+			}
+		}
+	}
+}
+
+// Creates a Client for [conn] belonging to [playerId] in [room]. Call room.addClient to start tracking it
+func newClient(room *Room, playerId string, spectator bool, conn *websocket.Conn) *Client {
+	return &Client{
+		room:      room,
+		playerId:  playerId,
+		spectator: spectator,
+		conn:      conn,
+		send:      make(chan WebsocketMessage, clientSendBufferSize),
+
+		heartbeat:    heartbeat,
+		connectionId: Engine.GenerateId(),
+	}
+}
@@ -0,0 +1,25 @@
+package Lobby
+
+import (
+	"candlelight-ruleengine/Engine"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Lists every public lobby that's still waiting for its game to start, so players can find one to join without being given its room code
+func GetPublicLobbies(w http.ResponseWriter, r *http.Request) {
+	funcLogPrefix := "==GetPublicLobbies=="
+
+	log.Printf("%s Received request for public lobbies", funcLogPrefix)
+
+	lobbies, err := Engine.GetPublicLobbies()
+	if err != nil {
+		log.Printf("%s ERROR: %s", funcLogPrefix, err)
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lobbies)
+}
@@ -2,6 +2,7 @@ package Lobby
 
 import (
 	"candlelight-api/CreationStudio"
+	"candlelight-models/Game"
 	"candlelight-models/Player"
 	"candlelight-models/Session"
 	"candlelight-ruleengine/Engine"
@@ -9,8 +10,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -138,7 +141,7 @@ func TestHostLobby(t *testing.T) {
 
 func TestJoinLobby(t *testing.T) {
 	ensureDummyGameExists()
-	roomCode, err := Engine.CreateRoom("game123")
+	roomCode, err := Engine.CreateRoom("game123", false)
 	if err != nil {
 		t.Fatal("Couldn't Create Lobby for dummy game! Ensure function createJSON has been called or a GET request has been sent to /dummy")
 	}
@@ -146,9 +149,6 @@ func TestJoinLobby(t *testing.T) {
 	defer testRecovery(t, "lobby:"+roomCode)
 	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode)
 
-	//Hack our newly created lobby into the gamesClients tracker
-	gamesClients[roomCode] = make(map[string]*websocket.Conn)
-
 	tests := []struct {
 		name          string
 		playerName    string
@@ -263,7 +263,7 @@ func TestJoinLobby(t *testing.T) {
 
 func TestRejoinLobby(t *testing.T) { //TODO: Tests don't take their player out of the lobby so we're hitting the max player count
 	ensureDummyGameExists()
-	roomCode, err := Engine.CreateRoom("game123")
+	roomCode, err := Engine.CreateRoom("game123", false)
 	if err != nil {
 		t.Fatal("Couldn't Create Lobby for dummy game! Ensure function createJSON has been called or a GET request has been sent to /dummy")
 	}
@@ -271,9 +271,6 @@ func TestRejoinLobby(t *testing.T) { //TODO: Tests don't take their player out o
 	defer testRecovery(t, "lobby:"+roomCode)
 	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode)
 
-	//Hack our newly created lobby into the gamesClients tracker
-	gamesClients[roomCode] = make(map[string]*websocket.Conn)
-
 	tests := []struct {
 		name                 string
 		firstJoinQueryString string
@@ -409,7 +406,7 @@ func TestRejoinLobby(t *testing.T) { //TODO: Tests don't take their player out o
 
 func TestLeaveGame(t *testing.T) {
 	ensureDummyGameExists()
-	roomCode, err := Engine.CreateRoom("game123")
+	roomCode, err := Engine.CreateRoom("game123", false)
 	if err != nil {
 		t.Fatal("Couldn't Create Lobby for dummy game! Ensure function createJSON has been called or a GET request has been sent to /dummy")
 	}
@@ -417,9 +414,6 @@ func TestLeaveGame(t *testing.T) {
 	defer testRecovery(t, "lobby:"+roomCode)
 	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode)
 
-	//Hack our newly created lobby into the gamesClients tracker
-	gamesClients[roomCode] = make(map[string]*websocket.Conn)
-
 	// Create a test server using the hostLobby handler.
 	// Dummy game must be created prior to running
 	server := httptest.NewServer(http.HandlerFunc(HostLobby))
@@ -570,20 +564,36 @@ func TestEndGame(t *testing.T) {
 		t.Fatalf("Websocket type mismatch. Expected a GameOver message, but got: %s", received.Type)
 	}
 
-	//Next, we should immediately get a Close Message
+	//Next, we should get the ended lobby, and the connection should stay open
 
 	ws.ReadJSON(&received)
 
-	if received.Type != WebsocketMessage_Close {
-		t.Fatalf("Websocket type mismatch. Expected a Close message, but got: %s", received.Type)
+	if received.Type != WebsocketMessage_LobbyInfo {
+		t.Fatalf("Websocket type mismatch. Expected a LobbyInfo message, but got: %s", received.Type)
 	}
 
-	closeMessage := SocketClose{}
+	endedInfo := LobbyInfo{}
 	asJson, _ := json.Marshal(received.Data)
-	json.Unmarshal(asJson, &closeMessage)
+	json.Unmarshal(asJson, &endedInfo)
+
+	if endedInfo.LobbyInfo.Status != Session.LobbyStatus_Ended {
+		t.Fatalf("Expected lobby to be ended, but its status is {%s}", endedInfo.LobbyInfo.Status)
+	}
+
+	//The host can then start over with the same lobby
+	err = ws.WriteJSON(map[string]any{"jsonType": "rematch"})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	if closeMessage.Message == "" {
-		t.Fatalf("Didn't get a close message!")
+	rematchInfo := LobbyInfo{}
+	readMessageData(t, ws, WebsocketMessage_LobbyInfo, &rematchInfo)
+
+	if rematchInfo.LobbyInfo.Status != Session.LobbyStatus_AwaitingStart || rematchInfo.LobbyInfo.RoomCode != endedInfo.LobbyInfo.RoomCode {
+		t.Fatalf("Expected lobby {%s} to be awaiting start again. Got lobby {%s} with status {%s}", endedInfo.LobbyInfo.RoomCode, rematchInfo.LobbyInfo.RoomCode, rematchInfo.LobbyInfo.Status)
+	}
+	if len(rematchInfo.LobbyInfo.Players) != 1 || rematchInfo.LobbyInfo.Players[0].Id != endedInfo.LobbyInfo.Host.Id {
+		t.Fatalf("Expected the same player to still be in the lobby. Got %+v", rematchInfo.LobbyInfo.Players)
 	}
 }
 
@@ -605,3 +615,1323 @@ func ensureDummyGameExists() {
 
 	CreationStudio.GenerateJSON(response, request)
 }
+
+func TestHeartbeat(t *testing.T) {
+	ensureDummyGameExists()
+
+	//Use short heartbeats so a dead connection is noticed quickly
+	defaultHeartbeat := heartbeat
+	heartbeat = heartbeatConfig{PingInterval: 50 * time.Millisecond, PongWait: 200 * time.Millisecond, WriteWait: time.Second}
+	defer func() { heartbeat = defaultHeartbeat }()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	mux.HandleFunc("/joinLobby", HandleJoinLobby)
+	mux.HandleFunc("/rejoinLobby", HandleRejoinLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode)
+
+	//This player never reads, so never answers a ping
+	silent, _, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName=silent&roomCode="+roomCode, nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect second player: %s", err)
+	}
+	defer silent.Close()
+
+	joinInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &joinInfo)
+
+	presence := PlayerPresence{}
+	readMessageData(t, host, WebsocketMessage_PlayerPresence, &presence)
+	if presence.PlayerId != joinInfo.PlayerID || presence.Connected {
+		t.Fatalf("Expected player {%s} to be reported as disconnected, got %+v", joinInfo.PlayerID, presence)
+	}
+
+	//The dead connection should be gone, so the player can rejoin
+	rejoined, _, err := websocket.DefaultDialer.Dial(wsBase+"/rejoinLobby?roomCode="+roomCode+"&playerId="+joinInfo.PlayerID, nil)
+	if err != nil {
+		t.Fatalf("Couldn't rejoin after connection was dropped! %s", err)
+	}
+	defer rejoined.Close()
+
+	readMessageData(t, host, WebsocketMessage_PlayerPresence, &presence)
+	if presence.PlayerId != joinInfo.PlayerID || !presence.Connected {
+		t.Fatalf("Expected player {%s} to be reported as reconnected, got %+v", joinInfo.PlayerID, presence)
+	}
+}
+
+// Reads messages from [ws] until one of the given [messageType] arrives, then unmarshals its Data into [data]
+func readMessageData(t *testing.T, ws *websocket.Conn, messageType string, data any) {
+	t.Helper()
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer ws.SetReadDeadline(time.Time{})
+
+	for {
+		received := WebsocketMessage{}
+		if err := ws.ReadJSON(&received); err != nil {
+			t.Fatalf("Failed waiting for a %s message: %s", messageType, err)
+		}
+		if received.Type != messageType {
+			continue
+		}
+
+		asJson, _ := json.Marshal(received.Data)
+		if err := json.Unmarshal(asJson, data); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+}
+
+func TestRejoinLobby_Resume(t *testing.T) {
+	ensureDummyGameExists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	mux.HandleFunc("/joinLobby", HandleJoinLobby)
+	mux.HandleFunc("/rejoinLobby", HandleRejoinLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	player, _, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName=player&roomCode="+roomCode, nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect second player: %s", err)
+	}
+
+	joined := WebsocketMessage{}
+	if err := player.ReadJSON(&joined); err != nil {
+		t.Fatal(err)
+	}
+	if joined.Seq == 0 {
+		t.Fatalf("Broadcast LobbyInfo was not given a sequence number")
+	}
+	joinInfo := LobbyInfo{}
+	asJson, _ := json.Marshal(joined.Data)
+	json.Unmarshal(asJson, &joinInfo)
+
+	//Disconnect, then have something happen while the player is gone
+	player.WriteJSON(map[string]string{"jsonType": "disconnect"})
+	readMessageData(t, player, WebsocketMessage_Close, &SocketClose{})
+	player.Close()
+	readMessageData(t, host, WebsocketMessage_PlayerPresence, &PlayerPresence{})
+
+	host.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &LobbyInfo{})
+
+	t.Run("Replays Missed Messages", func(t *testing.T) {
+		rejoined, _, err := websocket.DefaultDialer.Dial(wsBase+"/rejoinLobby?roomCode="+roomCode+"&playerId="+joinInfo.PlayerID+"&lastSeq="+strconv.FormatInt(joined.Seq, 10), nil)
+		if err != nil {
+			t.Fatalf("Couldn't rejoin! %s", err)
+		}
+
+		//Should be the player's own disconnect, followed by the host readying up
+		expectedTypes := []string{WebsocketMessage_PlayerPresence, WebsocketMessage_LobbyInfo}
+		for index, expectedType := range expectedTypes {
+			received := WebsocketMessage{}
+			if err := rejoined.ReadJSON(&received); err != nil {
+				t.Fatal(err)
+			}
+			if received.Type != expectedType || received.Seq != joined.Seq+int64(index)+1 {
+				t.Errorf("Replayed message #%d mismatch! Expected {%s, %d}, Got {%s, %d}", index, expectedType, joined.Seq+int64(index)+1, received.Type, received.Seq)
+			}
+		}
+
+		rejoined.WriteJSON(map[string]string{"jsonType": "disconnect"})
+		readMessageData(t, rejoined, WebsocketMessage_Close, &SocketClose{})
+		rejoined.Close()
+
+		//Wait until the host has heard about the disconnect, skipping past the reconnect
+		for presence := (PlayerPresence{Connected: true}); presence.Connected; {
+			readMessageData(t, host, WebsocketMessage_PlayerPresence, &presence)
+		}
+	})
+
+	t.Run("Falls Back To Full State", func(t *testing.T) {
+		latest, _ := Engine.CurrentRoomSeq(roomCode)
+		rejoined, _, err := websocket.DefaultDialer.Dial(wsBase+"/rejoinLobby?roomCode="+roomCode+"&playerId="+joinInfo.PlayerID+"&lastSeq=100000", nil)
+		if err != nil {
+			t.Fatalf("Couldn't rejoin! %s", err)
+		}
+		defer rejoined.Close()
+
+		received := WebsocketMessage{}
+		if err := rejoined.ReadJSON(&received); err != nil {
+			t.Fatal(err)
+		}
+		if received.Type != WebsocketMessage_LobbyInfo || received.Seq != latest {
+			t.Errorf("Expected a LobbyInfo numbered %d, Got {%s, %d}", latest, received.Type, received.Seq)
+		}
+	})
+}
+
+func TestHub_MultipleInstances(t *testing.T) {
+	ensureDummyGameExists()
+
+	//Two separate servers, sharing nothing but Redis
+	newServer := func() *httptest.Server {
+		hub := NewHub()
+		mux := http.NewServeMux()
+		mux.HandleFunc("/hostLobby", hub.HostLobby)
+		mux.HandleFunc("/joinLobby", hub.HandleJoinLobby)
+		mux.HandleFunc("/rejoinLobby", hub.HandleRejoinLobby)
+		return httptest.NewServer(mux)
+	}
+	serverA, serverB := newServer(), newServer()
+	defer serverA.Close()
+	defer serverB.Close()
+	wsBaseA := "ws" + strings.TrimPrefix(serverA.URL, "http")
+	wsBaseB := "ws" + strings.TrimPrefix(serverB.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBaseA+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode, "roomDisconnected:"+roomCode)
+
+	//Join through the other server. Everyone on both servers should hear about it
+	player, _, err := websocket.DefaultDialer.Dial(wsBaseB+"/joinLobby?playerName=player&roomCode="+roomCode, nil)
+	if err != nil {
+		t.Fatalf("Couldn't join through a different server than the host! %s", err)
+	}
+	defer player.Close()
+
+	playerInfo := LobbyInfo{}
+	readMessageData(t, player, WebsocketMessage_LobbyInfo, &playerInfo)
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	if len(hostInfo.LobbyInfo.Players) != 2 {
+		t.Fatalf("Host was not told about the player joining on another server. Lobby has %d players", len(hostInfo.LobbyInfo.Players))
+	}
+
+	t.Run("Broadcasts Reach Both Servers", func(t *testing.T) {
+		player.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+
+		readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+		if !hostInfo.LobbyInfo.Ready[playerInfo.PlayerID] {
+			t.Errorf("Host did not receive the other server's broadcast")
+		}
+		readMessageData(t, player, WebsocketMessage_LobbyInfo, &playerInfo)
+	})
+
+	t.Run("Open Connections Are Known To Both Servers", func(t *testing.T) {
+		_, _, err := websocket.DefaultDialer.Dial(wsBaseA+"/rejoinLobby?roomCode="+roomCode+"&playerId="+playerInfo.PlayerID, nil)
+		if err == nil {
+			t.Errorf("Was able to open a second connection for a player connected to another server")
+		}
+	})
+
+	t.Run("Kicking Closes Connections On Other Servers", func(t *testing.T) {
+		toKick, _, err := websocket.DefaultDialer.Dial(wsBaseB+"/joinLobby?playerName=toKick&roomCode="+roomCode, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer toKick.Close()
+
+		kickInfo := LobbyInfo{}
+		readMessageData(t, toKick, WebsocketMessage_LobbyInfo, &kickInfo)
+		readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+
+		host.WriteJSON(map[string]any{"jsonType": "kickPlayer", "data": map[string]string{"playerToKick": kickInfo.PlayerID}})
+		readMessageData(t, toKick, WebsocketMessage_Close, &SocketClose{})
+		readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+		if len(hostInfo.LobbyInfo.Players) != 2 {
+			t.Errorf("Kicked player is still in the lobby. Lobby has %d players", len(hostInfo.LobbyInfo.Players))
+		}
+	})
+
+	t.Run("Ending The Game Reaches Every Server", func(t *testing.T) {
+		host.WriteJSON(map[string]string{"jsonType": "endGame"})
+
+		readMessageData(t, player, WebsocketMessage_GameOver, &GameOver{})
+		endedInfo := LobbyInfo{}
+		readMessageData(t, player, WebsocketMessage_LobbyInfo, &endedInfo)
+		if endedInfo.LobbyInfo.Status != Session.LobbyStatus_Ended {
+			t.Errorf("Expected lobby to be ended, but its status is {%s}", endedInfo.LobbyInfo.Status)
+		}
+	})
+}
+
+func TestRequestIds(t *testing.T) {
+	ensureDummyGameExists()
+
+	server := httptest.NewServer(http.HandlerFunc(HostLobby))
+	defer server.Close()
+
+	host, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	//Reads messages until one of the given type arrives, returning the whole message
+	readMessage := func(messageType string) WebsocketMessage {
+		t.Helper()
+		host.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			received := WebsocketMessage{}
+			if err := host.ReadJSON(&received); err != nil {
+				t.Fatalf("Failed waiting for a %s message: %s", messageType, err)
+			}
+			if received.Type == messageType {
+				return received
+			}
+		}
+	}
+
+	t.Run("Error Echoes RequestId", func(t *testing.T) {
+		host.WriteJSON(map[string]any{"jsonType": "setReady", "requestId": "bad-ready", "data": "not an object"})
+
+		received := readMessage(WebsocketMessage_Error)
+		if received.RequestId != "bad-ready" {
+			t.Errorf("Expected Error to carry requestId {bad-ready}, Got {%s}", received.RequestId)
+		}
+	})
+
+	t.Run("Action Is Acknowledged", func(t *testing.T) {
+		host.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+		readMessage(WebsocketMessage_LobbyInfo)
+		host.WriteJSON(map[string]any{"jsonType": "startGame"})
+		gameStateMessage := readMessage(WebsocketMessage_GameState)
+		gameState := Session.GameState{}
+		asJson, _ := json.Marshal(gameStateMessage.Data)
+		json.Unmarshal(asJson, &gameState)
+		defer Engine.RDB.Del(Engine.RDB.Context(), "gameState:"+gameState.Id)
+
+		host.WriteJSON(map[string]any{
+			"jsonType":  "submitAction",
+			"requestId": "end-turn",
+			"data": map[string]any{
+				"gameId": gameState.Id,
+				"action": map[string]any{"type": Session.ActionType_EndTurn, "turn": map[string]any{}},
+			},
+		})
+
+		//The acknowledgement and the Changelog can arrive in either order
+		var changelog, accepted WebsocketMessage
+		for changelog.Type == "" || accepted.Type == "" {
+			received := WebsocketMessage{}
+			if err := host.ReadJSON(&received); err != nil {
+				t.Fatal(err)
+			}
+			switch received.Type {
+			case WebsocketMessage_Changelog:
+				changelog = received
+			case WebsocketMessage_ActionAccepted:
+				accepted = received
+			}
+		}
+		if accepted.RequestId != "end-turn" {
+			t.Errorf("Expected ActionAccepted to carry requestId {end-turn}, Got {%s}", accepted.RequestId)
+		}
+
+		acceptedData := ActionAccepted{}
+		asJson, _ = json.Marshal(accepted.Data)
+		json.Unmarshal(asJson, &acceptedData)
+		if acceptedData.ChangelogSeq != changelog.Seq {
+			t.Errorf("ActionAccepted points at the wrong Changelog. Expected {%d}, Got {%d}", changelog.Seq, acceptedData.ChangelogSeq)
+		}
+	})
+}
+
+func TestErrorCodes(t *testing.T) {
+	ensureDummyGameExists()
+
+	server := httptest.NewServer(http.HandlerFunc(HostLobby))
+	defer server.Close()
+	joinServer := httptest.NewServer(http.HandlerFunc(HandleJoinLobby))
+	defer joinServer.Close()
+
+	host, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	host.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &LobbyInfo{})
+	host.WriteJSON(map[string]any{"jsonType": "startGame"})
+	gameState := Session.GameState{}
+	readMessageData(t, host, WebsocketMessage_GameState, &gameState)
+	defer Engine.RDB.Del(Engine.RDB.Context(), "gameState:"+gameState.Id)
+
+	var socketTests = []struct {
+		Name            string
+		Action          map[string]any
+		ExpectedCode    string
+		ExpectedDetails map[string]string
+	}{
+		{
+			Name:            "Rejected Turn",
+			Action:          map[string]any{"type": Session.ActionType_CardFlip, "turn": map[string]any{"inView": "nowhere", "flipCard": "card"}},
+			ExpectedCode:    Session.ErrorCode_ViewNotFound,
+			ExpectedDetails: map[string]string{"viewId": "nowhere"},
+		},
+		{
+			Name:         "Malformed Turn",
+			Action:       map[string]any{"type": Session.ActionType_CardFlip, "turn": "not an object"},
+			ExpectedCode: Session.ErrorCode_MalformedRequest,
+		},
+		{
+			Name:         "Unknown Action Type",
+			Action:       map[string]any{"type": "Teleport", "turn": map[string]any{}},
+			ExpectedCode: Session.ErrorCode_UnknownActionType,
+		},
+	}
+
+	for _, tt := range socketTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			host.WriteJSON(map[string]any{"jsonType": "submitAction", "data": map[string]any{"gameId": gameState.Id, "action": tt.Action}})
+
+			socketError := SocketError{}
+			readMessageData(t, host, WebsocketMessage_Error, &socketError)
+			if socketError.Code != tt.ExpectedCode {
+				t.Errorf("Expected error code {%s}, Got {%s} (%s)", tt.ExpectedCode, socketError.Code, socketError.Message)
+			}
+			for key, value := range tt.ExpectedDetails {
+				if socketError.Details[key] != value {
+					t.Errorf("Expected detail {%s} to be {%s}, Got {%s}", key, value, socketError.Details[key])
+				}
+			}
+		})
+	}
+
+	var httpTests = []struct {
+		Name           string
+		Query          string
+		ExpectedStatus int
+		ExpectedCode   string
+	}{
+		{"Missing Parameters", "?playerName=someone", http.StatusBadRequest, Session.ErrorCode_MalformedRequest},
+		{"Unknown Lobby", "?playerName=someone&roomCode=NOPE", http.StatusNotFound, Session.ErrorCode_LobbyNotFound},
+		{"Game Already Started", "?playerName=latecomer&roomCode=" + roomCode, http.StatusNotFound, Session.ErrorCode_GameAlreadyStarted},
+	}
+
+	for _, tt := range httpTests {
+		t.Run(tt.Name, func(t *testing.T) {
+			resp, err := http.Get(joinServer.URL + tt.Query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.ExpectedStatus {
+				t.Errorf("Expected status {%d}, Got {%d}", tt.ExpectedStatus, resp.StatusCode)
+			}
+			socketError := SocketError{}
+			if err := json.NewDecoder(resp.Body).Decode(&socketError); err != nil {
+				t.Fatalf("Error response wasn't JSON: %s", err)
+			}
+			if socketError.Code != tt.ExpectedCode {
+				t.Errorf("Expected error code {%s}, Got {%s} (%s)", tt.ExpectedCode, socketError.Code, socketError.Message)
+			}
+		})
+	}
+}
+
+func TestChat(t *testing.T) {
+	ensureDummyGameExists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	mux.HandleFunc("/joinLobby", HandleJoinLobby)
+	mux.HandleFunc("/rejoinLobby", HandleRejoinLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode, "roomChat:"+roomCode)
+
+	//Joins a player, returning their connection and Id
+	join := func(name string) (*websocket.Conn, string) {
+		t.Helper()
+		ws, _, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName="+name+"&roomCode="+roomCode, nil)
+		if err != nil {
+			t.Fatalf("Error trying to connect %s: %s", name, err)
+		}
+		info := LobbyInfo{}
+		readMessageData(t, ws, WebsocketMessage_LobbyInfo, &info)
+		return ws, info.PlayerID
+	}
+	guest, guestId := join("guest")
+	defer guest.Close()
+	other, otherId := join("other")
+	defer other.Close()
+
+	chat := func(ws *websocket.Conn, message string, to string) {
+		ws.WriteJSON(map[string]any{"jsonType": "chat", "data": map[string]string{"message": message, "to": to}})
+	}
+
+	t.Run("Room-Wide And Private", func(t *testing.T) {
+		chat(host, "hello everyone", "")
+		received := Session.ChatMessage{}
+		readMessageData(t, guest, WebsocketMessage_ChatMessage, &received)
+		if received.Message != "hello everyone" || received.From != hostInfo.PlayerID || received.To != "" {
+			t.Errorf("Room-wide message arrived wrong. Got %+v", received)
+		}
+
+		readMessageData(t, host, WebsocketMessage_ChatMessage, &received) //The host's own copy
+
+		chat(guest, "just for you", hostInfo.PlayerID)
+		readMessageData(t, host, WebsocketMessage_ChatMessage, &received)
+		if received.Message != "just for you" || received.To != hostInfo.PlayerID {
+			t.Errorf("Private message arrived wrong. Got %+v", received)
+		}
+
+		//Anyone else's next chat message should skip over the private one
+		chat(host, "second", "")
+		readMessageData(t, other, WebsocketMessage_ChatMessage, &received) //"hello everyone"
+		readMessageData(t, other, WebsocketMessage_ChatMessage, &received)
+		if received.Message != "second" {
+			t.Errorf("A third player was sent a private message. Got %+v", received)
+		}
+	})
+
+	t.Run("Muted Player", func(t *testing.T) {
+		other.WriteJSON(map[string]any{"jsonType": "mutePlayer", "data": map[string]any{"playerId": hostInfo.PlayerID, "muted": true}})
+		socketError := SocketError{}
+		readMessageData(t, other, WebsocketMessage_Error, &socketError)
+		if socketError.Code != Session.ErrorCode_NotHost {
+			t.Errorf("Expected only the host to be able to mute. Got {%s}", socketError.Code)
+		}
+
+		host.WriteJSON(map[string]any{"jsonType": "mutePlayer", "data": map[string]any{"playerId": otherId, "muted": true}})
+		lobbyInfo := LobbyInfo{}
+		readMessageData(t, other, WebsocketMessage_LobbyInfo, &lobbyInfo)
+		if !lobbyInfo.LobbyInfo.Muted[otherId] {
+			t.Fatalf("Expected LobbyInfo to show player as muted. Got %v", lobbyInfo.LobbyInfo.Muted)
+		}
+
+		chat(other, "can anyone hear me", "")
+		readMessageData(t, other, WebsocketMessage_Error, &socketError)
+		if socketError.Code != Session.ErrorCode_Muted {
+			t.Errorf("Expected a Muted error, Got {%s}", socketError.Code)
+		}
+	})
+
+	t.Run("History Replayed On Rejoin", func(t *testing.T) {
+		guest.WriteJSON(map[string]string{"jsonType": "disconnect"})
+		readMessageData(t, guest, WebsocketMessage_Close, &SocketClose{})
+		guest.Close()
+		presence := PlayerPresence{}
+		for presence.PlayerId != guestId || presence.Connected {
+			readMessageData(t, host, WebsocketMessage_PlayerPresence, &presence)
+		}
+
+		rejoined, _, err := websocket.DefaultDialer.Dial(wsBase+"/rejoinLobby?roomCode="+roomCode+"&playerId="+guestId, nil)
+		if err != nil {
+			t.Fatalf("Couldn't rejoin: %s", err)
+		}
+		defer rejoined.Close()
+
+		history := ChatHistory{}
+		readMessageData(t, rejoined, WebsocketMessage_ChatHistory, &history)
+		sent := []string{}
+		for _, message := range history.Messages {
+			sent = append(sent, message.Message)
+		}
+		if !slices.Equal(sent, []string{"hello everyone", "just for you", "second"}) {
+			t.Errorf("Chat history was wrong. Got %v", sent)
+		}
+	})
+}
+
+func TestSpectateLobby(t *testing.T) {
+	ensureDummyGameExists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	mux.HandleFunc("/spectateLobby", HandleSpectateLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	spectator, _, err := websocket.DefaultDialer.Dial(wsBase+"/spectateLobby?playerName=watcher&roomCode="+roomCode, nil)
+	if err != nil {
+		t.Fatalf("Error trying to spectate: %s", err)
+	}
+	defer spectator.Close()
+
+	spectatorInfo := LobbyInfo{}
+	readMessageData(t, spectator, WebsocketMessage_LobbyInfo, &spectatorInfo)
+
+	t.Run("Not Counted As A Player", func(t *testing.T) {
+		lobby := spectatorInfo.LobbyInfo
+		if lobby.NumPlayers != 1 || len(lobby.Spectators) != 1 || lobby.Spectators[0].Id != spectatorInfo.PlayerID {
+			t.Errorf("Expected 1 player and the spectator. Got %d players and spectators %+v", lobby.NumPlayers, lobby.Spectators)
+		}
+	})
+
+	t.Run("Can't Take Actions", func(t *testing.T) {
+		spectator.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+		socketError := SocketError{}
+		readMessageData(t, spectator, WebsocketMessage_Error, &socketError)
+		if socketError.Code != Session.ErrorCode_NotAPlayer {
+			t.Errorf("Expected a NotAPlayer error, Got {%s}", socketError.Code)
+		}
+	})
+
+	t.Run("Sees Spectator GameState", func(t *testing.T) {
+		host.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+		host.WriteJSON(map[string]any{"jsonType": "startGame"})
+
+		gameState := Session.GameState{}
+		readMessageData(t, spectator, WebsocketMessage_GameState, &gameState)
+		defer Engine.RDB.Del(Engine.RDB.Context(), "gameState:"+gameState.Id)
+		for _, player := range gameState.Players {
+			if len(player.Hand) != 0 {
+				t.Errorf("Spectator was shown player {%s}'s hand", player.Id)
+			}
+		}
+	})
+
+	t.Run("Host Turns Off Spectating", func(t *testing.T) {
+		host.WriteJSON(map[string]any{"jsonType": "setSpectatorsAllowed", "data": map[string]bool{"allowed": false}})
+		readMessageData(t, spectator, WebsocketMessage_Close, &SocketClose{})
+
+		resp, err := http.Get(server.URL + "/spectateLobby?playerName=late&roomCode=" + roomCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		socketError := SocketError{}
+		json.NewDecoder(resp.Body).Decode(&socketError)
+		if socketError.Code != Session.ErrorCode_SpectatingDisabled {
+			t.Errorf("Expected spectating to be refused. Got {%s}", socketError.Code)
+		}
+	})
+}
+
+func TestHostMigration(t *testing.T) {
+	ensureDummyGameExists()
+
+	//Don't wait long for a disconnected host to come back
+	defaultGracePeriod := hostGracePeriod
+	hostGracePeriod = 100 * time.Millisecond
+	defer func() { hostGracePeriod = defaultGracePeriod }()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	mux.HandleFunc("/joinLobby", HandleJoinLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	guest, _, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName=guest&roomCode="+roomCode, nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect second player: %s", err)
+	}
+	defer guest.Close()
+
+	guestInfo := LobbyInfo{}
+	readMessageData(t, guest, WebsocketMessage_LobbyInfo, &guestInfo)
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &LobbyInfo{}) //guest joining
+
+	t.Run("Explicit Transfer", func(t *testing.T) {
+		guest.WriteJSON(map[string]any{"jsonType": "transferHost", "data": map[string]string{"newHost": guestInfo.PlayerID}})
+		socketError := SocketError{}
+		readMessageData(t, guest, WebsocketMessage_Error, &socketError)
+		if socketError.Code != Session.ErrorCode_NotHost {
+			t.Errorf("Expected only the host to be able to transfer host, Got %+v", socketError)
+		}
+
+		host.WriteJSON(map[string]any{"jsonType": "transferHost", "data": map[string]string{"newHost": guestInfo.PlayerID}})
+		updated := LobbyInfo{}
+		readMessageData(t, guest, WebsocketMessage_LobbyInfo, &updated)
+		if updated.LobbyInfo.Host.Id != guestInfo.PlayerID {
+			t.Fatalf("Expected guest to be host, Got {%s}", updated.LobbyInfo.Host.Id)
+		}
+		readMessageData(t, host, WebsocketMessage_LobbyInfo, &updated)
+
+		guest.WriteJSON(map[string]any{"jsonType": "transferHost", "data": map[string]string{"newHost": hostInfo.PlayerID}})
+		readMessageData(t, guest, WebsocketMessage_LobbyInfo, &updated)
+		if updated.LobbyInfo.Host.Id != hostInfo.PlayerID {
+			t.Fatalf("Expected host to be host again, Got {%s}", updated.LobbyInfo.Host.Id)
+		}
+	})
+
+	t.Run("Host Disconnects", func(t *testing.T) {
+		host.Close()
+
+		presence := PlayerPresence{}
+		readMessageData(t, guest, WebsocketMessage_PlayerPresence, &presence)
+		if presence.PlayerId != hostInfo.PlayerID || presence.Connected {
+			t.Fatalf("Expected host to be reported as disconnected, Got %+v", presence)
+		}
+
+		updated := LobbyInfo{}
+		readMessageData(t, guest, WebsocketMessage_LobbyInfo, &updated)
+		if updated.LobbyInfo.Host.Id != guestInfo.PlayerID {
+			t.Errorf("Expected guest to be made host once the grace period was up, Got {%s}", updated.LobbyInfo.Host.Id)
+		}
+	})
+}
+
+func TestUpdateLobbySettings(t *testing.T) {
+	ensureDummyGameExists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	mux.HandleFunc("/joinLobby", HandleJoinLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode, "lobbyPassword:"+roomCode)
+
+	guest, _, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName=guest&roomCode="+roomCode, nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect second player: %s", err)
+	}
+	defer guest.Close()
+	readMessageData(t, guest, WebsocketMessage_LobbyInfo, &LobbyInfo{})
+
+	guest.WriteJSON(map[string]any{"jsonType": "updateLobbySettings", "data": map[string]any{"maxPlayers": 2}})
+	socketError := SocketError{}
+	readMessageData(t, guest, WebsocketMessage_Error, &socketError)
+	if socketError.Code != Session.ErrorCode_NotHost {
+		t.Errorf("Expected only the host to be able to change settings, Got %+v", socketError)
+	}
+
+	host.WriteJSON(map[string]any{"jsonType": "updateLobbySettings", "data": map[string]any{"maxPlayers": 2, "showOtherPlayerDetails": false, "password": "secret"}})
+	updated := LobbyInfo{}
+	readMessageData(t, guest, WebsocketMessage_LobbyInfo, &updated)
+	if updated.LobbyInfo.MaxPlayers != 2 || !updated.LobbyInfo.HasPassword {
+		t.Errorf("Expected max players of 2 and a password. Got %d, %t", updated.LobbyInfo.MaxPlayers, updated.LobbyInfo.HasPassword)
+	}
+	if overrides := updated.LobbyInfo.RuleOverrides; overrides.ShowOtherPlayerDetails == nil || *overrides.ShowOtherPlayerDetails || overrides.EnforceTurnOrder != nil {
+		t.Errorf("Expected only ShowOtherPlayerDetails to be overridden. Got %+v", overrides)
+	}
+}
+
+func TestPublicAndPrivateLobbies(t *testing.T) {
+	ensureDummyGameExists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	mux.HandleFunc("/joinLobby", HandleJoinLobby)
+	mux.HandleFunc("/lobbies", GetPublicLobbies)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host&public=true", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode, "lobbyPassword:"+roomCode)
+
+	t.Run("Listed", func(t *testing.T) {
+		response, err := http.Get(server.URL + "/lobbies")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+
+		lobbies := []Session.LobbySummary{}
+		json.NewDecoder(response.Body).Decode(&lobbies)
+		if !slices.ContainsFunc(lobbies, func(summary Session.LobbySummary) bool {
+			return summary.RoomCode == roomCode && summary.HostName == "host"
+		}) {
+			t.Errorf("Expected lobby {%s} to be listed. Got %+v", roomCode, lobbies)
+		}
+	})
+
+	t.Run("Password Required", func(t *testing.T) {
+		host.WriteJSON(map[string]any{"jsonType": "updateLobbySettings", "data": map[string]any{"password": "secret"}})
+		readMessageData(t, host, WebsocketMessage_LobbyInfo, &LobbyInfo{})
+
+		_, response, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName=guest&roomCode="+roomCode+"&password=wrong", nil)
+		if err == nil || response.StatusCode != http.StatusForbidden {
+			t.Fatalf("Expected joining with the wrong password to be refused")
+		}
+		socketError := SocketError{}
+		json.NewDecoder(response.Body).Decode(&socketError)
+		if socketError.Code != Session.ErrorCode_WrongPassword {
+			t.Errorf("Expected a WrongPassword error, Got %+v", socketError)
+		}
+
+		guest, _, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName=guest&roomCode="+roomCode+"&password=secret", nil)
+		if err != nil {
+			t.Fatalf("Expected joining with the right password to work, Got %s", err)
+		}
+		guest.Close()
+	})
+}
+
+func TestBanAndVoteKick(t *testing.T) {
+	ensureDummyGameExists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	mux.HandleFunc("/joinLobby", HandleJoinLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	//Joins a player, returning their connection and Id
+	join := func(name string) (*websocket.Conn, string) {
+		t.Helper()
+		ws, _, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName="+name+"&roomCode="+roomCode, nil)
+		if err != nil {
+			t.Fatalf("Error trying to connect %s: %s", name, err)
+		}
+		info := LobbyInfo{}
+		readMessageData(t, ws, WebsocketMessage_LobbyInfo, &info)
+		return ws, info.PlayerID
+	}
+
+	t.Run("Banned Player Can't Rejoin", func(t *testing.T) {
+		banned, bannedId := join("banned")
+		defer banned.Close()
+
+		host.WriteJSON(map[string]any{"jsonType": "banPlayer", "data": map[string]string{"playerId": bannedId}})
+		readMessageData(t, banned, WebsocketMessage_Close, &SocketClose{})
+
+		//Whether under the same name, or a new one with the Id they were given
+		for _, query := range []string{"playerName=banned", "playerName=innocent&playerId=" + bannedId} {
+			_, response, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?"+query+"&roomCode="+roomCode, nil)
+			if err == nil {
+				t.Fatalf("Expected a banned player to be unable to join again with {%s}", query)
+			}
+			socketError := SocketError{}
+			json.NewDecoder(response.Body).Decode(&socketError)
+			if socketError.Code != Session.ErrorCode_Banned {
+				t.Errorf("Expected a Banned error for {%s}, Got %+v", query, socketError)
+			}
+		}
+	})
+
+	t.Run("Vote Kick", func(t *testing.T) {
+		guest, _ := join("guest")
+		defer guest.Close()
+		target, targetId := join("target")
+		defer target.Close()
+
+		//With 2 other players, both have to vote
+		host.WriteJSON(map[string]any{"jsonType": "voteKick", "data": map[string]string{"playerId": targetId}})
+		tally := LobbyInfo{}
+		for len(tally.LobbyInfo.KickVotes[targetId]) == 0 {
+			readMessageData(t, guest, WebsocketMessage_LobbyInfo, &tally)
+		}
+
+		guest.WriteJSON(map[string]any{"jsonType": "voteKick", "data": map[string]string{"playerId": targetId}})
+		readMessageData(t, target, WebsocketMessage_Close, &SocketClose{})
+
+		updated := LobbyInfo{}
+		for updated.LobbyInfo.NumPlayers != 2 {
+			readMessageData(t, guest, WebsocketMessage_LobbyInfo, &updated)
+		}
+		if len(updated.LobbyInfo.Banned) != 2 {
+			t.Errorf("Expected the voted-out player to be banned too. Got %+v", updated.LobbyInfo.Banned)
+		}
+	})
+}
+
+func TestLeaveMidGame(t *testing.T) {
+	ensureDummyGameExists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	mux.HandleFunc("/joinLobby", HandleJoinLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	guest, _, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName=guest&roomCode="+roomCode, nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect guest: %s", err)
+	}
+	defer guest.Close()
+	guestInfo := LobbyInfo{}
+	readMessageData(t, guest, WebsocketMessage_LobbyInfo, &guestInfo)
+
+	host.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+	guest.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+	ready := LobbyInfo{}
+	for len(ready.LobbyInfo.Ready) != 2 {
+		readMessageData(t, host, WebsocketMessage_LobbyInfo, &ready)
+	}
+	host.WriteJSON(map[string]any{"jsonType": "startGame"})
+
+	gameState := Session.GameState{}
+	readMessageData(t, host, WebsocketMessage_GameState, &gameState)
+	defer Engine.RDB.Del(Engine.RDB.Context(), "gameState:"+gameState.Id)
+
+	//The dummy game doesn't set a LeavePolicy, so the guest's seat is frozen and everything in their hand stays in the game
+	guest.WriteJSON(map[string]any{"jsonType": "leaveLobby"})
+
+	changelog := Session.Changelog{}
+	readMessageData(t, host, WebsocketMessage_Changelog, &changelog)
+	if changelog.Seats[guestInfo.PlayerID] != Player.SeatStatus_Frozen {
+		t.Errorf("Expected the guest's seat to be frozen. Got %v", changelog.Seats)
+	}
+
+	cached, err := Engine.GetCachedGameStateFromRedis(gameState.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached.Players) != 2 {
+		t.Errorf("Expected the guest's seat to stay in the game. Got %+v", cached.Players)
+	}
+}
+
+func TestSeatTakeover(t *testing.T) {
+	Engine.SaveGameDefToDB(Game.Game{Id: "takeoverGame", Name: "takeover", MaxPlayers: 4, Rules: Game.GameRules{LeavePolicy: Game.LeavePolicy_OpenSeat}})
+	defer Engine.RDB.Del(Engine.RDB.Context(), "game:takeoverGame")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	mux.HandleFunc("/joinLobby", HandleJoinLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=takeoverGame&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	guest, _, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName=guest&roomCode="+roomCode, nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect guest: %s", err)
+	}
+	defer guest.Close()
+	guestInfo := LobbyInfo{}
+	readMessageData(t, guest, WebsocketMessage_LobbyInfo, &guestInfo)
+
+	host.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+	guest.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+	ready := LobbyInfo{}
+	for len(ready.LobbyInfo.Ready) != 2 {
+		readMessageData(t, host, WebsocketMessage_LobbyInfo, &ready)
+	}
+	host.WriteJSON(map[string]any{"jsonType": "startGame"})
+
+	gameState := Session.GameState{}
+	readMessageData(t, host, WebsocketMessage_GameState, &gameState)
+	defer Engine.RDB.Del(Engine.RDB.Context(), "gameState:"+gameState.Id)
+
+	guest.WriteJSON(map[string]any{"jsonType": "leaveLobby"})
+	changelog := Session.Changelog{}
+	readMessageData(t, host, WebsocketMessage_Changelog, &changelog)
+	if changelog.Seats[guestInfo.PlayerID] != Player.SeatStatus_Open {
+		t.Fatalf("Expected the guest's seat to be left open. Got %v", changelog.Seats)
+	}
+
+	newcomer, _, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName=newcomer&roomCode="+roomCode, nil)
+	if err != nil {
+		t.Fatalf("Expected the newcomer to be able to ask for the open seat, Got %s", err)
+	}
+	defer newcomer.Close()
+	newcomerInfo := LobbyInfo{}
+	readMessageData(t, newcomer, WebsocketMessage_LobbyInfo, &newcomerInfo)
+	newcomerId := newcomerInfo.PlayerID
+
+	t.Run("Waits For The Host", func(t *testing.T) {
+		requested := LobbyInfo{}
+		for len(requested.LobbyInfo.SeatRequests) == 0 {
+			readMessageData(t, host, WebsocketMessage_LobbyInfo, &requested)
+		}
+		if requested.LobbyInfo.SeatRequests[0].SeatId != guestInfo.PlayerID || requested.LobbyInfo.NumPlayers != 1 {
+			t.Errorf("Expected the newcomer to be waiting for the guest's seat. Got %+v", requested.LobbyInfo)
+		}
+
+		newcomer.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+		socketError := SocketError{}
+		readMessageData(t, newcomer, WebsocketMessage_Error, &socketError)
+		if socketError.Code != Session.ErrorCode_NotAPlayer {
+			t.Errorf("Expected the newcomer to be unable to act before being seated, Got {%s}", socketError.Code)
+		}
+	})
+
+	t.Run("Host Approves", func(t *testing.T) {
+		host.WriteJSON(map[string]any{"jsonType": "answerSeatRequest", "data": map[string]any{"playerId": newcomerId, "approve": true}})
+
+		seated := Session.GameState{}
+		readMessageData(t, newcomer, WebsocketMessage_GameState, &seated)
+		if !slices.ContainsFunc(seated.Players, func(p Player.Player) bool { return p.Id == newcomerId && p.Name == "newcomer" && p.SeatStatus == "" }) {
+			t.Errorf("Expected the newcomer to have taken over the seat. Got %+v", seated.Players)
+		}
+
+		//Now that they're a player, they're told the game has started rather than that they can't act
+		newcomer.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+		socketError := SocketError{}
+		readMessageData(t, newcomer, WebsocketMessage_Error, &socketError)
+		if socketError.Code != Session.ErrorCode_GameAlreadyStarted {
+			t.Errorf("Expected the newcomer to be treated as a player, Got {%s}", socketError.Code)
+		}
+	})
+
+	t.Run("No Seats Left", func(t *testing.T) {
+		_, response, err := websocket.DefaultDialer.Dial(wsBase+"/joinLobby?playerName=late&roomCode="+roomCode, nil)
+		if err == nil {
+			t.Fatalf("Expected nobody else to be able to join")
+		}
+		socketError := SocketError{}
+		json.NewDecoder(response.Body).Decode(&socketError)
+		if socketError.Code != Session.ErrorCode_GameAlreadyStarted {
+			t.Errorf("Expected a GameAlreadyStarted error, Got %+v", socketError)
+		}
+	})
+}
+
+func TestBots(t *testing.T) {
+	ensureDummyGameExists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	host.WriteJSON(map[string]any{"jsonType": "addBot", "data": map[string]string{"strategy": Engine.BotStrategy_Greedy}})
+	withBot := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &withBot)
+	if len(withBot.LobbyInfo.Players) != 2 || withBot.LobbyInfo.Players[1].Bot != Engine.BotStrategy_Greedy {
+		t.Fatalf("Expected a Greedy bot to join the lobby. Got %+v", withBot.LobbyInfo.Players)
+	}
+	botId := withBot.LobbyInfo.Players[1].Id
+
+	host.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+	ready := LobbyInfo{}
+	for !ready.LobbyInfo.AllPlayersReady() {
+		readMessageData(t, host, WebsocketMessage_LobbyInfo, &ready)
+	}
+	host.WriteJSON(map[string]any{"jsonType": "startGame"})
+
+	gameState := Session.GameState{}
+	readMessageData(t, host, WebsocketMessage_GameState, &gameState)
+	defer Engine.RDB.Del(Engine.RDB.Context(), "gameState:"+gameState.Id)
+	if gameState.Players[1].SeatStatus != Player.SeatStatus_Bot {
+		t.Fatalf("Expected the bot's seat to be played by a bot. Got %+v", gameState.Players[1])
+	}
+
+	//Once the host passes the turn, the bot plays its own and hands it straight back
+	host.WriteJSON(map[string]any{"jsonType": "submitAction", "data": map[string]any{"gameId": gameState.Id, "action": map[string]any{"type": Session.ActionType_EndTurn, "turn": map[string]string{}}}})
+
+	changelog := Session.Changelog{}
+	readMessageData(t, host, WebsocketMessage_Changelog, &changelog)
+	if changelog.CurrentPlayer != botId {
+		t.Fatalf("Expected the turn to pass to the bot. Got %s", changelog.CurrentPlayer)
+	}
+	for changelog.CurrentPlayer == botId {
+		readMessageData(t, host, WebsocketMessage_Changelog, &changelog)
+	}
+	if changelog.CurrentPlayer != hostInfo.PlayerID {
+		t.Errorf("Expected the bot to hand the turn back to the host. Got %s", changelog.CurrentPlayer)
+	}
+}
+
+func TestGetLegalActions(t *testing.T) {
+	ensureDummyGameExists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	host.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &LobbyInfo{})
+	host.WriteJSON(map[string]any{"jsonType": "startGame"})
+
+	gameState := Session.GameState{}
+	readMessageData(t, host, WebsocketMessage_GameState, &gameState)
+	defer Engine.RDB.Del(Engine.RDB.Context(), "gameState:"+gameState.Id)
+
+	host.WriteJSON(map[string]any{"jsonType": "getLegalActions", "requestId": "legal", "data": map[string]string{"gameId": gameState.Id}})
+
+	host.SetReadDeadline(time.Now().Add(5 * time.Second))
+	received := WebsocketMessage{}
+	for received.Type != WebsocketMessage_LegalActions {
+		if err := host.ReadJSON(&received); err != nil {
+			t.Fatalf("Failed waiting for LegalActions: %s", err)
+		}
+	}
+	if received.RequestId != "legal" {
+		t.Errorf("Expected LegalActions to carry requestId {legal}, Got {%s}", received.RequestId)
+	}
+
+	legal := LegalActions{}
+	asJson, _ := json.Marshal(received.Data)
+	json.Unmarshal(asJson, &legal)
+	if !slices.ContainsFunc(legal.Actions, func(a Session.SubmittedAction) bool { return a.Type == Session.ActionType_EndTurn }) {
+		t.Errorf("Expected the host to be able to end their turn. Got %+v", legal.Actions)
+	}
+}
+
+func TestTurnTimer(t *testing.T) {
+	ensureDummyGameExists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	host.WriteJSON(map[string]any{"jsonType": "updateLobbySettings", "data": map[string]int{"turnTimeLimit": 2}})
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &LobbyInfo{})
+	host.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &LobbyInfo{})
+	host.WriteJSON(map[string]any{"jsonType": "startGame"})
+
+	gameState := Session.GameState{}
+	readMessageData(t, host, WebsocketMessage_GameState, &gameState)
+	defer Engine.RDB.Del(Engine.RDB.Context(), "gameState:"+gameState.Id)
+	defer host.WriteJSON(map[string]string{"jsonType": "endGame"})
+	if gameState.TurnDeadline == nil {
+		t.Fatalf("Expected the first turn to have a deadline")
+	}
+
+	warning := TurnWarning{}
+	readMessageData(t, host, WebsocketMessage_TurnWarning, &warning)
+	if !warning.Deadline.Equal(*gameState.TurnDeadline) || warning.SecondsLeft < 1 {
+		t.Errorf("Expected a warning about the first turn's deadline. Got %+v", warning)
+	}
+
+	changelog := Session.Changelog{}
+	readMessageData(t, host, WebsocketMessage_Changelog, &changelog)
+	if !strings.Contains(changelog.MostRecentAction, "ran out of time") {
+		t.Errorf("Expected the host's turn to be ended for them. Got %s", changelog.MostRecentAction)
+	}
+	if changelog.TurnDeadline == nil || !changelog.TurnDeadline.After(*gameState.TurnDeadline) {
+		t.Errorf("Expected the next turn to get a new deadline. Got %v", changelog.TurnDeadline)
+	}
+}
+
+func TestScheduleTurnTimer(t *testing.T) {
+	room := &Room{hub: NewHub(), roomCode: "timers"}
+	deadline := time.Now().Add(time.Hour)
+	gameState := Session.GameState{TurnDeadline: &deadline, Rules: Game.GameRules{TurnTimeLimit: 3600}}
+
+	room.scheduleTurnTimer(gameState)
+	timers := room.turnTimers
+	if len(timers) != 2 {
+		t.Fatalf("Expected a warning and a timeout to be set. Got %d timers", len(timers))
+	}
+
+	//Every action reschedules, but the same turn should keep the same timers
+	room.scheduleTurnTimer(gameState)
+	if !slices.Equal(room.turnTimers, timers) {
+		t.Errorf("Expected the timers to be kept for the same deadline")
+	}
+
+	next := deadline.Add(time.Minute)
+	gameState.TurnDeadline = &next
+	room.scheduleTurnTimer(gameState)
+	if slices.Equal(room.turnTimers, timers) || timers[0].Stop() || timers[1].Stop() {
+		t.Errorf("Expected the old timers to be stopped and replaced for a new deadline")
+	}
+
+	gameState.TurnDeadline = nil
+	stillSet := room.turnTimers
+	room.scheduleTurnTimer(gameState)
+	if len(room.turnTimers) != 0 || stillSet[0].Stop() || stillSet[1].Stop() {
+		t.Errorf("Expected every timer to be stopped once turns have no deadline")
+	}
+}
+
+func TestCommitAction(t *testing.T) {
+	ensureDummyGameExists()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hostLobby", HostLobby)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	wsBase := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	host, _, err := websocket.DefaultDialer.Dial(wsBase+"/hostLobby?gameId=game123&playerName=host", nil)
+	if err != nil {
+		t.Fatalf("Error trying to connect: %s", err)
+	}
+	defer host.Close()
+
+	hostInfo := LobbyInfo{}
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &hostInfo)
+	roomCode := hostInfo.LobbyInfo.RoomCode
+	defer Engine.RDB.Del(Engine.RDB.Context(), "lobby:"+roomCode, "roomSeq:"+roomCode, "roomMessages:"+roomCode)
+
+	host.WriteJSON(map[string]any{"jsonType": "addBot", "data": map[string]string{}})
+	readMessageData(t, host, WebsocketMessage_LobbyInfo, &LobbyInfo{})
+	host.WriteJSON(map[string]any{"jsonType": "setReady", "data": map[string]bool{"ready": true}})
+	ready := LobbyInfo{}
+	for !ready.LobbyInfo.AllPlayersReady() {
+		readMessageData(t, host, WebsocketMessage_LobbyInfo, &ready)
+	}
+	host.WriteJSON(map[string]any{"jsonType": "startGame"})
+
+	gameState := Session.GameState{}
+	readMessageData(t, host, WebsocketMessage_GameState, &gameState)
+	defer Engine.RDB.Del(Engine.RDB.Context(), "gameState:"+gameState.Id, "roundCommitments:"+gameState.Id)
+
+	//The dummy game is played in turns, so switch it over to rounds
+	gameState.Rules.SimultaneousRounds = true
+	Engine.CacheGameStateInRedis(gameState)
+
+	host.WriteJSON(map[string]any{"jsonType": "commitAction", "requestId": "commit", "data": map[string]any{"gameId": gameState.Id, "action": map[string]any{"type": Session.ActionType_EndTurn, "turn": map[string]string{}}}})
+
+	accepted := ActionAccepted{}
+	readMessageData(t, host, WebsocketMessage_ActionAccepted, &accepted)
+	if accepted.ChangelogSeq != 0 {
+		t.Errorf("Expected nothing to be revealed until the bot commits, Got changelog seq %d", accepted.ChangelogSeq)
+	}
+
+	//The bot commits straight after, revealing the round
+	changelog := Session.Changelog{}
+	readMessageData(t, host, WebsocketMessage_Changelog, &changelog)
+	if !strings.Contains(changelog.MostRecentAction, "Everyone has committed") || !strings.Contains(changelog.MostRecentAction, "'host' passed") {
+		t.Errorf("Expected one Changelog for the whole round. Got %s", changelog.MostRecentAction)
+	}
+
+	//The bot commits to the next round straight away too, leaving only the host to wait on
+	progress := Engine.RoundProgress{}
+	readMessageData(t, host, WebsocketMessage_RoundProgress, &progress)
+	if !slices.Equal(progress.Waiting, []string{hostInfo.PlayerID}) || len(progress.Committed) != 1 {
+		t.Errorf("Expected a fresh round waiting on just the host. Got %+v", progress)
+	}
+}
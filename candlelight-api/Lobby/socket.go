@@ -8,17 +8,11 @@ import (
 	"log"
 	"net/http"
 	"slices"
-	"sync"
+	"strconv"
 
 	"github.com/gorilla/websocket"
 )
 
-// Outer key is the room code, inner key is the playerId. Value is the actual connection object
-var gamesClients = make(map[string]map[string]*websocket.Conn)
-
-// Mutex to access gamesClients in a thread-safe manner
-var gamesClientsMutex = sync.Mutex{}
-
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  32768, // Setting read buffer size to 32 KB
 	WriteBufferSize: 32768, // Setting write buffer size to 32 KB
@@ -27,28 +21,50 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+// HostLobby handles /hostLobby using this server's Hub. See Hub.HostLobby
+func HostLobby(w http.ResponseWriter, r *http.Request) {
+	defaultHub.HostLobby(w, r)
+}
+
+// HandleJoinLobby handles /joinLobby using this server's Hub. See Hub.HandleJoinLobby
+func HandleJoinLobby(w http.ResponseWriter, r *http.Request) {
+	defaultHub.HandleJoinLobby(w, r)
+}
+
+// HandleRejoinLobby handles /rejoinLobby using this server's Hub. See Hub.HandleRejoinLobby
+func HandleRejoinLobby(w http.ResponseWriter, r *http.Request) {
+	defaultHub.HandleRejoinLobby(w, r)
+}
+
+// HandleSpectateLobby handles /spectateLobby using this server's Hub. See Hub.HandleSpectateLobby
+func HandleSpectateLobby(w http.ResponseWriter, r *http.Request) {
+	defaultHub.HandleSpectateLobby(w, r)
+}
+
 // HostLobby creates the waiting lobby, joins on behalf of the given player, and upgrades the host into a websocket.
 // The lobby (which contains the Room Code used for other people to join) is then passed back into the websocket.
-func HostLobby(w http.ResponseWriter, r *http.Request) {
+func (hub *Hub) HostLobby(w http.ResponseWriter, r *http.Request) {
 	log.Println("Starting hostLobby")
 	gameDefId := r.URL.Query().Get("gameId")
 	playerName := r.URL.Query().Get("playerName")
+	public := r.URL.Query().Get("public") == "true"
 
 	if gameDefId == "" || playerName == "" {
 		log.Println("Missing gameId or playerName in request")
-		http.Error(w, "Missing gameId or playerName in request", http.StatusBadRequest)
+		httpError(w, http.StatusBadRequest, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Missing gameId or playerName in request"))
 		return
 	}
 
-	roomCode, err := Engine.CreateRoom(gameDefId) // Assuming Engine.CreateRoom initializes room in DB
+	roomCode, err := Engine.CreateRoom(gameDefId, public) // Assuming Engine.CreateRoom initializes room in DB
 	if err != nil {
-		http.Error(w, "Unable to create room", http.StatusInternalServerError)
+		log.Printf("Error creating room: %v\n", err)
+		httpError(w, http.StatusInternalServerError, err)
 		return
 	}
-	lobbyInfo, playerID, err := Engine.JoinRoom(roomCode, playerName)
+	lobbyInfo, playerID, err := Engine.JoinRoom(roomCode, playerName, "")
 	if err != nil {
 		log.Printf("Error joining room: %v\n", err)
-		http.Error(w, "Unable to join room", http.StatusInternalServerError)
+		httpError(w, http.StatusInternalServerError, err)
 		return
 	}
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -56,104 +72,147 @@ func HostLobby(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "WebSocket upgrade failed", http.StatusInternalServerError)
 		return
 	}
-	msg := WebsocketMessage{
-		Type: WebsocketMessage_LobbyInfo,
-		Data: LobbyInfo{
-			PlayerID:  playerID,
-			LobbyInfo: lobbyInfo,
-		},
-	}
-	conn.WriteJSON(msg)
-	gamesClientsMutex.Lock()
-	if _, exists := gamesClients[roomCode]; !exists {
-		gamesClients[roomCode] = make(map[string]*websocket.Conn)
-	}
-	gamesClients[roomCode][playerID] = conn
-	gamesClientsMutex.Unlock()
-
-	go manageClient(roomCode, gamesClients[roomCode], playerID, conn)
+	hub.connect(roomCode, playerID, false, conn, func(client *Client) {
+		client.preload(WebsocketMessage{
+			Type: WebsocketMessage_LobbyInfo,
+			Data: LobbyInfo{
+				PlayerID:  playerID,
+				LobbyInfo: lobbyInfo,
+			},
+		})
+	})
 }
 
 // Given a playerName and roomCode, tries to join that room for the given player. If joining was successul,
-// the client's connection is upgraded to a websocket. Once complete, the client receives the lobby info
-func HandleJoinLobby(w http.ResponseWriter, r *http.Request) {
+// the client's connection is upgraded to a websocket. Once complete, the client receives the lobby info. If the game is already
+// in progress, the player instead asks to take over an open seat (see requestSeat)
+func (hub *Hub) HandleJoinLobby(w http.ResponseWriter, r *http.Request) {
 	roomCode := r.URL.Query().Get("roomCode")
 	playerName := r.URL.Query().Get("playerName")
 
 	if roomCode == "" || playerName == "" {
-		http.Error(w, "Please provide roomCode and playerName", http.StatusBadRequest)
+		httpError(w, http.StatusBadRequest, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Please provide roomCode and playerName"))
+		return
+	}
+
+	if !checkPassword(w, roomCode, r.URL.Query().Get("password")) {
 		return
 	}
 
-	_, playerID, err := Engine.JoinRoom(roomCode, playerName)
+	if lobby, err := Engine.LoadLobbyFromRedis(roomCode); err == nil && lobby.Status == Session.LobbyStatus_InProgress {
+		hub.requestSeat(w, r, roomCode, playerName)
+		return
+	}
+
+	_, playerID, err := Engine.JoinRoom(roomCode, playerName, r.URL.Query().Get("playerId"))
 	if err != nil {
 		log.Printf("Error joining room: %v\n", err)
-		http.Error(w, "Unable to join room", http.StatusNotFound)
+		httpError(w, http.StatusNotFound, err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		http.Error(w, "WebSocket upgrade failed", http.StatusInternalServerError)
 		return
 	}
 
-	gamesClientsMutex.Lock()
-	if _, exists := gamesClients[roomCode]; !exists {
-		http.Error(w, "Lobby is not being tracked by server.", http.StatusInternalServerError)
-		gamesClientsMutex.Unlock()
+	if hub.connect(roomCode, playerID, false, conn, nil) {
+		handShake(roomCode, playerID)
+	}
+}
+
+// Asks for [playerName] to take over an open seat in the in-progress game in the lobby with [roomCode] (the one given as seatId, if any), then
+// upgrades their connection to a websocket. Until the host answers, they're treated as a spectator: they're sent what spectators would be, and can't
+// take any actions. If the host approves, they become a player and are sent the GameState. If not, their connection is closed
+func (hub *Hub) requestSeat(w http.ResponseWriter, r *http.Request, roomCode string, playerName string) {
+	_, playerId, err := Engine.RequestSeat(roomCode, playerName, r.URL.Query().Get("seatId"), r.URL.Query().Get("playerId"))
+	if err != nil {
+		log.Printf("Error requesting a seat: %v\n", err)
+		httpError(w, http.StatusNotFound, err)
 		return
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		Engine.WithdrawSeatRequest(roomCode, playerId)
 		http.Error(w, "WebSocket upgrade failed", http.StatusInternalServerError)
 		return
 	}
 
-	// msg := WebsocketMessage{
-	// 	Type: WebsocketMessage_LobbyInfo,
-	// 	Data: LobbyInfo{
-	// 		PlayerID:  playerID,
-	// 		LobbyInfo: lobbyInfo,
-	// 	},
-	// }
-	//conn.WriteJSON(msg)
-	gamesClients[roomCode][playerID] = conn
-	gamesClientsMutex.Unlock()
-	go handShake(roomCode, playerID)
+	connected := hub.connect(roomCode, playerId, true, conn, func(client *Client) {
+		client.claimingSeat = true
+		client.room.welcomeSpectator(client)
+	})
+	if !connected {
+		Engine.WithdrawSeatRequest(roomCode, playerId)
+		return
+	}
+
+	//Let everyone (especially the host) know someone is waiting for a seat
+	handShake(roomCode, playerId)
+}
+
+// Makes sure [password] is the password of the lobby with [roomCode], if it has one. If it isn't, an error response is written to [w] and false is returned
+func checkPassword(w http.ResponseWriter, roomCode string, password string) bool {
+	ok, err := Engine.CheckLobbyPassword(roomCode, password)
+	if err != nil {
+		log.Printf("Error checking password for room {%s}: %v\n", roomCode, err)
+		httpError(w, http.StatusInternalServerError, err)
+		return false
+	}
+	if !ok {
+		httpError(w, http.StatusForbidden, Session.NewGameError(Session.ErrorCode_WrongPassword, "That lobby needs a password, and the one given was wrong!"))
+		return false
+	}
+	return true
 }
 
 // Given a roomCode and a playerId (which should have been generated by the backend upon Hosting or Joining that lobby), will attempt to
 // reinsert the player into the Lobby. If successful, connection is upgraded to a websocket, then, depending on whether the game has started yet
-// or not, either a LobbyInfo or GameState is sent to the player, after which they're added into the regular flow of listening for messages
-func HandleRejoinLobby(w http.ResponseWriter, r *http.Request) {
+// or not, either a LobbyInfo or GameState is sent to the player, after which they're added into the regular flow of listening for messages.
+// If the player sends the sequence number of the last message they received as [lastSeq], they're instead sent only the messages they missed, when possible
+func (hub *Hub) HandleRejoinLobby(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received request to rejoin a lobby!")
 
 	roomCode := r.URL.Query().Get("roomCode")
 	playerId := r.URL.Query().Get("playerId")
 
 	if roomCode == "" || playerId == "" {
-		http.Error(w, "Please send both roomCode and playerId", http.StatusBadRequest)
+		httpError(w, http.StatusBadRequest, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Please send both roomCode and playerId"))
 		return
 	}
 
+	//Optionally, the sequence number of the last message the player received, so they can be sent only what they've missed
+	lastSeq, hasLastSeq := int64(0), r.URL.Query().Has("lastSeq")
+	if hasLastSeq {
+		parsed, err := strconv.ParseInt(r.URL.Query().Get("lastSeq"), 10, 64)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, Session.NewGameError(Session.ErrorCode_MalformedRequest, "lastSeq must be a number", "lastSeq", r.URL.Query().Get("lastSeq")))
+			return
+		}
+		lastSeq = parsed
+	}
+
 	lobbyInfo, err := Engine.LoadLobbyFromRedis(roomCode)
 	if err != nil {
-		http.Error(w, "Could not find requested lobby", http.StatusNotFound)
+		httpError(w, http.StatusNotFound, err)
 		return
 	}
 
 	//Make sure this player has joined the game before
 	log.Printf("Making sure player {%s} has joined this game before", playerId)
 	if !slices.ContainsFunc(lobbyInfo.Players, func(p Player.Player) bool { return p.Id == playerId }) {
-		http.Error(w, "No player with given ID found in lobby", http.StatusNotFound)
+		httpError(w, http.StatusNotFound, Session.NewGameError(Session.ErrorCode_PlayerNotFound, "No player with given ID found in lobby", "playerId", playerId))
 		return
 	}
 
 	//Make sure that player does not already have an open connection
 	log.Printf("Making sure player {%s} does not already have an open connection", playerId)
-	gamesClientsMutex.Lock()
-	if _, exists := gamesClients[roomCode][playerId]; exists {
-		http.Error(w, "Found already open connection for player", http.StatusBadRequest)
-		gamesClientsMutex.Unlock()
+	if connected, _ := Engine.IsPlayerConnected(roomCode, playerId); connected {
+		httpError(w, http.StatusBadRequest, Session.NewGameError(Session.ErrorCode_AlreadyConnected, "Found already open connection for player", "playerId", playerId))
 		return
 	}
-	gamesClientsMutex.Unlock()
 
 	//Now we should know the player is allowed to rejoin. Upgrade to websocket
 	log.Printf("Player {%s} is allowed to rejoin. Upgrading connection to websocket.", playerId)
@@ -163,135 +222,271 @@ func HandleRejoinLobby(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Println("Upgrade finished. Checking status of lobby to give accurate first message(s)...")
+	log.Printf("Player {%s} is allowed back in. Beginning to track connection for further communication...", playerId)
 
-	//If the game has ended, indicate as such and close connection
-	if lobbyInfo.Status == Session.LobbyStatus_Ended {
-		log.Println("Game has already ended! Player cannot join!")
-		msg := WebsocketMessage{
-			Type: WebsocketMessage_Error,
-			Data: SocketError{
-				Message: "Game has already ended. Cannot rejoin",
-			},
-		}
-		conn.WriteJSON(msg)
-		conn.Close()
+	//Start tracking the connection. Their first message(s) are queued on the Room's event loop so nothing sent in the meantime is missed
+	hub.connect(roomCode, playerId, false, conn, func(client *Client) {
+		client.room.welcomeBack(client, lastSeq, hasLastSeq)
+	})
+}
+
+// Given a roomCode and playerName, adds the player to the lobby as a spectator and upgrades their connection to a websocket. Spectators are sent the
+// lobby info (and, if the game has started, a GameState showing only what spectators are allowed to see), then every message sent to the lobby from then on,
+// but can't take any actions. They're removed from the lobby as soon as their connection closes
+func (hub *Hub) HandleSpectateLobby(w http.ResponseWriter, r *http.Request) {
+	roomCode := r.URL.Query().Get("roomCode")
+	playerName := r.URL.Query().Get("playerName")
+
+	if roomCode == "" || playerName == "" {
+		httpError(w, http.StatusBadRequest, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Please provide roomCode and playerName"))
 		return
 	}
 
-	//Send LobbyInfo
-	msg := WebsocketMessage{
+	if !checkPassword(w, roomCode, r.URL.Query().Get("password")) {
+		return
+	}
+
+	_, spectatorId, err := Engine.SpectateRoom(roomCode, playerName, r.URL.Query().Get("playerId"))
+	if err != nil {
+		log.Printf("Error spectating room: %v\n", err)
+		httpError(w, http.StatusNotFound, err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		Engine.LeaveAsSpectator(roomCode, spectatorId)
+		http.Error(w, "WebSocket upgrade failed", http.StatusInternalServerError)
+		return
+	}
+
+	connected := hub.connect(roomCode, spectatorId, true, conn, func(client *Client) {
+		client.room.welcomeSpectator(client)
+	})
+	if !connected {
+		Engine.LeaveAsSpectator(roomCode, spectatorId)
+		return
+	}
+
+	//Let everyone else know someone has started watching
+	lobbyInfo, err := Engine.LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		log.Printf("error connecting spectator: {%s}", err)
+		return
+	}
+	publishToRoom(roomCode, roomEnvelope{
+		Message: &WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: lobbyInfo}},
+	}, true)
+}
+
+// Queues the first messages for a new spectator: the lobby info, and the spectator's view of the GameState if the game has started. Both are numbered
+// with the Room's latest sequence number. Must be run on the Room's event loop
+func (room *Room) welcomeSpectator(client *Client) {
+	seq, err := Engine.CurrentRoomSeq(room.roomCode)
+	if err != nil {
+		log.Printf("Error getting latest sequence number for room {%s}: %s", room.roomCode, err)
+	}
+
+	lobbyInfo, err := Engine.LoadLobbyFromRedis(room.roomCode)
+	if err != nil {
+		client.preload(WebsocketMessage{Type: WebsocketMessage_Error, Data: newSocketError(err)})
+		return
+	}
+	client.preload(WebsocketMessage{
 		Type: WebsocketMessage_LobbyInfo,
 		Data: LobbyInfo{
-			PlayerID:  playerId,
+			PlayerID:  client.playerId,
 			LobbyInfo: lobbyInfo,
 		},
-	}
-	conn.WriteJSON(msg)
+		Seq: seq,
+	})
 
-	//If the game has started, send a GameState
 	if lobbyInfo.Status == Session.LobbyStatus_InProgress {
-		log.Println("Game has been marked as 'In Progress' - Sending GameState...")
 		gameState, err := Engine.GetCachedGameStateFromRedis(lobbyInfo.GameStateId)
 		if err != nil {
-			conn.WriteJSON(WebsocketMessage{
-				Type: WebsocketMessage_Error,
-				Data: SocketError{Message: err.Error()},
-			})
+			client.preload(WebsocketMessage{Type: WebsocketMessage_Error, Data: newSocketError(err)})
+			return
 		}
-		conn.WriteJSON(WebsocketMessage{Type: WebsocketMessage_GameState, Data: gameState})
+		client.preload(WebsocketMessage{Type: WebsocketMessage_GameState, Data: gameState.ForSpectators(), Seq: seq})
 	}
 
-	log.Printf("Player {%s} has been given first message(s). Beginning to track connection for further communication...", playerId)
+	room.sendChatHistory(client)
+}
 
-	//Store the connection, give the connection its own goroutine, and begin listening for more messages
-	gamesClientsMutex.Lock()
-	gamesClients[roomCode][playerId] = conn
-	room := gamesClients[roomCode]
-	gamesClientsMutex.Unlock()
+// Queues the first message(s) for a rejoining [client]. If [hasLastSeq] is set and every message after [lastSeq] is still buffered, the client
+// is sent just those messages. Otherwise, they're sent a LobbyInfo and, if the game has started, a GameState, both numbered with the Room's
+// latest sequence number so the client can pick up from there. Must be run on the Room's event loop
+func (room *Room) welcomeBack(client *Client, lastSeq int64, hasLastSeq bool) {
+	if hasLastSeq {
+		missed, complete, err := Engine.GetRoomMessagesSince(room.roomCode, lastSeq)
+		if err == nil && complete {
+			log.Printf("Replaying %d missed message(s) to player {%s}", len(missed), client.playerId)
+			for _, roomMessage := range missed {
+				envelope := roomEnvelope{}
+				if err := json.Unmarshal(roomMessage.Message, &envelope); err != nil || envelope.Message == nil {
+					log.Printf("Error decoding buffered message {%d} for room {%s}: %s", roomMessage.Seq, room.roomCode, err)
+					continue
+				}
+				message := *envelope.Message
+				message.Seq = roomMessage.Seq
+				client.preload(message)
+			}
+			room.sendChatHistory(client)
+			return
+		}
+		log.Printf("Can't replay messages after {%d} to player {%s}. Sending full state instead", lastSeq, client.playerId)
+	}
 
-	go manageClient(roomCode, room, playerId, conn)
-}
+	seq, err := Engine.CurrentRoomSeq(room.roomCode)
+	if err != nil {
+		log.Printf("Error getting latest sequence number for room {%s}: %s", room.roomCode, err)
+	}
 
-// handShake sends out the lobby info to everyone currently in the room, along with the
-// name of the freshly joined player
-func handShake(roomCode string, newPlayerId string) {
-	gamesClientsMutex.Lock()
-	room := gamesClients[roomCode]
-	gamesClientsMutex.Unlock()
-	jsonLobby, err := Engine.LoadLobbyFromRedis(roomCode)
+	lobbyInfo, err := Engine.LoadLobbyFromRedis(room.roomCode)
+	if err != nil {
+		client.preload(WebsocketMessage{
+			Type: WebsocketMessage_Error,
+			Data: newSocketError(err),
+		})
+		return
+	}
 
-	msg := WebsocketMessage{
+	//Send LobbyInfo
+	client.preload(WebsocketMessage{
 		Type: WebsocketMessage_LobbyInfo,
 		Data: LobbyInfo{
-			PlayerID:  newPlayerId,
-			LobbyInfo: jsonLobby,
+			PlayerID:  client.playerId,
+			LobbyInfo: lobbyInfo,
 		},
-	}
-	for playerId, conn := range room {
-		if err != nil {
-			log.Printf("error connecting: {%s}", err)
-		}
-		err := conn.WriteJSON(msg)
+		Seq: seq,
+	})
+
+	//If the game has started, send a GameState
+	if lobbyInfo.Status == Session.LobbyStatus_InProgress {
+		log.Println("Game has been marked as 'In Progress' - Sending GameState...")
+		gameState, err := Engine.GetCachedGameStateFromRedis(lobbyInfo.GameStateId)
 		if err != nil {
-			log.Println("Error sending handshake, aborting connection ", playerId)
-			gamesClientsMutex.Lock()
-			room[playerId].Close()
-			delete(room, playerId)
-			gamesClientsMutex.Unlock()
-			continue // I don't handle disconnection rn
+			client.preload(WebsocketMessage{
+				Type: WebsocketMessage_Error,
+				Data: newSocketError(err),
+			})
 		}
+		client.preload(WebsocketMessage{Type: WebsocketMessage_GameState, Data: gameState, Seq: seq})
 	}
 
-	//Last thing we need to do is start listening for messages from this player
-	go manageClient(roomCode, room, newPlayerId, room[newPlayerId])
+	room.sendChatHistory(client)
 }
 
-func manageClient(lobbyCode string, room map[string]*websocket.Conn, playerId string, conn *websocket.Conn) {
-	defer socketRecovery(room, playerId)
+// Queues the chat messages [client] is allowed to see, so a rejoining player can catch up on what was said while they were gone
+func (room *Room) sendChatHistory(client *Client) {
+	history, err := Engine.GetChatHistory(room.roomCode, client.playerId)
+	if err != nil {
+		log.Printf("Error getting chat history for room {%s}: %s", room.roomCode, err)
+		return
+	}
+	client.preload(WebsocketMessage{Type: WebsocketMessage_ChatHistory, Data: ChatHistory{Messages: history}})
+}
 
-	log.Printf("Managing Connection for playerId %s and waiting for message", playerId)
-	_, msg, err := conn.ReadMessage()
+// handShake sends out the lobby info to everyone currently in the room, along with the
+// name of the freshly joined player
+func handShake(roomCode string, newPlayerId string) {
+	jsonLobby, err := Engine.LoadLobbyFromRedis(roomCode)
 	if err != nil {
-		//Disconnect client by closing connection and removing player from lobby
-		gamesClientsMutex.Lock()
-		room[playerId].Close()
-		delete(room, playerId)
-		gamesClientsMutex.Unlock()
+		log.Printf("error connecting: {%s}", err)
 	}
 
-	processMessage(lobbyCode, playerId, msg)
+	publishToRoom(roomCode, roomEnvelope{
+		Message: &WebsocketMessage{
+			Type: WebsocketMessage_LobbyInfo,
+			Data: LobbyInfo{
+				PlayerID:  newPlayerId,
+				LobbyInfo: jsonLobby,
+			},
+		},
+	}, true)
 }
 
-// This gets called on loop per lobby
-func processMessage(roomCode string, playerId string, message []byte) {
+// Handles a single message sent by [client]. Called by the Room's event loop, so messages within a Room are always processed one at a time, in the order they arrived
+func (room *Room) processMessage(client *Client, message []byte) {
+	roomCode := room.roomCode
+	playerId := client.playerId
 
 	var msg struct {
-		JsonType string          `json:"jsonType"`
-		Data     json.RawMessage `json:"data"` //Raw message delays the parsing
+		JsonType string `json:"jsonType"`
+		//Optional. Echoed back on any reply sent only to this client (Errors, ActionAccepted) so it can tell which message the reply is for
+		RequestId string          `json:"requestId"`
+		Data      json.RawMessage `json:"data"` //Raw message delays the parsing
 	}
 	json.Unmarshal(message, &msg)
 
-	gamesClientsMutex.Lock()
-	room := gamesClients[roomCode]
-	gamesClientsMutex.Unlock()
+	//Spectators can only watch
+	if client.spectator && msg.JsonType != "disconnect" {
+		room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_NotAPlayer, "Spectators can only watch the game!"))
+		return
+	}
 
 	switch msg.JsonType {
 	case "startGame":
-		game, err := Engine.GetInitialGameState(roomCode)
+		options := Engine.StartOptions{}
+		if len(msg.Data) > 0 {
+			if err := json.Unmarshal(msg.Data, &options); err != nil {
+				log.Printf("Error trying to unmarshal startGame options: {%s}. Starting with default options", err)
+				options = Engine.StartOptions{}
+			}
+		}
+
+		game, err := Engine.GetInitialGameState(roomCode, playerId, options)
 		if err != nil {
 			log.Printf("ERROR: GAME NOT STARTED, ABORTING...%s", err)
-			return
+			room.sendError(playerId, msg.RequestId, err)
+			break
 		}
 
-		sendMessageToAllPlayers(room, WebsocketMessage{Type: WebsocketMessage_GameState, Data: game})
+		room.sendToAllProjected(WebsocketMessage{Type: WebsocketMessage_GameState, Data: game}, WebsocketMessage{Type: WebsocketMessage_GameState, Data: game.ForSpectators()})
+		room.runBots(game.Id)
 	case "endGame":
-		err := Engine.EndGame(roomCode, playerId)
+		//Anyone still waiting for a seat has nothing left to wait for
+		dbLobby, _ := Engine.LoadLobbyFromRedis(roomCode)
+
+		updatedLobby, err := Engine.EndGame(roomCode, playerId)
 		if err != nil {
 			log.Printf("ERROR: Trying to end game...%s", err)
-			return
+			room.sendError(playerId, msg.RequestId, err)
+			break
 		}
-		cleanUpRoom(room, roomCode)
+
+		for _, request := range dbLobby.SeatRequests {
+			room.closePlayer(request.Player.Id, "The game has ended. Closing connection")
+		}
+
+		//Everyone stays connected so the host can start a rematch
+		room.sendToAll(WebsocketMessage{
+			Type: WebsocketMessage_GameOver,
+			Data: GameOver{}, //Maybe put the final GameState here?
+		})
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "rematch":
+		var rematch struct {
+			GameId string `json:"gameId"`
+		}
+		if len(msg.Data) > 0 {
+			if err := json.Unmarshal(msg.Data, &rematch); err != nil {
+				log.Printf("Error trying to unmarshal rematch request: {%s}", err)
+				room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. If set, 'gameId' in the message object's 'Data' field must be a string!"))
+				break
+			}
+		}
+
+		updatedLobby, removed, err := Engine.Rematch(roomCode, playerId, rematch.GameId)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		for _, spectator := range removed {
+			room.closePlayer(spectator.Id, "The next game doesn't allow spectators. Closing connection")
+		}
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
 	case "submitAction":
 		var action struct {
 			GameId string                  `json:"gameId"`
@@ -299,13 +494,7 @@ func processMessage(roomCode string, playerId string, message []byte) {
 		}
 		if err := json.Unmarshal(msg.Data, &action); err != nil {
 			log.Printf("error decoding submitAction: {%s}", err)
-			socketError := WebsocketMessage{
-				Type: WebsocketMessage_Error,
-				Data: SocketError{
-					Message: err.Error(),
-				},
-			}
-			room[playerId].WriteJSON(socketError)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'action' is found in message object's 'Data' field!"))
 			break
 		}
 
@@ -315,45 +504,141 @@ func processMessage(roomCode string, playerId string, message []byte) {
 		changelog, err := Engine.SubmitAction(action.GameId, action.Action)
 		if err != nil {
 			log.Printf("error with submitAction: {%s}", err)
-			socketError := WebsocketMessage{
-				Type: WebsocketMessage_Error,
-				Data: SocketError{
-					Message: err.Error(),
-				},
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		//Spectators may not be allowed to see every View in the Changelog. If we can't tell which ones they can see, they're sent none of them
+		gameState, err := Engine.GetCachedGameStateFromRedis(action.GameId)
+		if err != nil {
+			log.Printf("error loading gameState to project changelog for spectators: {%s}", err)
+		}
+		changelogSeq := room.sendToAllProjected(WebsocketMessage{Type: WebsocketMessage_Changelog, Data: changelog}, WebsocketMessage{Type: WebsocketMessage_Changelog, Data: changelog.ForSpectators(gameState)})
+		room.sendTo(playerId, WebsocketMessage{
+			Type:      WebsocketMessage_ActionAccepted,
+			Data:      ActionAccepted{ChangelogSeq: changelogSeq},
+			RequestId: msg.RequestId,
+		})
+		room.runBots(action.GameId)
+	case "commitAction":
+		var action struct {
+			GameId string                  `json:"gameId"`
+			Action Session.SubmittedAction `json:"action"`
+		}
+		if err := json.Unmarshal(msg.Data, &action); err != nil {
+			log.Printf("error decoding commitAction: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'action' is found in message object's 'Data' field!"))
+			break
+		}
+
+		//Supply PlayerId with the Id of the player belonging to this connection
+		action.Action.PlayerId = playerId
+
+		_, revealed, err := Engine.CommitAction(action.GameId, action.Action)
+		if err != nil {
+			log.Printf("error with commitAction: {%s}", err)
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		//Nothing is broadcast until the round is revealed, besides who has committed (sent by runBots)
+		var changelogSeq int64
+		if revealed != nil {
+			gameState, err := Engine.GetCachedGameStateFromRedis(action.GameId)
+			if err != nil {
+				log.Printf("error loading gameState to project changelog for spectators: {%s}", err)
 			}
-			room[playerId].WriteJSON(socketError)
+			changelogSeq = room.sendToAllProjected(WebsocketMessage{Type: WebsocketMessage_Changelog, Data: *revealed}, WebsocketMessage{Type: WebsocketMessage_Changelog, Data: revealed.ForSpectators(gameState)})
+		}
+		room.sendTo(playerId, WebsocketMessage{
+			Type:      WebsocketMessage_ActionAccepted,
+			Data:      ActionAccepted{ChangelogSeq: changelogSeq},
+			RequestId: msg.RequestId,
+		})
+		room.runBots(action.GameId)
+	case "getLegalActions":
+		var request struct {
+			GameId string `json:"gameId"`
+		}
+		if err := json.Unmarshal(msg.Data, &request); err != nil {
+			log.Printf("error decoding getLegalActions: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'gameId' is found in message object's 'Data' field!"))
 			break
 		}
 
-		sendMessageToAllPlayers(room, WebsocketMessage{Type: WebsocketMessage_Changelog, Data: changelog})
-	case "leaveLobby":
-		updatedLobby, err := endPlayerConnection(roomCode, playerId, room)
+		actions, err := Engine.GetLegalActions(request.GameId, playerId)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		room.sendTo(playerId, WebsocketMessage{
+			Type:      WebsocketMessage_LegalActions,
+			Data:      LegalActions{Actions: actions},
+			RequestId: msg.RequestId,
+		})
+	case "answerSeatRequest":
+		var answer struct {
+			PlayerId string `json:"playerId"`
+			Approve  bool   `json:"approve"`
+		}
+		if err := json.Unmarshal(msg.Data, &answer); err != nil {
+			log.Printf("Error trying to unmarshal answerSeatRequest request: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure fields 'playerId' and 'approve' are found in message object's 'Data' field!"))
+			break
+		}
 
+		updatedLobby, gameState, declined, err := Engine.AnswerSeatRequest(roomCode, playerId, answer.PlayerId, answer.Approve)
 		if err != nil {
-			socketError := WebsocketMessage{
-				Type: WebsocketMessage_Error,
-				Data: SocketError{
-					Message: err.Error(),
-				},
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		for _, declinedId := range declined {
+			room.closePlayer(declinedId, "The host has declined your request to take over a seat. Closing connection")
+		}
+		if gameState != nil {
+			//Seat them before the GameState goes out, so they're sent the player's version of it
+			room.seatPlayer(answer.PlayerId)
+			room.sendToAllProjected(WebsocketMessage{Type: WebsocketMessage_GameState, Data: *gameState}, WebsocketMessage{Type: WebsocketMessage_GameState, Data: gameState.ForSpectators()})
+		}
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "addBot":
+		var bot struct {
+			Strategy string `json:"strategy"`
+			Name     string `json:"name"`
+		}
+		if len(msg.Data) > 0 {
+			if err := json.Unmarshal(msg.Data, &bot); err != nil {
+				log.Printf("Error trying to unmarshal addBot request: {%s}", err)
+				room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure fields 'strategy' and 'name' are strings if given!"))
+				break
 			}
-			room[playerId].WriteJSON(socketError)
+		}
+
+		updatedLobby, err := Engine.AddBot(roomCode, playerId, bot.Strategy, bot.Name)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "leaveLobby":
+		updatedLobby, err := room.endPlayerConnection(playerId)
+
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
 			break
 		}
 
-		sendMessageToAllPlayers(room, WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
 	case "kickPlayer":
 		var action struct {
 			PlayerToKick string `json:"playerToKick"`
 		}
 		if err := json.Unmarshal(msg.Data, &action); err != nil {
 			log.Printf("Error trying to unmarshal kick request into struct with field 'playerToKick' ... Please ensure field exists in Data")
-			socketError := WebsocketMessage{
-				Type: WebsocketMessage_Error,
-				Data: SocketError{
-					Message: "Message is malformed. Please ensure field 'playerToKick' is found in message object's 'Data' field!",
-				},
-			}
-			room[playerId].WriteJSON(socketError)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'playerToKick' is found in message object's 'Data' field!"))
 			break
 		}
 
@@ -361,145 +646,271 @@ func processMessage(roomCode string, playerId string, message []byte) {
 
 		if err != nil {
 			log.Printf("Error trying to find lobby")
-			socketError := WebsocketMessage{
-				Type: WebsocketMessage_Error,
-				Data: SocketError{
-					Message: "Could not find lobby. Something has gone terribly wrong",
-				},
-			}
-			room[playerId].WriteJSON(socketError)
+			room.sendError(playerId, msg.RequestId, err)
 			break
 		}
 
 		if dbLobby.Host.Id != playerId {
-			socketError := WebsocketMessage{
-				Type: WebsocketMessage_Error,
-				Data: SocketError{
-					Message: "Player submitting kick request is not the host of the lobby!",
-				},
-			}
-			room[playerId].WriteJSON(socketError)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_NotHost, "Player submitting kick request is not the host of the lobby!"))
 			break
 		}
 
-		updatedLobby, err := endPlayerConnection(roomCode, action.PlayerToKick, room)
+		updatedLobby, err := room.endPlayerConnection(action.PlayerToKick)
 
 		if err != nil {
-			socketError := WebsocketMessage{
-				Type: WebsocketMessage_Error,
-				Data: SocketError{
-					Message: err.Error(),
-				},
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "banPlayer":
+		var ban struct {
+			PlayerId string `json:"playerId"`
+		}
+		if err := json.Unmarshal(msg.Data, &ban); err != nil {
+			log.Printf("Error trying to unmarshal banPlayer request: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'playerId' is found in message object's 'Data' field!"))
+			break
+		}
+
+		if err := Engine.BanPlayer(roomCode, playerId, ban.PlayerId); err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		updatedLobby, err := room.endPlayerConnection(ban.PlayerId)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "voteKick":
+		vote := struct {
+			PlayerId string `json:"playerId"`
+			Vote     bool   `json:"vote"`
+		}{Vote: true}
+		if err := json.Unmarshal(msg.Data, &vote); err != nil {
+			log.Printf("Error trying to unmarshal voteKick request: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'playerId' is found in message object's 'Data' field!"))
+			break
+		}
+
+		updatedLobby, kicked, err := Engine.VoteKick(roomCode, playerId, vote.PlayerId, vote.Vote)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		if kicked {
+			updatedLobby, err = room.endPlayerConnection(vote.PlayerId)
+			if err != nil {
+				room.sendError(playerId, msg.RequestId, err)
+				break
 			}
-			room[playerId].WriteJSON(socketError)
+		}
+
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "transferHost":
+		var transfer struct {
+			NewHost string `json:"newHost"`
+		}
+		if err := json.Unmarshal(msg.Data, &transfer); err != nil {
+			log.Printf("Error trying to unmarshal transferHost request: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'newHost' is found in message object's 'Data' field!"))
 			break
 		}
 
-		sendMessageToAllPlayers(room, WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
-	case "disconnect":
-		log.Printf("Player %s is requesting a disconnect!", playerId)
-		conn := room[playerId]
-		msg := WebsocketMessage{
-			Type: WebsocketMessage_Close,
-			Data: SocketClose{
-				Message: "Request acknowledged, closing connection",
-			},
+		updatedLobby, err := Engine.TransferHost(roomCode, playerId, transfer.NewHost)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "setReady":
+		var readiness struct {
+			Ready bool `json:"ready"`
+		}
+		if err := json.Unmarshal(msg.Data, &readiness); err != nil {
+			log.Printf("Error trying to unmarshal setReady request: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'ready' is found in message object's 'Data' field!"))
+			break
+		}
+
+		updatedLobby, err := Engine.SetPlayerReady(roomCode, playerId, readiness.Ready)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "arrangeSeats":
+		var arrangement struct {
+			SeatingOrder    string   `json:"seatingOrder"`
+			SeatArrangement []string `json:"seatArrangement"`
+		}
+		if err := json.Unmarshal(msg.Data, &arrangement); err != nil {
+			log.Printf("Error trying to unmarshal arrangeSeats request: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'seatingOrder' is found in message object's 'Data' field!"))
+			break
+		}
+
+		updatedLobby, err := Engine.ArrangeSeats(roomCode, playerId, arrangement.SeatingOrder, arrangement.SeatArrangement)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "updateLobbySettings":
+		settings := Engine.LobbySettings{}
+		if err := json.Unmarshal(msg.Data, &settings); err != nil {
+			log.Printf("Error trying to unmarshal updateLobbySettings request: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure the settings to change are found in message object's 'Data' field!"))
+			break
+		}
+
+		updatedLobby, removed, err := Engine.UpdateLobbySettings(roomCode, playerId, settings)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		for _, spectator := range removed {
+			room.closePlayer(spectator.Id, "The lobby's new game doesn't allow spectators. Closing connection")
+		}
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "chat":
+		var chat struct {
+			Message string `json:"message"`
+			To      string `json:"to"`
+		}
+		if err := json.Unmarshal(msg.Data, &chat); err != nil {
+			log.Printf("Error trying to unmarshal chat request: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'message' is found in message object's 'Data' field!"))
+			break
+		}
+
+		chatMessage, err := Engine.SendChatMessage(roomCode, playerId, chat.To, chat.Message)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		//Private messages go only to the recipient, plus the sender so they can see what they sent
+		if chatMessage.To == "" {
+			room.sendToAll(WebsocketMessage{Type: WebsocketMessage_ChatMessage, Data: chatMessage})
+		} else {
+			room.sendToPlayer(chatMessage.To, WebsocketMessage{Type: WebsocketMessage_ChatMessage, Data: chatMessage})
+			room.sendTo(playerId, WebsocketMessage{Type: WebsocketMessage_ChatMessage, Data: chatMessage, RequestId: msg.RequestId})
+		}
+	case "mutePlayer":
+		var mute struct {
+			PlayerId string `json:"playerId"`
+			Muted    bool   `json:"muted"`
+		}
+		if err := json.Unmarshal(msg.Data, &mute); err != nil {
+			log.Printf("Error trying to unmarshal mutePlayer request: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'playerId' is found in message object's 'Data' field!"))
+			break
 		}
 
-		conn.WriteJSON(msg)
-		log.Println("Close message has been sent, closing connection")
-		conn.Close()
-		delete(room, playerId)
-		log.Println("Connection closed and server has stopped tracking websocket connection")
-		return //Return so we don't go back into manageClient
+		updatedLobby, err := Engine.SetPlayerMuted(roomCode, playerId, mute.PlayerId, mute.Muted)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "setSpectatorsAllowed":
+		var spectating struct {
+			Allowed bool `json:"allowed"`
+		}
+		if err := json.Unmarshal(msg.Data, &spectating); err != nil {
+			log.Printf("Error trying to unmarshal setSpectatorsAllowed request: {%s}", err)
+			room.sendError(playerId, msg.RequestId, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Message is malformed. Please ensure field 'allowed' is found in message object's 'Data' field!"))
+			break
+		}
+
+		updatedLobby, removed, err := Engine.SetSpectatorsAllowed(roomCode, playerId, spectating.Allowed)
+		if err != nil {
+			room.sendError(playerId, msg.RequestId, err)
+			break
+		}
+
+		for _, spectator := range removed {
+			room.closePlayer(spectator.Id, "The host has turned off spectating. Closing connection")
+		}
+		room.sendToAll(WebsocketMessage{Type: WebsocketMessage_LobbyInfo, Data: LobbyInfo{PlayerID: "", LobbyInfo: updatedLobby}})
+	case "disconnect":
+		log.Printf("Player %s is requesting a disconnect!", playerId)
+		room.closeClient(client, "Request acknowledged, closing connection")
+		room.lostConnection(client)
+		log.Println("Server has stopped tracking websocket connection. It will be closed once the Close message has been sent")
 	default:
 		log.Println("Unknown type sent, ignoring message recieved", msg)
 	}
-
-	//Listen for the next message from this client. Not using `go manageClient(...)` because this is already happening in a goroutine
-	manageClient(roomCode, room, playerId, room[playerId])
 }
 
-func endPlayerConnection(roomCode string, playerId string, room map[string]*websocket.Conn) (Session.Lobby, error) {
+// Removes [playerId] from the lobby and, if they have an open connection, tells them it's closing before closing it. If they were in the middle of a
+// game, everyone is sent the Changelog of what happened to their seat. Must be run on the Room's event loop
+func (room *Room) endPlayerConnection(playerId string) (Session.Lobby, error) {
 	//Tell the engine to remove the player from the DB copy of the lobby
-	updatedLobby, err := Engine.LeaveRoom(roomCode, playerId)
+	updatedLobby, changelog, err := Engine.LeaveRoom(room.roomCode, playerId)
 	if err != nil {
 		return Session.Lobby{}, err
 	}
 
-	//If the removed client has a currently open connection, tell that the client that the connection is closing, then close connection
-	if conn, exists := room[playerId]; exists {
-		msg := WebsocketMessage{
-			Type: WebsocketMessage_Close,
-			Data: SocketClose{
-				Message: "Player has been removed from Lobby. Closing connection",
-			},
+	if changelog != nil {
+		gameState, err := Engine.GetCachedGameStateFromRedis(updatedLobby.GameStateId)
+		if err != nil {
+			log.Printf("error loading gameState to project changelog for spectators: {%s}", err)
 		}
-		conn.WriteJSON(msg)
-		conn.Close()
-		//Remove connection from lobby map so we don't try to send them any more messages
-		delete(room, playerId)
+		room.sendToAllProjected(WebsocketMessage{Type: WebsocketMessage_Changelog, Data: *changelog}, WebsocketMessage{Type: WebsocketMessage_Changelog, Data: changelog.ForSpectators(gameState)})
+		//Their turn may have passed to a bot, or a bot may have taken over their seat
+		room.runBots(updatedLobby.GameStateId)
 	}
 
+	//If the removed client has a currently open connection (on any server), tell that the client that the connection is closing, then stop tracking it so we don't try to send them any more messages
+	room.closePlayer(playerId, "Player has been removed from Lobby. Closing connection")
+	room.forgetPlayer(playerId)
+
 	return updatedLobby, nil
 }
 
-func cleanUpRoom(room map[string]*websocket.Conn, roomCode string) {
-	gameOverMessage := WebsocketMessage{
-		Type: WebsocketMessage_GameOver,
-		Data: GameOver{}, //Maybe put the final GameState here?
-	}
-	closeMessage := WebsocketMessage{
-		Type: WebsocketMessage_Close,
-		Data: SocketClose{
-			Message: "Game has ended. Closing connection",
-		},
+// Lets every bot whose turn it is in the GameState with [gameId] play (or commit, in simultaneous rounds), then sends everyone the Changelog of each
+// action they took and how the round is going, and starts the timer on whoever's turn it is afterwards. Must be run on the Room's event loop
+func (room *Room) runBots(gameId string) {
+	changelogs, err := Engine.RunBotTurns(gameId)
+	if err != nil {
+		log.Printf("error letting bots play in room {%s}: {%s}", room.roomCode, err)
 	}
 
-	//Send the messages to every player
-	for playerId, conn := range room {
-		err := conn.WriteJSON(gameOverMessage)
-		if err != nil {
-			log.Printf("Error sending GameOver Message to %s. Aborting message, but closing connection anyways", playerId)
-		}
-		err = conn.WriteJSON(closeMessage)
-		if err != nil {
-			log.Printf("Error sending Close Message to %s. Aborting message, but closing connection anyways", playerId)
-		}
-		conn.Close()
-		delete(room, playerId)
+	gameState, err := Engine.GetCachedGameStateFromRedis(gameId)
+	if err != nil {
+		log.Printf("error loading gameState to project changelog for spectators: {%s}", err)
+		return
 	}
-
-	//Clean up the gamesClients map
-	gamesClientsMutex.Lock()
-	delete(gamesClients, roomCode)
-	gamesClientsMutex.Unlock()
-}
-
-func sendMessageToAllPlayers(room map[string]*websocket.Conn, message WebsocketMessage) {
-	//log.Printf("Sending message to every player: %s", message)
-
-	if message.Type == "" {
-		log.Println("WARNING: Websocket message being sent has no Type set! Frontend will likely not know how to handle the message!")
+	for _, changelog := range changelogs {
+		room.sendToAllProjected(WebsocketMessage{Type: WebsocketMessage_Changelog, Data: changelog}, WebsocketMessage{Type: WebsocketMessage_Changelog, Data: changelog.ForSpectators(gameState)})
 	}
 
-	for playerId, conn := range room {
-		err := conn.WriteJSON(message)
-		if err != nil {
-			log.Println("Error sending message, skipping meesage to ", playerId)
-			continue // I don't handle disconnection rn
-		}
-	}
+	publishRoundProgress(room.roomCode, gameState)
+	//Whoever's turn it is now may be on the clock
+	room.scheduleTurnTimer(gameState)
 }
 
-// Defer this function whenever you try to read from a socket. If ReadMessage panics, this will kick in. Note: This must be set up (deferred) **BEFORE** calling ReadMessage
-func socketRecovery(room map[string]*websocket.Conn, playerId string) {
-	if r := recover(); r != nil {
-		log.Printf("Something went wrong trying to read from the connection of Player, likely due to an unexpected closing of the Websocket connection: {%s} -- %s", playerId, r)
-		//Disconnect client by closing connection and removing player from lobby
-		gamesClientsMutex.Lock()
-		//lobby[playerId].Close() Assume the socket is already closed if we're here
-		delete(room, playerId)
-		gamesClientsMutex.Unlock()
+// Tells everyone in the room with [roomCode] who has and hasn't committed to an action this round, if [gameState] is played in simultaneous rounds.
+// Safe to call from anywhere
+func publishRoundProgress(roomCode string, gameState Session.GameState) {
+	if !gameState.Rules.SimultaneousRounds {
+		return
+	}
+	progress, err := Engine.GetRoundProgress(gameState.Id)
+	if err != nil {
+		log.Printf("error loading round progress of room {%s}: {%s}", roomCode, err)
+		return
 	}
+	publishToRoom(roomCode, roomEnvelope{Message: &WebsocketMessage{Type: WebsocketMessage_RoundProgress, Data: progress}}, true)
 }
@@ -0,0 +1,135 @@
+package Lobby
+
+import (
+	"candlelight-models/Session"
+	"candlelight-ruleengine/Engine"
+	"log"
+	"math"
+	"time"
+)
+
+// A turn timer that has gone off, waiting to be acted on by its Room's event loop
+type turnAlarm struct {
+	//The deadline of the turn the timer was set for
+	deadline time.Time
+	//Whether this is the warning before the deadline, rather than the deadline itself
+	warning bool
+}
+
+// Makes sure the Room's turn timers are set for whoever's turn it is in [gameState]: a TurnWarning shortly before their deadline, then the end of
+// their turn. Timers are only replaced when the deadline changes, so this can be called after every action. Must be run on the Room's event loop
+func (room *Room) scheduleTurnTimer(gameState Session.GameState) {
+	if gameState.TurnDeadline != nil && gameState.TurnDeadline.Equal(room.turnDeadline) {
+		return
+	}
+
+	for _, timer := range room.turnTimers {
+		timer.Stop()
+	}
+	room.turnTimers = nil
+	room.turnDeadline = time.Time{}
+	if gameState.TurnDeadline == nil {
+		return
+	}
+
+	room.turnDeadline = *gameState.TurnDeadline
+	room.turnTimers = room.hub.setTurnTimers(room.roomCode, gameState)
+}
+
+// Starts the timers for the turn in [gameState] in the room with [roomCode], and returns them. Each one goes off on whichever Room this server has
+// for [roomCode] at the time (see soundTurnAlarm)
+func (hub *Hub) setTurnTimers(roomCode string, gameState Session.GameState) []*time.Timer {
+	deadline := *gameState.TurnDeadline
+	return []*time.Timer{
+		time.AfterFunc(time.Until(deadline.Add(-Engine.TurnWarningLead(gameState.Rules))), func() {
+			hub.soundTurnAlarm(roomCode, turnAlarm{deadline: deadline, warning: true})
+		}),
+		time.AfterFunc(time.Until(deadline), func() { hub.soundTurnAlarm(roomCode, turnAlarm{deadline: deadline}) }),
+	}
+}
+
+// Hands [alarm] to this server's Room for [roomCode], so the turn is ended in between anything its players send rather than alongside it. If nobody
+// on this server is in the room anymore, there's nothing here to get in the way of, but anyone playing on another server still needs their turn to
+// end. So the alarm is acted on straight away, and the next turn's timers are set without a Room
+func (hub *Hub) soundTurnAlarm(roomCode string, alarm turnAlarm) {
+	hub.roomsMutex.Lock()
+	room, exists := hub.rooms[roomCode]
+	hub.roomsMutex.Unlock()
+
+	if exists {
+		select {
+		case room.turnAlarms <- alarm:
+			return
+		case <-room.done:
+		}
+	}
+
+	if next := alarm.sound(roomCode); next != nil && next.TurnDeadline != nil {
+		hub.setTurnTimers(roomCode, *next)
+	}
+}
+
+// Warns about or ends the turn [alarm] was set for in the room with [roomCode]. If the turn was ended, returns the GameState afterwards so that the
+// next turn's timers can be set
+func (alarm turnAlarm) sound(roomCode string) *Session.GameState {
+	if alarm.warning {
+		warnTurn(roomCode, alarm.deadline)
+		return nil
+	}
+	return timeOutTurn(roomCode, alarm.deadline)
+}
+
+// Warns the player whose turn is due to end at [deadline] in the room with [roomCode] that they're nearly out of time, if it's still their turn. In a
+// game played in simultaneous rounds, everyone who hasn't committed yet is warned
+func warnTurn(roomCode string, deadline time.Time) {
+	playerIds, err := Engine.ClaimTurnWarning(roomCode, deadline)
+	if err != nil {
+		log.Printf("Error warning the current player of room {%s}: %s", roomCode, err)
+		return
+	}
+
+	secondsLeft := int(math.Ceil(time.Until(deadline).Seconds()))
+	for _, playerId := range playerIds {
+		publishToRoom(roomCode, roomEnvelope{
+			Target:  playerId,
+			Message: &WebsocketMessage{Type: WebsocketMessage_TurnWarning, Data: TurnWarning{Deadline: deadline, SecondsLeft: max(secondsLeft, 0)}},
+		}, false)
+	}
+}
+
+// Ends the turn due to end at [deadline] in the room with [roomCode], if it still hasn't, then lets any bots that are next play. Returns the GameState
+// afterwards, or nil if the turn had already ended
+func timeOutTurn(roomCode string, deadline time.Time) *Session.GameState {
+	changelogs, err := Engine.TimeOutTurn(roomCode, deadline)
+	if err != nil {
+		log.Printf("Error ending the current turn of room {%s}: %s", roomCode, err)
+	}
+	if len(changelogs) == 0 {
+		return nil
+	}
+
+	lobby, err := Engine.LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		log.Printf("Error loading lobby {%s} after ending its current turn: %s", roomCode, err)
+		return nil
+	}
+	played, err := Engine.RunBotTurns(lobby.GameStateId)
+	if err != nil {
+		log.Printf("error letting bots play in room {%s}: {%s}", roomCode, err)
+	}
+	changelogs = append(changelogs, played...)
+
+	gameState, err := Engine.GetCachedGameStateFromRedis(lobby.GameStateId)
+	if err != nil {
+		log.Printf("error loading gameState to project changelog for spectators: {%s}", err)
+		return nil
+	}
+	for _, changelog := range changelogs {
+		message := WebsocketMessage{Type: WebsocketMessage_Changelog, Data: changelog}
+		spectatorMessage := WebsocketMessage{Type: WebsocketMessage_Changelog, Data: changelog.ForSpectators(gameState)}
+		publishToRoom(roomCode, roomEnvelope{Message: &message, SpectatorMessage: &spectatorMessage}, true)
+	}
+
+	publishRoundProgress(roomCode, gameState)
+	return &gameState
+}
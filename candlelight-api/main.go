@@ -67,6 +67,8 @@ func registerPathHandlers(mux *http.ServeMux) {
 	mux.HandleFunc("/joinLobby", Lobby.HandleJoinLobby)
 	mux.HandleFunc("/hostLobby", Lobby.HostLobby)
 	mux.HandleFunc("/rejoinLobby", Lobby.HandleRejoinLobby)
+	mux.HandleFunc("/spectateLobby", Lobby.HandleSpectateLobby)
+	mux.HandleFunc("/lobbies", Lobby.GetPublicLobbies)
 
 	//Account-related Requests
 	mux.HandleFunc("/createAccount", Accounts.CreateAccount)
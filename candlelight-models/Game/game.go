@@ -18,6 +18,8 @@ type Game struct {
 	Author string `json:"author"`
 	//Max number of allowed players in this Game
 	MaxPlayers int `json:"maxPlayers"`
+	//Min number of players needed to start this Game. Anything below 1 is treated as 1
+	MinPlayers int `json:"minPlayers"`
 	//Whether this game should be shown to everyone instead of just the Author
 	Published bool `json:"published"`
 	//Set of Rules Candlelight should use while running this Game. See GameRules struct
@@ -48,11 +50,52 @@ type GameResource struct {
 	MinValue int `json:"minValue"`
 }
 
+// Supported values for GameRules.SpectatorVisibility
+const (
+	//Spectators can see every public View, but not what's in anyone's hand. This is the default
+	SpectatorVisibility_PublicOnly = "PublicOnly"
+	//Spectators can see everything, including every player's hand
+	SpectatorVisibility_Everything = "Everything"
+	//Nobody can spectate this game
+	SpectatorVisibility_Disabled = "Disabled"
+)
+
+// Supported values for GameRules.LeavePolicy. Every policy except LeavePolicy_ReturnToCollection and LeavePolicy_Distribute keeps the
+// player's seat (and everything in their hand) in the game
+const (
+	//The player's seat is kept as it is, and skipped in the turn order. This is the default
+	LeavePolicy_Freeze = "Freeze"
+	//Every card in the player's hand is put into the collection given by GameRules.LeaveCollection, and their seat is removed
+	LeavePolicy_ReturnToCollection = "ReturnToCollection"
+	//Every card in the player's hand is dealt out among the remaining players, and their seat is removed
+	LeavePolicy_Distribute = "Distribute"
+	//The player's seat is handed to a bot, which plays their turns for them
+	LeavePolicy_Bot = "Bot"
+	//The player's seat is kept for a new player to take over. It's skipped in the turn order until then
+	LeavePolicy_OpenSeat = "OpenSeat"
+)
+
 type GameRules struct {
 	//Whether players should be able to see details about other players such as how many cards are in their hands
 	ShowOtherPlayerDetails bool `json:"showOtherPlayerDetails"`
 	//Whether the RuleEngine should make use of (and enforce) Player turns, including disallowing actions from anyone whose turn it is not
 	EnforceTurnOrder bool `json:"enforceTurnOrder"`
+	//Whether the game is played in simultaneous rounds instead of turns. Each round, every player secretly commits to one action, and once everyone
+	//has, they're all applied together in seat order, starting from CurrentPlayer. CurrentPlayer then moves on to the next seat for the next round
+	SimultaneousRounds bool `json:"simultaneousRounds"`
+	//What spectators are allowed to see. One of the above SpectatorVisibility constants. Empty is treated as SpectatorVisibility_PublicOnly
+	SpectatorVisibility string `json:"spectatorVisibility"`
+	//What happens to a player's seat and hand if they leave mid-game. One of the above LeavePolicy constants. Empty is treated as LeavePolicy_Freeze
+	LeavePolicy string `json:"leavePolicy"`
+	//Only used with LeavePolicy_ReturnToCollection. The Id of the Deck or CardPlace (in a public View) that a leaving player's cards are put into
+	LeaveCollection string `json:"leaveCollection"`
+	//How many seconds each player has to take their turn. Once it's up, their turn is ended for them according to TurnTimeoutAction. 0 (or less) means no limit
+	TurnTimeLimit int `json:"turnTimeLimit"`
+	//How many seconds before their time is up a player is warned. 0 (or less) means 10 seconds. Never more than half of TurnTimeLimit
+	TurnWarning int `json:"turnWarning"`
+	//What's done for a player who runs out of time. Either the name of a bot strategy (i.e. "Random"), which plays out the rest of their turn for
+	//them, or anything else (including empty) to just end their turn
+	TurnTimeoutAction string `json:"turnTimeoutAction"`
 }
 
 // A collection of Pieces to display to a player.
@@ -60,30 +103,48 @@ type View struct {
 	//An Id for this View. Used to fill in ParentView on all GamePieces belonging to this View
 	Id string `json:"id"`
 	//The PlayerNumber of the Owner of this view. 0 is a special, reserved number for the Game itself. Any
-	//view with OwnerPlayerNumber == 0 is public and accessible by all Players
+	//view with OwnerPlayerNumber == 0 is public and accessible by all Players. Ignored if [PerPlayer] is true
 	OwnerPlayerNumber int `json:"ownerPlayerNumber"`
+	//Whether this View is a template rather than a View of its own. When the game starts, every player is given their own
+	//copy of each template (with fresh Ids for the View and everything in it), no matter how many players joined
+	PerPlayer bool `json:"perPlayer"`
+	//If this View was copied from a template, the Id of that template. Lets Sparks and other setup refer to "each player's copy" of a template by the template's Id
+	TemplateId string `json:"templateId"`
 	//Which playmat should be displayed as the background for this View
 	Playmat int `json:"playmat"`
 	//The PieceSet belonging to (and rendered within) this View
 	Pieces Pieces.PieceSet `json:"pieces"`
 }
 
+// Returns every non-template View owned by the given [playerNum]
 func (game Game) ViewsForPlayer(playerNum int) []View {
 	toReturn := []View{}
 	for _, view := range game.Views {
-		if view.OwnerPlayerNumber == playerNum {
+		if view.OwnerPlayerNumber == playerNum && !view.PerPlayer {
 			toReturn = append(toReturn, view)
 		}
 	}
 	return toReturn
 }
 
+// Returns every Piece in the non-template Views owned by the given [playerNum]
 func (game Game) PiecesForPlayer(playerNum int) Pieces.PieceSet {
 	toReturn := Pieces.PieceSet{}
 	for _, view := range game.Views {
-		if view.OwnerPlayerNumber == playerNum {
+		if view.OwnerPlayerNumber == playerNum && !view.PerPlayer {
 			toReturn.Combine(view.Pieces)
 		}
 	}
 	return toReturn
 }
+
+// Returns every View marked as PerPlayer
+func (game Game) ViewTemplates() []View {
+	toReturn := []View{}
+	for _, view := range game.Views {
+		if view.PerPlayer {
+			toReturn = append(toReturn, view)
+		}
+	}
+	return toReturn
+}
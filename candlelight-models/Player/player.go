@@ -2,6 +2,19 @@ package Player
 
 import "candlelight-models/Game"
 
+// Supported values for Player.SeatStatus. These only apply to Players in a GameState. SeatStatus is empty while a person is
+// playing the seat
+const (
+	//Nobody is playing this seat. It's skipped in the turn order
+	SeatStatus_Frozen = "Frozen"
+	//This seat is played by a bot, either because the host added one to the lobby or because its player left a game with LeavePolicy_Bot
+	SeatStatus_Bot = "Bot"
+	//This seat is waiting for a new player to take it over. It's skipped in the turn order until then
+	SeatStatus_Open = "Open"
+	//This seat has been removed from the game. Never set on a Player, but used in Changelogs to say a seat is gone
+	SeatStatus_Removed = "Removed"
+)
+
 // A Player within your game.
 type Player struct {
 	//Id just for book keeping
@@ -10,10 +23,26 @@ type Player struct {
 	Name string `json:"name"`
 	//All the Views belonging to this Player, with their associated PieceSets
 	Hand []Game.View `json:"hand"`
+	//Whether the player sitting in this seat has left mid-game, and what's become of the seat if so. One of the above SeatStatus constants,
+	//or empty if they're still playing
+	SeatStatus string `json:"seatStatus"`
+	//The strategy playing this seat if it's a bot the host added to the lobby. Empty for people, and for seats a bot took over from a
+	//player who left, which are played with the default strategy
+	Bot string `json:"bot"`
 	//All the Resources this Player currently has
 	//Resources []PlayerResource `json:"resources"`
 }
 
+// Returns whether this seat still gets turns, i.e. it's played by a person or a bot
+func (player Player) TakesTurns() bool {
+	return player.SeatStatus == "" || player.SeatStatus == SeatStatus_Bot
+}
+
+// Returns whether this seat is played by a bot rather than a person
+func (player Player) IsBot() bool {
+	return player.Bot != "" || player.SeatStatus == SeatStatus_Bot
+}
+
 // A resource that a Player currently possesses. Should have a name identical to
 // a name in the list of GameResources to ensure that min/max values stay correct
 type PlayerResource struct {
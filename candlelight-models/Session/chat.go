@@ -0,0 +1,22 @@
+package Session
+
+import "time"
+
+// A chat message sent by a player in a lobby, whether before or during the game
+type ChatMessage struct {
+	//Unique Id of this message. The frontend can use it to avoid showing the same message twice
+	Id string `json:"id"`
+	//Id of the player who sent the message
+	From string `json:"from"`
+	//Id of the only player who can see this message. Empty if the message was sent to the whole lobby
+	To string `json:"to,omitempty"`
+	//The message itself
+	Message string `json:"message"`
+	//When the server received the message
+	SentAt time.Time `json:"sentAt"`
+}
+
+// Returns whether the player with the given [playerId] is allowed to see this message
+func (message ChatMessage) VisibleTo(playerId string) bool {
+	return message.To == "" || message.To == playerId || message.From == playerId
+}
@@ -0,0 +1,116 @@
+package Session
+
+import "errors"
+
+// Stable, machine-readable codes for anything that can go wrong while running a game. These are sent to the frontend alongside
+// every error so it can react to (or localize) an error without having to parse its message. Once released, a code should never change
+const (
+	//A websocket message or HTTP request was missing something it needed, or couldn't be parsed
+	ErrorCode_MalformedRequest = "MalformedRequest"
+	//A SubmittedAction's Type isn't one of the supported ActionTypes
+	ErrorCode_UnknownActionType = "UnknownActionType"
+	//A player tried to take an action when it wasn't their turn
+	ErrorCode_NotYourTurn = "NotYourTurn"
+	//The referenced player isn't part of the game or lobby
+	ErrorCode_PlayerNotFound = "PlayerNotFound"
+	//The referenced View doesn't exist, or can't be seen by the player
+	ErrorCode_ViewNotFound = "ViewNotFound"
+	//The referenced Card isn't where the action expected it to be
+	ErrorCode_CardNotFound = "CardNotFound"
+	//The referenced Deck or CardPlace doesn't exist in the given View
+	ErrorCode_CollectionNotFound = "CollectionNotFound"
+	//The card can't be put into the Deck or CardPlace, because the collection's TagsWhitelist doesn't allow it
+	ErrorCode_CardNotAllowed = "CardNotAllowed"
+	//The referenced lobby doesn't exist (or has expired)
+	ErrorCode_LobbyNotFound = "LobbyNotFound"
+	//The lobby has already reached its max player count
+	ErrorCode_LobbyFull = "LobbyFull"
+	//Another player in the lobby already has the requested name
+	ErrorCode_NameTaken = "NameTaken"
+	//Only the host of the lobby may do this
+	ErrorCode_NotHost = "NotHost"
+	//This can only be done before the game starts
+	ErrorCode_GameAlreadyStarted = "GameAlreadyStarted"
+	//The game has ended, so this can no longer be done
+	ErrorCode_GameEnded = "GameEnded"
+	//Fewer players have joined than the game's minimum
+	ErrorCode_NotEnoughPlayers = "NotEnoughPlayers"
+	//At least one player hasn't marked themselves as ready
+	ErrorCode_PlayersNotReady = "PlayersNotReady"
+	//A seat arrangement was invalid, i.e. it contained a player twice or used an unknown seating order
+	ErrorCode_InvalidSeating = "InvalidSeating"
+	//One of the game's Sparks couldn't be applied when starting the game
+	ErrorCode_SparkFailed = "SparkFailed"
+	//The player already has an open connection to the lobby
+	ErrorCode_AlreadyConnected = "AlreadyConnected"
+	//The player has been muted by the host, so can't send chat messages
+	ErrorCode_Muted = "Muted"
+	//A chat message was longer than the server allows
+	ErrorCode_MessageTooLong = "MessageTooLong"
+	//Spectators can only watch, so can't do this
+	ErrorCode_NotAPlayer = "NotAPlayer"
+	//The lobby isn't accepting spectators, either because the host has turned spectating off or the game doesn't allow it
+	ErrorCode_SpectatingDisabled = "SpectatingDisabled"
+	//The player has been banned from the lobby
+	ErrorCode_Banned = "Banned"
+	//The lobby has a password, and the one given was missing or wrong
+	ErrorCode_WrongPassword = "WrongPassword"
+	//A lobby setting was out of range or not allowed, i.e. a max player count lower than the number of players already in the lobby
+	ErrorCode_InvalidSettings = "InvalidSettings"
+	//The player has left the game, so their seat can't be given the turn
+	ErrorCode_SeatVacant = "SeatVacant"
+	//The seat a new player asked to take over isn't open, i.e. because its player is still playing
+	ErrorCode_NoOpenSeat = "NoOpenSeat"
+	//The host tried to add a bot with a strategy the server doesn't know
+	ErrorCode_UnknownBotStrategy = "UnknownBotStrategy"
+	//An action was submitted to a game played in simultaneous rounds, where actions have to be committed instead
+	ErrorCode_MustCommit = "MustCommit"
+	//An action was committed to a game that isn't played in simultaneous rounds, where actions have to be submitted instead
+	ErrorCode_NotSimultaneous = "NotSimultaneous"
+	//A player tried to vote to kick the host, or the host tried to ban themselves. The host can only leave or be replaced when they disconnect
+	ErrorCode_CantKickHost = "CantKickHost"
+	//Something went wrong on the server's end. The message will not contain any details
+	ErrorCode_Internal = "Internal"
+)
+
+// An error meant to be shown to a player. [Code] is one of the above ErrorCode constants, [Message] is an English description suitable for
+// displaying directly, and [Details] holds any Ids or values relevant to the error (i.e. the Id of the Card that couldn't be found)
+type GameError struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func (gameError *GameError) Error() string {
+	return gameError.Message
+}
+
+// Creates a GameError with the given code and message. [details] should be given as key-value pairs (i.e. "cardId", "abc123").
+// A trailing key with no value is ignored
+func NewGameError(code string, message string, details ...string) *GameError {
+	gameError := &GameError{
+		Code:    code,
+		Message: message,
+	}
+	for i := 0; i+1 < len(details); i += 2 {
+		if gameError.Details == nil {
+			gameError.Details = map[string]string{}
+		}
+		gameError.Details[details[i]] = details[i+1]
+	}
+	return gameError
+}
+
+// Returns the GameError found in [err]'s chain. If there isn't one, [err] is assumed to be an internal error not meant for players, and
+// an Internal GameError with a generic message is returned instead. Returns nil if [err] is nil
+func AsGameError(err error) *GameError {
+	if err == nil {
+		return nil
+	}
+
+	var gameError *GameError
+	if errors.As(err, &gameError) {
+		return gameError
+	}
+	return NewGameError(ErrorCode_Internal, "Something went wrong on the server. Please try again")
+}
@@ -4,6 +4,7 @@ import (
 	"candlelight-models/Game"
 	"candlelight-models/Player"
 	"encoding/json"
+	"time"
 )
 
 // Supported valued for SubmittedAction.Type. Make sure this matches up with the object you put
@@ -32,6 +33,8 @@ type GameState struct {
 	Players []Player.Player `json:"players"`
 	//Id of the Player whose turn it currently is
 	CurrentPlayer string `json:"currentPlayer"`
+	//When the current turn will be ended for its player, if the game has a TurnTimeLimit. Null otherwise
+	TurnDeadline *time.Time `json:"turnDeadline"`
 	//Text that should be shown to all players upon joining the game. Should be scrubbed before use to prevent XSS because it is used as InnerHTML
 	SplashText string `json:"splashText"`
 	//Set of rules Candlelight should use while running this game
@@ -48,8 +51,21 @@ type Changelog struct {
 	Views []*Game.View `json:"views"`
 	//Id of the Player whose turn it is after applying the most recent SubmittedAction
 	CurrentPlayer string `json:"currentPlayer"`
-	//A description of the action that just took place. Will be empty if the most recent SubmittedAction had no effect for any reason
+	//When the turn of [CurrentPlayer] will be ended for them, if the game has a TurnTimeLimit. Null otherwise
+	TurnDeadline *time.Time `json:"turnDeadline"`
+	//A description of the action that just took place
 	MostRecentAction string `json:"mostRecentAction"`
+	//The Id of every Player whose seat changed because of the most recent action (i.e. because its player left), mapped to its new SeatStatus
+	Seats map[string]string `json:"seats"`
+}
+
+// Starts the clock on the current turn, giving its player until now + the game's TurnTimeLimit to take it. Clears the deadline if there's no limit
+func (gameState *GameState) StartTurnClock() {
+	gameState.TurnDeadline = nil
+	if gameState.Rules.TurnTimeLimit > 0 && gameState.CurrentPlayer != "" {
+		deadline := time.Now().Add(time.Duration(gameState.Rules.TurnTimeLimit) * time.Second).UTC().Truncate(time.Millisecond)
+		gameState.TurnDeadline = &deadline
+	}
 }
 
 // This is the way the frontend will send data to the backend during gameplay. They will
@@ -1,6 +1,10 @@
 package Session
 
-import "candlelight-models/Player"
+import (
+	"candlelight-models/Game"
+	"candlelight-models/Player"
+	"strings"
+)
 
 const (
 	LobbyStatus_AwaitingStart = "Awaiting Start"
@@ -8,6 +12,16 @@ const (
 	LobbyStatus_Ended         = "Game Ended"
 )
 
+// Supported values for Lobby.SeatingOrder, deciding the order of players in the GameState when the game starts
+const (
+	//Players are seated in the order they joined the lobby. This is the default
+	SeatingOrder_JoinOrder = "JoinOrder"
+	//Players are seated in a random order
+	SeatingOrder_Random = "Random"
+	//Players are seated in the order given by the host in Lobby.SeatArrangement
+	SeatingOrder_HostArranged = "HostArranged"
+)
+
 //A lobby is a collection of players waiting for a game to start. This is created by the /createRoom endpoint
 //which returns the room code. From there, you can pass the room code to /joinRoom which will put you in the room
 //and return the state of the lobby
@@ -27,8 +41,123 @@ type Lobby struct {
 	NumPlayers int `json:"numPlayers"`
 	//Maximum allowed players in the lobby. Determined from the GameDef's MaxPlayers
 	MaxPlayers int `json:"maxPlayers"`
+	//Minimum players needed before the game can be started. Determined from the GameDef's MinPlayers
+	MinPlayers int `json:"minPlayers"`
 	//Current list of joined players
 	Players []Player.Player `json:"players"`
 	//The player that created the Lobby
 	Host Player.Player `json:"host"`
+	//Whether each player has marked themselves as ready to start, keyed by player Id. A player missing from this map is not ready.
+	//The game can't be started until every player in the lobby is ready
+	Ready map[string]bool `json:"ready"`
+	//How players should be seated when the game starts. Will be one of the above SeatingOrder constants
+	SeatingOrder string `json:"seatingOrder"`
+	//Ids of players in the order the host wants them seated. Only used when SeatingOrder == SeatingOrder_HostArranged. Anyone
+	//missing from this list is seated after everyone in it, in the order they joined
+	SeatArrangement []string `json:"seatArrangement"`
+	//Players the host has muted, keyed by player Id. A muted player can't send chat messages. A player missing from this map is not muted
+	Muted map[string]bool `json:"muted"`
+	//Whether anyone may spectate this lobby. Starts out true unless the game definition disables spectating, and can be changed by the host
+	AllowSpectators bool `json:"allowSpectators"`
+	//Everyone currently spectating. Spectators aren't players, so aren't counted against MaxPlayers and can't take any actions
+	Spectators []Player.Player `json:"spectators"`
+	//Changes the host has made to the game definition's rules, for this lobby only
+	RuleOverrides RuleOverrides `json:"ruleOverrides"`
+	//Whether players need a password to join. The password itself is never sent to players
+	HasPassword bool `json:"hasPassword"`
+	//Whether this lobby is listed by /lobbies for anyone to find while it's awaiting start. Private lobbies can only be joined with the room code
+	Public bool `json:"public"`
+	//Players who have been banned from the lobby, either by the host or by a vote. They can't join or spectate again under the same name, or with the
+	//Id they were given. Joining isn't tied to an account yet, so someone who changes their name and leaves their old Id out still gets in
+	Banned []Player.Player `json:"banned"`
+	//Votes to kick players, keyed by the Id of the player being voted against, listing the Ids of everyone who has voted to kick them.
+	//Once most of the other players have voted, the player is removed and banned
+	KickVotes map[string][]string `json:"kickVotes"`
+	//Requests from new players to take over seats left open by players who left mid-game. Each waits here until the host approves or declines it
+	SeatRequests []SeatRequest `json:"seatRequests"`
+}
+
+// A request from a new player to take over a seat whose player left mid-game
+type SeatRequest struct {
+	//The player asking to take over the seat. They aren't one of the lobby's Players until the host approves
+	Player Player.Player `json:"player"`
+	//The Id of the seat's Player in the GameState, which is still the Id of the player who left
+	SeatId string `json:"seatId"`
+}
+
+// Returns whether [playerId] or [playerName] belongs to someone banned from the Lobby. [playerId] is the Id a returning player says they were given
+// when they were last in the Lobby, and may be empty. Since the player chooses whether to send it, this only catches players who don't hide who they
+// are. Names are compared ignoring case
+func (lobby Lobby) IsBanned(playerId string, playerName string) bool {
+	for _, banned := range lobby.Banned {
+		if (playerId != "" && banned.Id == playerId) || strings.EqualFold(banned.Name, playerName) {
+			return true
+		}
+	}
+	return false
+}
+
+// What /lobbies shows about a public lobby, so players can pick one to join
+type LobbySummary struct {
+	//The Room Code to pass to /joinLobby
+	RoomCode string `json:"roomCode"`
+	//The GameDefinition the Lobby is going to play
+	GameDefinitionId string `json:"gameDefinitionId"`
+	//Name of the Game being played
+	GameName string `json:"gameName"`
+	//Current number of players in the lobby
+	NumPlayers int `json:"numPlayers"`
+	//Maximum allowed players in the lobby
+	MaxPlayers int `json:"maxPlayers"`
+	//Name of the lobby's host
+	HostName string `json:"hostName"`
+	//Whether players need a password to join
+	HasPassword bool `json:"hasPassword"`
+}
+
+// Returns what /lobbies should show about the Lobby
+func (lobby Lobby) Summary() LobbySummary {
+	return LobbySummary{
+		RoomCode:         lobby.RoomCode,
+		GameDefinitionId: lobby.GameDefinitionId,
+		GameName:         lobby.GameName,
+		NumPlayers:       lobby.NumPlayers,
+		MaxPlayers:       lobby.MaxPlayers,
+		HostName:         lobby.Host.Name,
+		HasPassword:      lobby.HasPassword,
+	}
+}
+
+// Rules the host can change for a single lobby. A nil field means the game definition's rule is used
+type RuleOverrides struct {
+	//Overrides GameRules.EnforceTurnOrder
+	EnforceTurnOrder *bool `json:"enforceTurnOrder,omitempty"`
+	//Overrides GameRules.ShowOtherPlayerDetails
+	ShowOtherPlayerDetails *bool `json:"showOtherPlayerDetails,omitempty"`
+	//Overrides GameRules.TurnTimeLimit
+	TurnTimeLimit *int `json:"turnTimeLimit,omitempty"`
+}
+
+// Returns [rules] with any overrides applied
+func (overrides RuleOverrides) Apply(rules Game.GameRules) Game.GameRules {
+	if overrides.EnforceTurnOrder != nil {
+		rules.EnforceTurnOrder = *overrides.EnforceTurnOrder
+	}
+	if overrides.ShowOtherPlayerDetails != nil {
+		rules.ShowOtherPlayerDetails = *overrides.ShowOtherPlayerDetails
+	}
+	if overrides.TurnTimeLimit != nil {
+		rules.TurnTimeLimit = *overrides.TurnTimeLimit
+	}
+	return rules
+}
+
+// Returns whether every player in the Lobby has marked themselves as ready
+func (lobby Lobby) AllPlayersReady() bool {
+	for _, player := range lobby.Players {
+		if !lobby.Ready[player.Id] {
+			return false
+		}
+	}
+	return true
 }
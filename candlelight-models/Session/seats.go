@@ -0,0 +1,164 @@
+package Session
+
+import (
+	"candlelight-models/Game"
+	"candlelight-models/Pieces"
+	"candlelight-models/Player"
+	"fmt"
+	"slices"
+)
+
+// Takes [playerId] out of the game according to the game's LeavePolicy, ending their turn first if it's theirs. Returns a Changelog of every View
+// and seat that changed as a result. If the player's cards can't go where the policy says (i.e. the LeaveCollection doesn't exist, or nobody else has
+// a hand to deal them into), their seat is frozen instead so that no cards are lost
+func (gameState *GameState) VacateSeat(playerId string) (Changelog, error) {
+	changelog := Changelog{
+		CurrentPlayer: gameState.CurrentPlayer,
+		Seats:         map[string]string{},
+	}
+
+	seatIndex := slices.IndexFunc(gameState.Players, func(p Player.Player) bool { return p.Id == playerId })
+	if seatIndex < 0 {
+		return changelog, NewGameError(ErrorCode_PlayerNotFound, "Could not find the player leaving the game!", "playerId", playerId)
+	}
+	//A bot that took over from a player who left has nobody left to leave, but a bot the host added can be removed like anyone else
+	seat := gameState.Players[seatIndex]
+	if !seat.TakesTurns() || (seat.SeatStatus == Player.SeatStatus_Bot && seat.Bot == "") {
+		return changelog, NewGameError(ErrorCode_SeatVacant, "That player has already left the game!", "playerId", playerId)
+	}
+
+	//Mark the seat first, so the turn can't be passed straight back to it
+	gameState.Players[seatIndex].SeatStatus = Player.SeatStatus_Frozen
+	gameState.Players[seatIndex].Bot = ""
+	if gameState.CurrentPlayer == playerId {
+		EndTurn{}.Execute(gameState, playerId)
+	}
+
+	player := gameState.Players[seatIndex]
+	var outcome string
+	switch gameState.Rules.LeavePolicy {
+	case Game.LeavePolicy_ReturnToCollection:
+		outcome = gameState.returnCardsToCollection(player, &changelog)
+	case Game.LeavePolicy_Distribute:
+		outcome = gameState.distributeCards(seatIndex, &changelog)
+	case Game.LeavePolicy_Bot:
+		gameState.Players[seatIndex].SeatStatus = Player.SeatStatus_Bot
+		outcome = "A bot has taken over their seat"
+	case Game.LeavePolicy_OpenSeat:
+		gameState.Players[seatIndex].SeatStatus = Player.SeatStatus_Open
+		outcome = "Their seat is open for a new player to take over"
+	}
+
+	if outcome == "" {
+		outcome = "Their seat has been frozen"
+	}
+
+	//Policies that handed out the player's cards have no more use for the seat
+	if changelog.Views != nil {
+		gameState.Players = slices.Delete(gameState.Players, seatIndex, seatIndex+1)
+		changelog.Seats[playerId] = Player.SeatStatus_Removed
+
+		//Only happens if nobody was left to take the turn. Give it to whoever was seated after them
+		if gameState.CurrentPlayer == playerId {
+			gameState.CurrentPlayer = ""
+			if len(gameState.Players) > 0 {
+				gameState.CurrentPlayer = gameState.Players[seatIndex%len(gameState.Players)].Id
+			}
+			gameState.StartTurnClock()
+		}
+	} else {
+		changelog.Seats[playerId] = gameState.Players[seatIndex].SeatStatus
+	}
+
+	changelog.CurrentPlayer = gameState.CurrentPlayer
+	changelog.TurnDeadline = gameState.TurnDeadline
+	changelog.MostRecentAction = fmt.Sprintf("Player '%s' left the game. %s", player.Name, outcome)
+	return changelog, nil
+}
+
+// Returns the Ids of every seat waiting for a new player to take it over, in turn order
+func (gameState GameState) OpenSeats() []string {
+	seats := []string{}
+	for _, player := range gameState.Players {
+		if player.SeatStatus == Player.SeatStatus_Open {
+			seats = append(seats, player.Id)
+		}
+	}
+	return seats
+}
+
+// Seats [player] in the open seat with [seatId], so they take over its hand and its place in the turn order, including the current turn if it's
+// the seat's. From then on the seat is known by [player]'s Id
+func (gameState *GameState) TakeOverSeat(seatId string, player Player.Player) error {
+	seat := findPlayerInGameState(seatId, gameState)
+	if seat == nil || seat.SeatStatus != Player.SeatStatus_Open {
+		return NewGameError(ErrorCode_NoOpenSeat, "That seat isn't open to take over!", "seatId", seatId)
+	}
+
+	seat.Id = player.Id
+	seat.Name = player.Name
+	seat.SeatStatus = ""
+	if gameState.CurrentPlayer == seatId {
+		gameState.CurrentPlayer = player.Id
+	}
+	return nil
+}
+
+// Puts every card in [player]'s hand into the game's LeaveCollection. Returns a description of what happened, or an empty string if the
+// collection couldn't be found and nothing was moved
+func (gameState *GameState) returnCardsToCollection(player Player.Player, changelog *Changelog) string {
+	for index := range gameState.Views {
+		view := &gameState.Views[index]
+		collection := findCollectionInView(gameState.Rules.LeaveCollection, view)
+		if collection == nil {
+			continue
+		}
+
+		for _, card := range cardsInHand(player) {
+			card.ParentView = view.Id
+			collection.AddCardToCollection(card)
+		}
+		changelog.Views = append(changelog.Views, view)
+		return fmt.Sprintf("Their cards were put into '%s'", collection.GetName())
+	}
+	return ""
+}
+
+// Deals every card in the hand of the player at [seatIndex] out among the other players still in the game, one at a time in turn order, starting
+// with whoever sits after them. Each card becomes an Orphan in its new owner's first hand View. Returns a description of what happened, or an empty
+// string if nobody could be given the cards and nothing was moved
+func (gameState *GameState) distributeCards(seatIndex int, changelog *Changelog) string {
+	recipients := []*Game.View{}
+	for offset := 1; offset < len(gameState.Players); offset++ {
+		recipient := &gameState.Players[(seatIndex+offset)%len(gameState.Players)]
+		if recipient.TakesTurns() && len(recipient.Hand) > 0 {
+			recipients = append(recipients, &recipient.Hand[0])
+		}
+	}
+	if len(recipients) == 0 {
+		return ""
+	}
+
+	for index, card := range cardsInHand(gameState.Players[seatIndex]) {
+		hand := recipients[index%len(recipients)]
+		card.ParentView = hand.Id
+		hand.Pieces.Orphans = append(hand.Pieces.Orphans, card)
+	}
+	changelog.Views = append(changelog.Views, recipients...)
+	return "Their cards were dealt out among the remaining players"
+}
+
+// Returns every card in [player]'s hand, whether it's an Orphan or inside a Deck or CardPlace
+func cardsInHand(player Player.Player) []Pieces.Card {
+	cards := []Pieces.Card{}
+	for _, view := range player.Hand {
+		cards = append(cards, view.Pieces.Orphans...)
+		for _, deck := range view.Pieces.Decks {
+			cards = append(cards, deck.Cards...)
+		}
+		for _, cardPlace := range view.Pieces.CardPlaces {
+			cards = append(cards, cardPlace.Cards...)
+		}
+	}
+	return cards
+}
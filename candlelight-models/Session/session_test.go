@@ -4,8 +4,12 @@ import (
 	"candlelight-models/Game"
 	"candlelight-models/Pieces"
 	"candlelight-models/Player"
+	"errors"
+	"fmt"
 	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestInsertion_Execute(t *testing.T) {
@@ -790,6 +794,138 @@ func TestReshuffle_Execute(t *testing.T) {
 	}
 }
 
+func TestExecute_ErrorCodes(t *testing.T) {
+	var tests = []struct {
+		Name            string
+		Turn            Turn
+		ExpectedCode    string
+		ExpectedDetails map[string]string
+	}{
+		{
+			Name:            "Missing View",
+			Turn:            Cardflip{FlipCard: "card", InView: "nowhere"},
+			ExpectedCode:    ErrorCode_ViewNotFound,
+			ExpectedDetails: map[string]string{"viewId": "nowhere"},
+		},
+		{
+			Name:            "Missing Card",
+			Turn:            Movement{CardId: "nothing", FromView: "table", ToView: "table"},
+			ExpectedCode:    ErrorCode_CardNotFound,
+			ExpectedDetails: map[string]string{"cardId": "nothing", "viewId": "table"},
+		},
+		{
+			Name:            "Missing Collection",
+			Turn:            Insertion{InsertCard: "card", FromView: "table", ToCollection: "nothing", InView: "table"},
+			ExpectedCode:    ErrorCode_CollectionNotFound,
+			ExpectedDetails: map[string]string{"collectionId": "nothing"},
+		},
+		{
+			Name:            "Card Not Allowed",
+			Turn:            Insertion{InsertCard: "card", FromView: "table", ToCollection: "picky", InView: "table"},
+			ExpectedCode:    ErrorCode_CardNotAllowed,
+			ExpectedDetails: map[string]string{"cardId": "card", "collectionId": "picky"},
+		},
+		{
+			Name:            "Missing Next Player",
+			Turn:            EndTurn{NextPlayer: "nobody"},
+			ExpectedCode:    ErrorCode_PlayerNotFound,
+			ExpectedDetails: map[string]string{"playerId": "nobody"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			gameState := GameState{
+				CurrentPlayer: "me",
+				Players:       []Player.Player{{Id: "me"}},
+				Views: []Game.View{
+					{
+						Id: "table",
+						Pieces: Pieces.PieceSet{
+							Orphans:    []Pieces.Card{{GamePiece: Pieces.GamePiece{Id: "card"}}},
+							CardPlaces: []Pieces.CardPlace{{GamePiece: Pieces.GamePiece{Id: "picky"}, PieceContainer: Pieces.PieceContainer{TagsWhitelist: map[string][]string{"suit": {"hearts"}}}}},
+						},
+					},
+				},
+			}
+
+			_, err := tt.Turn.Execute(&gameState, "me")
+
+			var gameError *GameError
+			if !errors.As(err, &gameError) {
+				t.Fatalf("Expected a GameError, Got %v", err)
+			}
+			if gameError.Code != tt.ExpectedCode {
+				t.Errorf("Expected code {%s}, Got {%s}", tt.ExpectedCode, gameError.Code)
+			}
+			for key, value := range tt.ExpectedDetails {
+				if gameError.Details[key] != value {
+					t.Errorf("Expected detail {%s} to be {%s}, Got {%s}", key, value, gameError.Details[key])
+				}
+			}
+		})
+	}
+}
+
+func TestAsGameError(t *testing.T) {
+	notYourTurn := NewGameError(ErrorCode_NotYourTurn, "Not your turn!", "currentPlayer", "someoneElse", "ignored")
+	if len(notYourTurn.Details) != 1 || notYourTurn.Details["currentPlayer"] != "someoneElse" {
+		t.Errorf("Details weren't paired up correctly. Got %v", notYourTurn.Details)
+	}
+
+	if AsGameError(fmt.Errorf("wrapped: %w", notYourTurn)) != notYourTurn {
+		t.Errorf("Expected a wrapped GameError to be found")
+	}
+	if internal := AsGameError(fmt.Errorf("redis: connection refused")); internal.Code != ErrorCode_Internal || strings.Contains(internal.Message, "redis") {
+		t.Errorf("Expected a generic Internal error, Got {%s: %s}", internal.Code, internal.Message)
+	}
+	if AsGameError(nil) != nil {
+		t.Errorf("Expected nil for a nil error")
+	}
+}
+
+func TestForSpectators(t *testing.T) {
+	hand := Game.View{Id: "hand", Pieces: Pieces.PieceSet{Orphans: []Pieces.Card{{GamePiece: Pieces.GamePiece{Id: "secret"}}}}}
+	table := Game.View{Id: "table"}
+
+	var tests = []struct {
+		name               string
+		visibility         string
+		expectedHandSize   int
+		expectedChangelogs int
+	}{
+		{name: "Default", visibility: "", expectedHandSize: 0, expectedChangelogs: 1},
+		{name: "Public Only", visibility: Game.SpectatorVisibility_PublicOnly, expectedHandSize: 0, expectedChangelogs: 1},
+		{name: "Everything", visibility: Game.SpectatorVisibility_Everything, expectedHandSize: 1, expectedChangelogs: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameState := GameState{
+				Rules:   Game.GameRules{SpectatorVisibility: tt.visibility},
+				Players: []Player.Player{{Id: "me", Name: "me", Hand: []Game.View{hand}}},
+				Views:   []Game.View{table},
+			}
+
+			projection := gameState.ForSpectators()
+			if len(projection.Players) != 1 || projection.Players[0].Name != "me" {
+				t.Fatalf("Spectators should still see every player. Got %+v", projection.Players)
+			}
+			if len(projection.Players[0].Hand) != tt.expectedHandSize {
+				t.Errorf("Expected spectators to see %d hand Views, Got %d", tt.expectedHandSize, len(projection.Players[0].Hand))
+			}
+			if len(gameState.Players[0].Hand) != 1 {
+				t.Errorf("Projecting the GameState changed the original")
+			}
+
+			changelog := Changelog{Views: []*Game.View{&table, &hand}}
+			if views := changelog.ForSpectators(gameState).Views; len(views) != tt.expectedChangelogs {
+				t.Errorf("Expected spectators to see %d Views in the Changelog, Got %d", tt.expectedChangelogs, len(views))
+			}
+		})
+	}
+}
+
 func cardInView(cardId string, view *Game.View) bool {
 	for _, collection := range view.Pieces.GetCollections() {
 		if collection.FindCardInCollection(cardId) != nil {
@@ -803,3 +939,227 @@ func cardInView(cardId string, view *Game.View) bool {
 	}
 	return false
 }
+
+func TestRuleOverrides(t *testing.T) {
+	rules := Game.GameRules{EnforceTurnOrder: true, ShowOtherPlayerDetails: true}
+
+	if applied := (RuleOverrides{}).Apply(rules); applied != rules {
+		t.Errorf("Expected no overrides to leave the rules alone. Got %+v", applied)
+	}
+
+	off := false
+	applied := RuleOverrides{EnforceTurnOrder: &off}.Apply(rules)
+	if applied.EnforceTurnOrder || !applied.ShowOtherPlayerDetails {
+		t.Errorf("Expected only EnforceTurnOrder to be overridden. Got %+v", applied)
+	}
+}
+
+func TestVacateSeat(t *testing.T) {
+	var tests = []struct {
+		name               string
+		policy             string
+		leaveCollection    string
+		expectedSeat       string
+		expectedPlayers    int
+		expectedDiscards   int
+		expectedOtherHands int
+	}{
+		{name: "Default Freezes Seat", policy: "", expectedSeat: Player.SeatStatus_Frozen, expectedPlayers: 3},
+		{name: "Bot", policy: Game.LeavePolicy_Bot, expectedSeat: Player.SeatStatus_Bot, expectedPlayers: 3},
+		{name: "Open Seat", policy: Game.LeavePolicy_OpenSeat, expectedSeat: Player.SeatStatus_Open, expectedPlayers: 3},
+		{name: "Return To Collection", policy: Game.LeavePolicy_ReturnToCollection, leaveCollection: "discard", expectedSeat: Player.SeatStatus_Removed, expectedPlayers: 2, expectedDiscards: 3},
+		{name: "Return To Missing Collection", policy: Game.LeavePolicy_ReturnToCollection, leaveCollection: "invalid", expectedSeat: Player.SeatStatus_Frozen, expectedPlayers: 3},
+		{name: "Distribute", policy: Game.LeavePolicy_Distribute, expectedSeat: Player.SeatStatus_Removed, expectedPlayers: 2, expectedOtherHands: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := func(id string) Pieces.Card {
+				return Pieces.Card{GamePiece: Pieces.GamePiece{Id: id, ParentView: "leaverHand"}}
+			}
+			leaver := Player.Player{Id: "leaver", Name: "leaver", Hand: []Game.View{{
+				Id: "leaverHand",
+				Pieces: Pieces.PieceSet{
+					Orphans: []Pieces.Card{card("a"), card("b")},
+					Decks:   []Pieces.Deck{{GamePiece: Pieces.GamePiece{Id: "leaverDeck"}, Cards: []Pieces.Card{card("c")}}},
+				},
+			}}}
+
+			gameState := GameState{
+				Rules: Game.GameRules{LeavePolicy: tt.policy, LeaveCollection: tt.leaveCollection},
+				Players: []Player.Player{
+					{Id: "first", Name: "first", Hand: []Game.View{{Id: "firstHand"}}},
+					leaver,
+					{Id: "last", Name: "last", Hand: []Game.View{{Id: "lastHand"}}},
+				},
+				CurrentPlayer: "leaver",
+				Views: []Game.View{{
+					Id:     "table",
+					Pieces: Pieces.PieceSet{CardPlaces: []Pieces.CardPlace{{GamePiece: Pieces.GamePiece{Id: "discard", Name: "Discard"}}}},
+				}},
+			}
+
+			changelog, err := gameState.VacateSeat("leaver")
+			if err != nil {
+				t.Fatalf("Unexpected error vacating seat: %s", err)
+			}
+
+			if changelog.Seats["leaver"] != tt.expectedSeat {
+				t.Errorf("Expected the Changelog to give the seat status %s, Got %s", tt.expectedSeat, changelog.Seats["leaver"])
+			}
+			if len(gameState.Players) != tt.expectedPlayers {
+				t.Fatalf("Expected %d seats to be left, Got %d", tt.expectedPlayers, len(gameState.Players))
+			}
+			if tt.expectedPlayers == 3 && gameState.Players[1].SeatStatus != tt.expectedSeat {
+				t.Errorf("Expected the seat to be marked %s, Got %s", tt.expectedSeat, gameState.Players[1].SeatStatus)
+			}
+
+			//Turn should skip the seat, whatever happened to it
+			if gameState.CurrentPlayer != "last" || changelog.CurrentPlayer != "last" {
+				t.Errorf("Expected the turn to pass to 'last'. Got %s (changelog says %s)", gameState.CurrentPlayer, changelog.CurrentPlayer)
+			}
+
+			discards := gameState.Views[0].Pieces.CardPlaces[0].Cards
+			if len(discards) != tt.expectedDiscards {
+				t.Errorf("Expected %d cards in the discard pile, Got %d", tt.expectedDiscards, len(discards))
+			}
+			for _, discard := range discards {
+				if discard.ParentView != "table" {
+					t.Errorf("Expected card %s to belong to View 'table', Got %s", discard.Id, discard.ParentView)
+				}
+			}
+
+			otherHands := 0
+			for _, player := range gameState.Players {
+				if player.Id != "leaver" {
+					otherHands += len(player.Hand[0].Pieces.Orphans)
+				}
+			}
+			if otherHands != tt.expectedOtherHands {
+				t.Errorf("Expected %d cards to be dealt to the other players, Got %d", tt.expectedOtherHands, otherHands)
+			}
+			if tt.policy == Game.LeavePolicy_Distribute && len(changelog.Views) != 2 {
+				t.Errorf("Expected both remaining hands in the Changelog, Got %d Views", len(changelog.Views))
+			}
+
+			if _, err := gameState.VacateSeat("leaver"); err == nil {
+				t.Errorf("Expected an error vacating a seat that's already empty or gone")
+			}
+		})
+	}
+
+	t.Run("Turn Skips Vacated Seats", func(t *testing.T) {
+		gameState := GameState{
+			Players:       []Player.Player{{Id: "first"}, {Id: "frozen", SeatStatus: Player.SeatStatus_Frozen}, {Id: "last"}},
+			CurrentPlayer: "first",
+		}
+
+		if _, err := (EndTurn{}).Execute(&gameState, "first"); err != nil || gameState.CurrentPlayer != "last" {
+			t.Errorf("Expected the turn to skip the frozen seat. Got %s (err == %v)", gameState.CurrentPlayer, err)
+		}
+		_, err := (EndTurn{NextPlayer: "frozen"}).Execute(&gameState, "last")
+		if gameError := AsGameError(err); gameError == nil || gameError.Code != ErrorCode_SeatVacant {
+			t.Errorf("Expected a SeatVacant error giving the turn to a frozen seat. Got %v", err)
+		}
+	})
+}
+
+func TestTakeOverSeat(t *testing.T) {
+	hand := []Game.View{{Id: "leaverHand", Pieces: Pieces.PieceSet{Orphans: []Pieces.Card{{GamePiece: Pieces.GamePiece{Id: "card"}}}}}}
+	gameState := GameState{
+		Players: []Player.Player{
+			{Id: "first", Name: "first"},
+			{Id: "frozen", Name: "frozen", SeatStatus: Player.SeatStatus_Frozen},
+			{Id: "leaver", Name: "leaver", Hand: hand, SeatStatus: Player.SeatStatus_Open},
+		},
+		CurrentPlayer: "leaver",
+	}
+
+	if seats := gameState.OpenSeats(); !slices.Equal(seats, []string{"leaver"}) {
+		t.Errorf("Expected only the open seat to be listed. Got %v", seats)
+	}
+
+	for _, seatId := range []string{"first", "frozen", "invalid"} {
+		err := gameState.TakeOverSeat(seatId, Player.Player{Id: "newcomer", Name: "newcomer"})
+		if gameError := AsGameError(err); gameError == nil || gameError.Code != ErrorCode_NoOpenSeat {
+			t.Errorf("Expected a NoOpenSeat error taking over seat {%s}, Got %v", seatId, err)
+		}
+	}
+
+	if err := gameState.TakeOverSeat("leaver", Player.Player{Id: "newcomer", Name: "newcomer"}); err != nil {
+		t.Fatalf("Expected to take over the open seat, Got %s", err)
+	}
+	seat := gameState.Players[2]
+	if seat.Id != "newcomer" || seat.Name != "newcomer" || seat.SeatStatus != "" || len(seat.Hand) != 1 || seat.Hand[0].Id != "leaverHand" {
+		t.Errorf("Expected the newcomer to inherit the seat and its hand. Got %+v", seat)
+	}
+	if gameState.CurrentPlayer != "newcomer" {
+		t.Errorf("Expected the newcomer to inherit the current turn. Got %s", gameState.CurrentPlayer)
+	}
+	if len(gameState.OpenSeats()) != 0 {
+		t.Errorf("Expected no open seats to be left")
+	}
+}
+
+func TestForPlayer(t *testing.T) {
+	hand := Game.View{Id: "hand", Pieces: Pieces.PieceSet{Orphans: []Pieces.Card{{GamePiece: Pieces.GamePiece{Id: "secret"}}}}}
+	gameState := GameState{
+		Players: []Player.Player{
+			{Id: "me", Name: "me", Hand: []Game.View{hand}},
+			{Id: "bot", Name: "bot", Hand: []Game.View{hand}, SeatStatus: Player.SeatStatus_Bot, Bot: "Random"},
+		},
+		Views: []Game.View{{Id: "table"}},
+	}
+
+	projection := gameState.ForPlayer("me")
+	if len(projection.Players[0].Hand) != 1 || len(projection.Views) != 1 {
+		t.Errorf("Expected the player to see their own hand and the table. Got %+v", projection)
+	}
+	if len(projection.Players[1].Hand) != 0 || projection.Players[1].Bot != "Random" || projection.Players[1].SeatStatus != Player.SeatStatus_Bot {
+		t.Errorf("Expected everyone else's hand to be left out, but not who they are. Got %+v", projection.Players[1])
+	}
+	if len(gameState.Players[1].Hand) != 1 {
+		t.Errorf("Projecting shouldn't change the GameState itself")
+	}
+}
+
+func TestEndTurn_BotSeats(t *testing.T) {
+	gameState := GameState{
+		Players: []Player.Player{
+			{Id: "person"},
+			{Id: "frozen", SeatStatus: Player.SeatStatus_Frozen},
+			{Id: "bot", SeatStatus: Player.SeatStatus_Bot},
+		},
+		CurrentPlayer: "person",
+	}
+
+	changelog, err := EndTurn{}.Execute(&gameState, "person")
+	if err != nil || changelog.CurrentPlayer != "bot" {
+		t.Errorf("Expected the turn to skip the frozen seat and go to the bot. Got %s (%v)", changelog.CurrentPlayer, err)
+	}
+	if _, err := (EndTurn{NextPlayer: "person"}).Execute(&gameState, "bot"); err != nil {
+		t.Errorf("Expected a bot to be able to pass the turn, Got %s", err)
+	}
+}
+
+func TestEndTurn_TurnDeadline(t *testing.T) {
+	gameState := GameState{
+		Players:       []Player.Player{{Id: "first"}, {Id: "second"}},
+		CurrentPlayer: "first",
+		Rules:         Game.GameRules{TurnTimeLimit: 60},
+	}
+
+	before := time.Now()
+	changelog, err := EndTurn{}.Execute(&gameState, "first")
+	if err != nil {
+		t.Fatalf("Expected the turn to pass, Got %s", err)
+	}
+	if changelog.TurnDeadline == nil || changelog.TurnDeadline != gameState.TurnDeadline || changelog.TurnDeadline.Before(before.Add(59*time.Second)) {
+		t.Errorf("Expected the next player to get the full time limit, Got %v", changelog.TurnDeadline)
+	}
+
+	gameState.Rules.TurnTimeLimit = 0
+	if changelog, _ := (EndTurn{}).Execute(&gameState, "second"); changelog.TurnDeadline != nil {
+		t.Errorf("Expected no deadline without a time limit, Got %v", changelog.TurnDeadline)
+	}
+}
@@ -0,0 +1,65 @@
+package Session
+
+import (
+	"candlelight-models/Game"
+	"candlelight-models/Player"
+	"slices"
+)
+
+// Returns a copy of the GameState as it should be shown to spectators. Unless the game's SpectatorVisibility is SpectatorVisibility_Everything,
+// every player's hand is left out
+func (gameState GameState) ForSpectators() GameState {
+	if gameState.Rules.SpectatorVisibility == Game.SpectatorVisibility_Everything {
+		return gameState
+	}
+
+	projection := gameState
+	projection.Players = make([]Player.Player, len(gameState.Players))
+	for index, player := range gameState.Players {
+		projection.Players[index] = Player.Player{
+			Id:         player.Id,
+			Name:       player.Name,
+			Hand:       []Game.View{},
+			SeatStatus: player.SeatStatus,
+			Bot:        player.Bot,
+		}
+	}
+	return projection
+}
+
+// Returns a copy of the GameState as [playerId] should know it, for bots deciding what to do. Every other player's hand is left out
+func (gameState GameState) ForPlayer(playerId string) GameState {
+	projection := gameState
+	projection.Players = make([]Player.Player, len(gameState.Players))
+	for index, player := range gameState.Players {
+		if player.Id == playerId {
+			projection.Players[index] = player
+			continue
+		}
+		projection.Players[index] = Player.Player{
+			Id:         player.Id,
+			Name:       player.Name,
+			Hand:       []Game.View{},
+			SeatStatus: player.SeatStatus,
+			Bot:        player.Bot,
+		}
+	}
+	return projection
+}
+
+// Returns a copy of the Changelog as it should be shown to spectators of [gameState]. Unless the game's SpectatorVisibility is
+// SpectatorVisibility_Everything, any View that isn't public (i.e. one in a player's hand) is left out
+func (changelog Changelog) ForSpectators(gameState GameState) Changelog {
+	if gameState.Rules.SpectatorVisibility == Game.SpectatorVisibility_Everything {
+		return changelog
+	}
+
+	projection := changelog
+	projection.Views = []*Game.View{}
+	for _, view := range changelog.Views {
+		if slices.ContainsFunc(gameState.Views, func(v Game.View) bool { return v.Id == view.Id }) {
+			projection.Views = append(projection.Views, view)
+		}
+	}
+	return projection
+}
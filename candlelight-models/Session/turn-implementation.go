@@ -13,11 +13,11 @@ func (ins Insertion) Execute(gameState *GameState, playerId string) (Changelog,
 		CurrentPlayer:    gameState.CurrentPlayer,
 		MostRecentAction: "",
 	}
-	var err error = nil
+	var err *GameError = nil
 
 	playerToUse := findPlayerInGameState(playerId, gameState)
 	if playerToUse == nil {
-		return changelog, fmt.Errorf("could not find player in gamestate")
+		return changelog, NewGameError(ErrorCode_PlayerNotFound, "Could not find you in the game!", "playerId", playerId)
 	}
 
 	//IMPORTANT: DO ALL ERROR-CHECKING BEFORE CHANGING THE GAMESTATE
@@ -25,14 +25,14 @@ func (ins Insertion) Execute(gameState *GameState, playerId string) (Changelog,
 	//Check for Views first so we can get them in the Changelog if applicable
 	takingFromView := findView(gameState, playerToUse, ins.FromView)
 	if takingFromView == nil {
-		err = fmt.Errorf("could not find View to take from with Id == {%s}", ins.FromView)
+		err = NewGameError(ErrorCode_ViewNotFound, "Could not find the View to take from!", "viewId", ins.FromView)
 	} else {
 		changelog.Views = append(changelog.Views, takingFromView)
 	}
 
 	intoView := findView(gameState, playerToUse, ins.InView)
 	if intoView == nil {
-		err = fmt.Errorf("could not find View to insert into with Id == {%s}", ins.InView)
+		err = NewGameError(ErrorCode_ViewNotFound, "Could not find the View to insert into!", "viewId", ins.InView)
 	} else {
 		changelog.Views = append(changelog.Views, intoView)
 	}
@@ -43,12 +43,15 @@ func (ins Insertion) Execute(gameState *GameState, playerId string) (Changelog,
 
 	cardToInsert := findCardInOrphans(ins.InsertCard, takingFromView)
 	if cardToInsert == nil {
-		return changelog, fmt.Errorf("could not find card to insert with Id == {%s} in given View", ins.InsertCard)
+		return changelog, NewGameError(ErrorCode_CardNotFound, "Could not find the card to insert!", "cardId", ins.InsertCard, "viewId", ins.FromView)
 	}
 
 	intoCollection := findCollectionInView(ins.ToCollection, intoView)
 	if intoCollection == nil {
-		return changelog, fmt.Errorf("could not find Collection to insert with with Id == {%s} in given View", ins.ToCollection)
+		return changelog, NewGameError(ErrorCode_CollectionNotFound, "Could not find the collection to insert the card into!", "collectionId", ins.ToCollection, "viewId", ins.InView)
+	}
+	if !intoCollection.CardIsAllowed(cardToInsert) {
+		return changelog, NewGameError(ErrorCode_CardNotAllowed, "That card isn't allowed in that collection!", "cardId", ins.InsertCard, "collectionId", ins.ToCollection)
 	}
 
 	//Also important. Copy the card since slices.DeleteFunc will 0 out that location in memory, and update the copy with its new ParentViewId
@@ -71,26 +74,26 @@ func (with Withdrawal) Execute(gameState *GameState, playerId string) (Changelog
 		CurrentPlayer:    gameState.CurrentPlayer,
 		MostRecentAction: "",
 	}
-	var err error = nil
+	var err *GameError = nil
 
 	//IMPORTANT: DO ALL ERROR-CHECKING BEFORE CHANGING THE GAMESTATE
 
 	playerToUse := findPlayerInGameState(playerId, gameState)
 	if playerToUse == nil {
-		return changelog, fmt.Errorf("could not find player in gamestate")
+		return changelog, NewGameError(ErrorCode_PlayerNotFound, "Could not find you in the game!", "playerId", playerId)
 	}
 
 	//Check for Views first so we can get them in the Changelog if applicable
 	takingFromView := findView(gameState, playerToUse, with.InView)
 	if takingFromView == nil {
-		err = fmt.Errorf("could not find View to take from with Id == {%s}", with.InView)
+		err = NewGameError(ErrorCode_ViewNotFound, "Could not find the View to take from!", "viewId", with.InView)
 	} else {
 		changelog.Views = append(changelog.Views, takingFromView)
 	}
 
 	intoView := findView(gameState, playerToUse, with.ToView)
 	if intoView == nil {
-		err = fmt.Errorf("could not find View to insert into with Id == {%s}", with.ToView)
+		err = NewGameError(ErrorCode_ViewNotFound, "Could not find the View to insert into!", "viewId", with.ToView)
 	} else {
 		changelog.Views = append(changelog.Views, intoView)
 	}
@@ -101,7 +104,7 @@ func (with Withdrawal) Execute(gameState *GameState, playerId string) (Changelog
 
 	fromCollection := findCollectionInView(with.FromCollection, takingFromView)
 	if fromCollection == nil {
-		return changelog, fmt.Errorf("could not find Collection to withdraw from with with Id == {%s} in given View", with.FromCollection)
+		return changelog, NewGameError(ErrorCode_CollectionNotFound, "Could not find the collection to draw from!", "collectionId", with.FromCollection, "viewId", with.InView)
 	}
 
 	var cardToWithdraw *Pieces.Card = nil
@@ -111,7 +114,7 @@ func (with Withdrawal) Execute(gameState *GameState, playerId string) (Changelog
 		cardToWithdraw = fromCollection.FindCardInCollection(with.WithdrawCard)
 	}
 	if cardToWithdraw == nil {
-		return changelog, fmt.Errorf("could not find Card to withdraw with Id == {%s} in given Collection", with.WithdrawCard)
+		return changelog, NewGameError(ErrorCode_CardNotFound, "Could not find the card to draw!", "cardId", with.WithdrawCard, "collectionId", with.FromCollection)
 	}
 
 	//Also important: Make a copy of the card and update the copy's ParentViewId, as well as setting the Position to the Collection's X/Y to make it appear on top
@@ -136,23 +139,23 @@ func (move Movement) Execute(gameState *GameState, playerId string) (Changelog,
 		CurrentPlayer:    gameState.CurrentPlayer,
 		MostRecentAction: "",
 	}
-	var err error = nil
+	var err *GameError = nil
 
 	playerToUse := findPlayerInGameState(playerId, gameState)
 	if playerToUse == nil {
-		return changelog, fmt.Errorf("could not find player in gamestate")
+		return changelog, NewGameError(ErrorCode_PlayerNotFound, "Could not find you in the game!", "playerId", playerId)
 	}
 
 	takingFromView := findView(gameState, playerToUse, move.FromView)
 	if takingFromView == nil {
-		err = fmt.Errorf("could not find View to take from with Id == {%s}", move.FromView)
+		err = NewGameError(ErrorCode_ViewNotFound, "Could not find the View to take from!", "viewId", move.FromView)
 	} else {
 		changelog.Views = append(changelog.Views, takingFromView)
 	}
 
 	intoView := findView(gameState, playerToUse, move.ToView)
 	if intoView == nil {
-		err = fmt.Errorf("could not find View to insert into with Id == {%s}", move.ToView)
+		err = NewGameError(ErrorCode_ViewNotFound, "Could not find the View to insert into!", "viewId", move.ToView)
 	} else {
 		changelog.Views = append(changelog.Views, intoView)
 	}
@@ -163,7 +166,7 @@ func (move Movement) Execute(gameState *GameState, playerId string) (Changelog,
 
 	pieceToMove := findCardInOrphans(move.CardId, takingFromView)
 	if pieceToMove == nil {
-		return changelog, fmt.Errorf("could not find Card to move with Id == {%s} in given View", move.CardId)
+		return changelog, NewGameError(ErrorCode_CardNotFound, "Could not find the card to move!", "cardId", move.CardId, "viewId", move.FromView)
 	}
 
 	//Copy card and update ParentViewId and Position data
@@ -191,7 +194,7 @@ func (et EndTurn) Execute(gameState *GameState, playerId string) (Changelog, err
 	currentPlayerIndex := slices.IndexFunc(gameState.Players, func(p Player.Player) bool { return p.Id == gameState.CurrentPlayer })
 
 	if currentPlayerIndex < 0 {
-		return changelog, fmt.Errorf("could not find current player in gameState.Players")
+		return changelog, NewGameError(ErrorCode_PlayerNotFound, "Could not find the player whose turn it is!", "playerId", gameState.CurrentPlayer)
 	}
 
 	nextPlayerIndex := currentPlayerIndex //Default to not changing anything if something goes wrong
@@ -199,21 +202,30 @@ func (et EndTurn) Execute(gameState *GameState, playerId string) (Changelog, err
 		nextPlayerIndex = slices.IndexFunc(gameState.Players, func(p Player.Player) bool { return p.Id == et.NextPlayer })
 
 		if nextPlayerIndex < 0 {
-			return changelog, fmt.Errorf("could not find player to give turn to")
+			return changelog, NewGameError(ErrorCode_PlayerNotFound, "Could not find the player to give the turn to!", "playerId", et.NextPlayer)
+		}
+		if !gameState.Players[nextPlayerIndex].TakesTurns() {
+			return changelog, NewGameError(ErrorCode_SeatVacant, "That player has left the game!", "playerId", et.NextPlayer)
 		}
 	} else {
-		//Get ID of next player (wrap if necessary)
-		nextPlayerIndex = currentPlayerIndex + 1
-		if nextPlayerIndex >= len(gameState.Players) {
-			nextPlayerIndex -= len(gameState.Players)
+		//Get ID of next player (wrap if necessary), skipping any seats whose players have left without a bot taking over. If every other seat is empty,
+		//the turn stays put
+		for offset := 1; offset <= len(gameState.Players); offset++ {
+			index := (currentPlayerIndex + offset) % len(gameState.Players)
+			if gameState.Players[index].TakesTurns() {
+				nextPlayerIndex = index
+				break
+			}
 		}
 	}
 
 	nextPlayerId := gameState.Players[nextPlayerIndex].Id
 
-	//Update gameState and changelog
+	//Update gameState and changelog. The next player gets a fresh clock, even if the turn came straight back to the same player
 	gameState.CurrentPlayer = nextPlayerId
+	gameState.StartTurnClock()
 	changelog.CurrentPlayer = nextPlayerId
+	changelog.TurnDeadline = gameState.TurnDeadline
 
 	changelog.MostRecentAction = fmt.Sprintf("Player '%s' ended their turn. Next player is '%s'", gameState.Players[currentPlayerIndex].Name, gameState.Players[nextPlayerIndex].Name)
 
@@ -229,13 +241,13 @@ func (cf Cardflip) Execute(gameState *GameState, playerId string) (Changelog, er
 	player := findPlayerInGameState(playerId, gameState)
 
 	if player == nil {
-		return changelog, fmt.Errorf("could not find player in GameState")
+		return changelog, NewGameError(ErrorCode_PlayerNotFound, "Could not find you in the game!", "playerId", playerId)
 	}
 
 	parentView := findView(gameState, player, cf.InView)
 
 	if parentView == nil {
-		return changelog, fmt.Errorf("could not find view with Id == {%s} in GameState", cf.InView)
+		return changelog, NewGameError(ErrorCode_ViewNotFound, "Could not find the View that card is in!", "viewId", cf.InView)
 	}
 
 	changelog.Views = append(changelog.Views, parentView)
@@ -243,7 +255,7 @@ func (cf Cardflip) Execute(gameState *GameState, playerId string) (Changelog, er
 	cardToFlip := findCardInOrphans(cf.FlipCard, parentView)
 
 	if cardToFlip == nil {
-		return changelog, fmt.Errorf("could not find card with Id == {%s} in View", cf.FlipCard)
+		return changelog, NewGameError(ErrorCode_CardNotFound, "Could not find the card to flip!", "cardId", cf.FlipCard, "viewId", cf.InView)
 	}
 
 	cardToFlip.Flipped = !cardToFlip.Flipped
@@ -257,11 +269,11 @@ func (reshuffle Reshuffle) Execute(gameState *GameState, playerId string) (Chang
 	changelog := Changelog{
 		CurrentPlayer: gameState.CurrentPlayer,
 	}
-	var err error = nil
+	var err *GameError = nil
 
 	playerToUse := findPlayerInGameState(playerId, gameState)
 	if playerToUse == nil {
-		return changelog, fmt.Errorf("could not find player in gamestate")
+		return changelog, NewGameError(ErrorCode_PlayerNotFound, "Could not find you in the game!", "playerId", playerId)
 	}
 
 	//IMPORTANT: DO ALL ERROR-CHECKING BEFORE CHANGING THE GAMESTATE
@@ -269,14 +281,14 @@ func (reshuffle Reshuffle) Execute(gameState *GameState, playerId string) (Chang
 	//Check for Views first so we can get them in the Changelog if applicable
 	takingFromView := findView(gameState, playerToUse, reshuffle.InView)
 	if takingFromView == nil {
-		err = fmt.Errorf("could not find View to take from with Id == {%s}", reshuffle.InView)
+		err = NewGameError(ErrorCode_ViewNotFound, "Could not find the View to take from!", "viewId", reshuffle.InView)
 	} else {
 		changelog.Views = append(changelog.Views, takingFromView)
 	}
 
 	toView := findView(gameState, playerToUse, reshuffle.ToView)
 	if toView == nil {
-		err = fmt.Errorf("could not find View to insert into with Id == {%s}", reshuffle.ToView)
+		err = NewGameError(ErrorCode_ViewNotFound, "Could not find the View to insert into!", "viewId", reshuffle.ToView)
 	} else {
 		changelog.Views = append(changelog.Views, toView)
 	}
@@ -289,13 +301,13 @@ func (reshuffle Reshuffle) Execute(gameState *GameState, playerId string) (Chang
 
 	reshuffleCardPlace, ok := reshuffleCollection.(*Pieces.CardPlace)
 	if !ok {
-		return changelog, fmt.Errorf("could not find CardPlace to reshuffle in given View")
+		return changelog, NewGameError(ErrorCode_CollectionNotFound, "Could not find the CardPlace to reshuffle!", "collectionId", reshuffle.ShuffleCardPlace, "viewId", reshuffle.InView)
 	}
 
 	intoCollection := findCollectionInView(reshuffle.IntoDeck, toView)
 	reshuffleDeck, ok := intoCollection.(*Pieces.Deck)
 	if !ok {
-		return changelog, fmt.Errorf("could not find Deck to reshuffle into with Id == {%s} in given View", reshuffle.IntoDeck)
+		return changelog, NewGameError(ErrorCode_CollectionNotFound, "Could not find the Deck to reshuffle into!", "collectionId", reshuffle.IntoDeck, "viewId", reshuffle.ToView)
 	}
 
 	transferAllCards(reshuffleCardPlace, reshuffleDeck)
@@ -1,28 +1,155 @@
 package Sparks
 
-//A collection of all Sparks available to a user
-type Sparks struct {
-	Dealer  Dealer  `json:"dealer"`
-	Flipper Flipper `json:"flipper"`
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Supported values for Spark.Type. Make sure this matches up with the object you put
+// in the Config field. The RuleEngine keeps a registry mapping each of these to its implementation
+const (
+	SparkType_Dealer         = "Dealer"
+	SparkType_Flipper        = "Flipper"
+	SparkType_Shuffler       = "Shuffler"
+	SparkType_StartingPlayer = "StartingPlayer"
+	SparkType_DeckBuilder    = "DeckBuilder"
+)
+
+// Supported values for StartingPlayer.Mode
+const (
+	//The first player in the GameState's player list goes first. This is what happens if no StartingPlayer Spark is configured
+	StartingPlayerMode_First = "First"
+	//A random player goes first
+	StartingPlayerMode_Random = "Random"
+	//The host of the Lobby goes first
+	StartingPlayerMode_Host = "Host"
+	//The host picks who goes first (i.e. "youngest player goes first") by supplying a player's Id when they start the game
+	StartingPlayerMode_Chosen = "Chosen"
+)
+
+// Supported values for the WhenEmpty field of Sparks that take cards from a Deck, describing what to do when that Deck runs out of cards
+const (
+	//Fail the Spark, and so the start of the game, if the Deck runs out. This is a Dealer's default, since starting with uneven hands is rarely what
+	//a game's author meant
+	WhenEmpty_Fail = "Fail"
+	//Stop taking cards once the Deck is empty, even if that leaves some players with fewer cards than others. This is a Flipper's default
+	WhenEmpty_Stop = "Stop"
+	//Before taking any cards, lower the number taken per player so that everyone receives the same amount
+	WhenEmpty_DealEvenly = "DealEvenly"
+	//Reshuffle every card in [DiscardPile] back into the Deck, then keep going. Behaves like WhenEmpty_Stop if the Deck is still empty afterwards
+	WhenEmpty_Reshuffle = "Reshuffle"
+)
+
+// A single automation that runs when the game starts. Works just like a SubmittedAction: [Type] tells the
+// RuleEngine which of the below structs to unmarshal [Config] into
+type Spark struct {
+	//One of the above constants. Tells the RuleEngine what type of struct is in the Config field
+	Type string `json:"type"`
+	//The actual configuration object. Should have all the fields within the struct matching [Type]
+	Config json.RawMessage `json:"config"`
+}
+
+// All Sparks configured for a Game, in the order they should be applied
+type Sparks []Spark
+
+// Builds a Spark of the given [sparkType] out of [config]. Panics if [config] can't be marshaled, which should never happen for the structs in this package
+func NewSpark(sparkType string, config any) Spark {
+	asJson, err := json.Marshal(config)
+	if err != nil {
+		panic(err)
+	}
+	return Spark{
+		Type:   sparkType,
+		Config: asJson,
+	}
 }
 
-//A Dealer will move [NumToDeal] random cards from [DeckToUse] to each player in the game
+// Game definitions saved before Sparks became a list stored them as an object with one field per Spark. This is
+// that object, kept around solely so those definitions can still be loaded
+type legacySparks struct {
+	Dealer struct {
+		Enabled bool `json:"enabled"`
+		Dealer
+	} `json:"dealer"`
+	Flipper struct {
+		Enabled bool `json:"enabled"`
+		Flipper
+	} `json:"flipper"`
+}
+
+// Accepts either the current list format or the legacy {dealer, flipper} object. Legacy Sparks are converted into
+// list entries (Dealer first, then Flipper, the same order they were always applied in), skipping any that weren't enabled
+func (s *Sparks) UnmarshalJSON(data []byte) error {
+	var list []Spark
+	listErr := json.Unmarshal(data, &list)
+	if listErr == nil {
+		*s = list
+		return nil
+	}
+
+	legacy := legacySparks{}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return fmt.Errorf("sparks are neither a list nor a legacy sparks object: %s", listErr)
+	}
+
+	converted := Sparks{}
+	if legacy.Dealer.Enabled {
+		converted = append(converted, NewSpark(SparkType_Dealer, legacy.Dealer.Dealer))
+	}
+	if legacy.Flipper.Enabled {
+		converted = append(converted, NewSpark(SparkType_Flipper, legacy.Flipper.Flipper))
+	}
+	*s = converted
+	return nil
+}
+
+// A Dealer will move [NumToDeal] random cards from [DeckToUse] to each player in the game, one card at a time around the table
 type Dealer struct {
-	//Whether to perform this Spark
-	Enabled bool `json:"enabled"`
 	//Number of random cards to deal to each player
 	NumToDeal int `json:"numToDeal"`
 	//Id of the Deck from which each card should come from
 	DeckToUse string `json:"deckToUse"`
+	//Ids of the Views (or View templates) the cards should be dealt into. Each player is dealt into the first View in their Hand matching one of these. If empty, the first View in their Hand is used
+	ToViews []string `json:"toViews"`
+	//One of the WhenEmpty constants above. Defaults to WhenEmpty_Fail
+	WhenEmpty string `json:"whenEmpty"`
+	//Id of the CardPlace to reshuffle into [DeckToUse] when it runs out. Only used with WhenEmpty_Reshuffle
+	DiscardPile string `json:"discardPile"`
 }
 
+// A Flipper will move [NumToFlip] random cards from [DeckToUse] into [CardPlaceToUse]
 type Flipper struct {
-	//Whether to perform this Spark
-	Enabled bool `json:"enabled"`
 	//How many cards to move from [DeckToUse] to [CardPlaceToUse]
 	NumToFlip int `json:"numToFlip"`
 	//The Deck to take cards from
 	DeckToUse string `json:"deckToUse"`
 	//The CardPlace to put the cards in
 	CardPlaceToUse string `json:"cardPlaceToUse"`
+	//One of the WhenEmpty constants above. Defaults to WhenEmpty_Stop. WhenEmpty_DealEvenly behaves the same as WhenEmpty_Stop, since there's only one CardPlace
+	WhenEmpty string `json:"whenEmpty"`
+	//Id of the CardPlace to reshuffle into [DeckToUse] when it runs out. Only used with WhenEmpty_Reshuffle
+	DiscardPile string `json:"discardPile"`
+}
+
+// A Shuffler will shuffle the order of the cards in each Deck listed in [DecksToShuffle]
+type Shuffler struct {
+	//Ids of the Decks to shuffle
+	DecksToShuffle []string `json:"decksToShuffle"`
+}
+
+// A StartingPlayer decides whose turn it is when the game starts
+type StartingPlayer struct {
+	//One of the StartingPlayerMode constants above
+	Mode string `json:"mode"`
+}
+
+// A DeckBuilder gives every player their own copy of [TemplateDeck], placed in one of their hand Views. All copied
+// pieces are given fresh Ids so that no two players share a Deck or Card
+type DeckBuilder struct {
+	//Id of the Deck to copy for each player
+	TemplateDeck string `json:"templateDeck"`
+	//Ids of the Views (or View templates) the copy should be placed in. Each player's copy goes into the first View in their Hand matching one of these. If empty, the first View in their Hand is used
+	ToViews []string `json:"toViews"`
+	//Whether [TemplateDeck] should be left where it is after copying. By default, it is removed from the game
+	KeepTemplate bool `json:"keepTemplate"`
 }
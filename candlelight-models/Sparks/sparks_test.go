@@ -0,0 +1,81 @@
+package Sparks
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSparks_UnmarshalJSON(t *testing.T) {
+	var tests = []struct {
+		Name              string
+		Json              string
+		ExpectedTypes     []string
+		ShouldReturnError bool
+	}{
+		{
+			Name:          "List Format",
+			Json:          `[{"type":"Flipper","config":{"numToFlip":1}},{"type":"Dealer","config":{"numToDeal":5}}]`,
+			ExpectedTypes: []string{SparkType_Flipper, SparkType_Dealer},
+		},
+		{
+			Name:          "Legacy Format, Both Enabled",
+			Json:          `{"dealer":{"enabled":true,"numToDeal":5,"deckToUse":"deck"},"flipper":{"enabled":true,"numToFlip":1,"deckToUse":"deck","cardPlaceToUse":"place"}}`,
+			ExpectedTypes: []string{SparkType_Dealer, SparkType_Flipper},
+		},
+		{
+			Name:          "Legacy Format, Only Flipper Enabled",
+			Json:          `{"dealer":{"enabled":false,"numToDeal":5,"deckToUse":"deck"},"flipper":{"enabled":true,"numToFlip":1,"deckToUse":"deck","cardPlaceToUse":"place"}}`,
+			ExpectedTypes: []string{SparkType_Flipper},
+		},
+		{
+			Name:          "Legacy Format, None Enabled",
+			Json:          `{"dealer":{"enabled":false},"flipper":{"enabled":false}}`,
+			ExpectedTypes: []string{},
+		},
+		{
+			Name:              "Neither Format",
+			Json:              `"dealer"`,
+			ShouldReturnError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			sparks := Sparks{}
+			err := json.Unmarshal([]byte(tt.Json), &sparks)
+
+			if (err != nil) != tt.ShouldReturnError {
+				t.Fatalf("Error value: Expected {%t}, Got {%s}", tt.ShouldReturnError, err)
+			}
+			if tt.ShouldReturnError {
+				return
+			}
+
+			if len(sparks) != len(tt.ExpectedTypes) {
+				t.Fatalf("Expected %d Sparks, Got %d", len(tt.ExpectedTypes), len(sparks))
+			}
+			for index, spark := range sparks {
+				if spark.Type != tt.ExpectedTypes[index] {
+					t.Errorf("Spark #%d type mismatch! Expected {%s}, Got {%s}", index, tt.ExpectedTypes[index], spark.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestSparks_LegacyConfigIsPreserved(t *testing.T) {
+	sparks := Sparks{}
+	err := json.Unmarshal([]byte(`{"dealer":{"enabled":true,"numToDeal":5,"deckToUse":"deck"}}`), &sparks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dealer := Dealer{}
+	if err := json.Unmarshal(sparks[0].Config, &dealer); err != nil {
+		t.Fatal(err)
+	}
+
+	if dealer.NumToDeal != 5 || dealer.DeckToUse != "deck" {
+		t.Errorf("Dealer config was not carried over! Got %+v", dealer)
+	}
+}
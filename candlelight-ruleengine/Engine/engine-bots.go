@@ -0,0 +1,290 @@
+package Engine
+
+import (
+	"candlelight-api/LogUtil"
+	"candlelight-models/Pieces"
+	"candlelight-models/Player"
+	"candlelight-models/Session"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Strategies bots can be added to a lobby with. More can be added through RegisterBot
+const (
+	//Picks any action it's allowed to take at random, including ending its turn. This is the default
+	BotStrategy_Random = "Random"
+	//Takes whichever action raises the score of its hand the most, and ends its turn once nothing would. See GreedyBot
+	BotStrategy_Greedy = "Greedy"
+)
+
+// The most actions a bot may take in one turn before its turn is ended for it, so a bot that never chooses EndTurn can't stall the game
+const maxBotActionsPerTurn = 20
+
+// Something that can play a seat in place of a person
+type Bot interface {
+	//Picks the next action for [playerId] to take, given [gameState] as [playerId] knows it (see GameState.ForPlayer). The action's PlayerId is
+	//filled in by the engine. Once it has nothing left to do, a bot should choose an EndTurn
+	ChooseAction(gameState Session.GameState, playerId string) (Session.SubmittedAction, error)
+}
+
+// Maps each bot strategy to the Bot that plays it. Add new strategies here (or through RegisterBot)
+var botRegistry = map[string]Bot{
+	BotStrategy_Random: RandomBot{},
+	BotStrategy_Greedy: GreedyBot{ScoreTag: "score"},
+}
+
+// Registers [bot] under [strategy], replacing any Bot already registered for that strategy
+func RegisterBot(strategy string, bot Bot) {
+	botRegistry[strategy] = bot
+}
+
+// Returns the Bot playing [player]'s seat. Seats a bot took over from a player who left (and any whose strategy is no longer registered) are
+// played with BotStrategy_Random
+func botFor(player Player.Player) Bot {
+	if bot, exists := botRegistry[player.Bot]; exists {
+		return bot
+	}
+	return botRegistry[BotStrategy_Random]
+}
+
+// Adds a bot playing with [strategy] to the lobby with [roomCode]. Only the host, [hostId], may do this, and only before the game starts. If [name] is
+// empty, the bot is given one. Bots are always ready, and take up a seat like any other player. Returns the new state of the lobby
+func AddBot(roomCode string, hostId string, strategy string, name string) (Session.Lobby, error) {
+	funcLogPrefix := "==AddBot=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, err
+	}
+
+	if lobby.Host.Id != hostId {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_NotHost, "Only the host can add bots!")
+	}
+	if lobby.Status != Session.LobbyStatus_AwaitingStart {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_GameAlreadyStarted, "Bots can't be added once the game has started!")
+	}
+	if lobby.NumPlayers >= lobby.MaxPlayers {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_LobbyFull, fmt.Sprintf("Lobby's max player count {%d} already reached", lobby.MaxPlayers), "maxPlayers", strconv.Itoa(lobby.MaxPlayers))
+	}
+	if strategy == "" {
+		strategy = BotStrategy_Random
+	}
+	if _, exists := botRegistry[strategy]; !exists {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_UnknownBotStrategy, fmt.Sprintf("Bot strategy {%s} not recognized", strategy), "strategy", strategy)
+	}
+
+	nameTaken := func(name string) bool {
+		return slices.ContainsFunc(lobby.Players, func(p Player.Player) bool { return strings.EqualFold(p.Name, name) })
+	}
+	if strings.TrimSpace(name) == "" {
+		for number := 1; name == "" || nameTaken(name); number++ {
+			name = fmt.Sprintf("%s Bot %d", strategy, number)
+		}
+	} else if nameTaken(name) {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_NameTaken, "Name already taken!", "playerName", name)
+	}
+
+	bot := createPlayerObject(name)
+	bot.Bot = strategy
+
+	lobby.Players = append(slices.Clone(lobby.Players), bot)
+	lobby.NumPlayers = len(lobby.Players)
+	if lobby.Ready == nil {
+		lobby.Ready = map[string]bool{}
+	}
+	lobby.Ready[bot.Id] = true
+
+	log.Printf("%s Host has added a %s bot {%s} to lobby {%s}", funcLogPrefix, strategy, bot.Id, roomCode)
+	return SaveLobbyInRedis(lobby)
+}
+
+// Plays every turn that belongs to a bot in the GameState with [gameId], one after another, until it's a person's turn. To keep a table of nothing but bots
+// from playing forever, each seat gets at most one turn per call. In a game played in SimultaneousRounds, every bot commits to an action for the current
+// round instead, and at most one round is revealed per call. Returns the Changelog of every action the bots took, in order
+func RunBotTurns(gameId string) ([]Session.Changelog, error) {
+	funcLogPrefix := "==RunBotTurns=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	changelogs := []Session.Changelog{}
+
+	gameState, err := GetCachedGameStateFromRedis(gameId)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return changelogs, err
+	}
+
+	if gameState.Rules.SimultaneousRounds {
+		changelogs, err = commitBotActions(gameState)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+		}
+		return changelogs, err
+	}
+
+	for turns := 0; turns < len(gameState.Players); turns++ {
+		seat := findSeat(gameState, gameState.CurrentPlayer)
+		if seat == nil || seat.SeatStatus != Player.SeatStatus_Bot {
+			break
+		}
+
+		played, err := playBotTurn(gameId, *seat)
+		changelogs = append(changelogs, played...)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+			return changelogs, err
+		}
+
+		gameState, err = GetCachedGameStateFromRedis(gameId)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+			return changelogs, err
+		}
+		//Nobody else could be given the turn
+		if gameState.CurrentPlayer == seat.Id {
+			break
+		}
+	}
+
+	return changelogs, nil
+}
+
+// Lets the bot in [seat] take actions until it ends its turn. Any action the engine rejects, and running out of actions, ends its turn for it
+func playBotTurn(gameId string, seat Player.Player) ([]Session.Changelog, error) {
+	funcLogPrefix := "==playBotTurn=="
+
+	changelogs := []Session.Changelog{}
+	bot := botFor(seat)
+
+	for actions := 0; actions < maxBotActionsPerTurn; actions++ {
+		gameState, err := GetCachedGameStateFromRedis(gameId)
+		if err != nil {
+			return changelogs, err
+		}
+
+		action, err := bot.ChooseAction(gameState.ForPlayer(seat.Id), seat.Id)
+		if err != nil {
+			LogError(funcLogPrefix, fmt.Errorf("bot {%s} couldn't choose an action: %s", seat.Id, err))
+			break
+		}
+		action.PlayerId = seat.Id
+
+		changelog, err := SubmitAction(gameId, action)
+		if err != nil {
+			log.Printf("%s Bot {%s}'s %s was rejected: %s", funcLogPrefix, seat.Id, action.Type, err)
+			break
+		}
+		changelogs = append(changelogs, changelog)
+
+		if action.Type == Session.ActionType_EndTurn {
+			return changelogs, nil
+		}
+	}
+
+	changelog, err := SubmitAction(gameId, endTurnAction(seat.Id))
+	if err != nil {
+		return changelogs, err
+	}
+	return append(changelogs, changelog), nil
+}
+
+// Plays a random action out of everything it's allowed to do (see LegalActions), including ending its turn
+type RandomBot struct{}
+
+func (bot RandomBot) ChooseAction(gameState Session.GameState, playerId string) (Session.SubmittedAction, error) {
+	actions := LegalActions(gameState, playerId)
+	if len(actions) == 0 {
+		return endTurnAction(playerId), nil
+	}
+	return actions[rand.Intn(len(actions))], nil
+}
+
+// Plays whichever action leaves the cards in its hand worth the most, adding up the number in each card's [ScoreTag] tag. Cards without the tag
+// (or with a tag that isn't a number) are worth nothing. Ends its turn once no action would raise its score
+type GreedyBot struct {
+	//The tag holding how much a card is worth
+	ScoreTag string
+}
+
+func (bot GreedyBot) ChooseAction(gameState Session.GameState, playerId string) (Session.SubmittedAction, error) {
+	best := endTurnAction(playerId)
+	bestScore := bot.score(gameState, playerId)
+
+	for _, action := range LegalActions(gameState, playerId) {
+		if action.Type == Session.ActionType_EndTurn {
+			continue
+		}
+		trial, err := cloneGameState(gameState)
+		if err != nil {
+			return best, err
+		}
+		if _, err := applyAction(&trial, action); err != nil {
+			continue
+		}
+		if score := bot.score(trial, playerId); score > bestScore {
+			best, bestScore = action, score
+		}
+	}
+	return best, nil
+}
+
+// Adds up the worth of every card in [playerId]'s hand
+func (bot GreedyBot) score(gameState Session.GameState, playerId string) float64 {
+	seat := findSeat(gameState, playerId)
+	if seat == nil {
+		return 0
+	}
+
+	total := 0.0
+	for _, view := range seat.Hand {
+		cards := slices.Clone(view.Pieces.Orphans)
+		for _, collection := range view.Pieces.GetCollections() {
+			cards = append(cards, collectionCards(collection)...)
+		}
+		for _, card := range cards {
+			if worth, err := strconv.ParseFloat(card.Tags[bot.ScoreTag], 64); err == nil {
+				total += worth
+			}
+		}
+	}
+	return total
+}
+
+// Returns the Player in [gameState] with [playerId], or nil if there isn't one
+func findSeat(gameState Session.GameState, playerId string) *Player.Player {
+	index := slices.IndexFunc(gameState.Players, func(p Player.Player) bool { return p.Id == playerId })
+	if index < 0 {
+		return nil
+	}
+	return &gameState.Players[index]
+}
+
+// Returns every card in [collection]
+func collectionCards(collection Pieces.Card_Container) []Pieces.Card {
+	switch typed := collection.(type) {
+	case *Pieces.Deck:
+		return typed.Cards
+	case *Pieces.CardPlace:
+		return typed.Cards
+	}
+	return []Pieces.Card{}
+}
+
+// Returns a deep copy of [gameState], so actions can be tried out on it without changing the original
+func cloneGameState(gameState Session.GameState) (Session.GameState, error) {
+	clone := Session.GameState{}
+	asJson, err := json.Marshal(gameState)
+	if err != nil {
+		return clone, err
+	}
+	err = json.Unmarshal(asJson, &clone)
+	return clone, err
+}
@@ -0,0 +1,142 @@
+package Engine
+
+import (
+	"candlelight-api/LogUtil"
+	"candlelight-models/Player"
+	"candlelight-models/Session"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// How many of a lobby's most recent chat messages are kept to be replayed to rejoining players
+const ChatHistorySize = 50
+
+// The longest chat message (in characters) a player may send
+const MaxChatMessageLength = 500
+
+func roomChatKey(roomCode string) string {
+	return "roomChat:" + roomCode
+}
+
+// Validates and records a chat message from [fromId] in the lobby with [roomCode]. If [toId] is set, the message is private between the two players.
+// Returns the message as it should be delivered
+func SendChatMessage(roomCode string, fromId string, toId string, text string) (Session.ChatMessage, error) {
+	funcLogPrefix := "==SendChatMessage=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.ChatMessage{}, err
+	}
+
+	if !slices.ContainsFunc(lobby.Players, func(p Player.Player) bool { return p.Id == fromId }) {
+		return Session.ChatMessage{}, Session.NewGameError(Session.ErrorCode_PlayerNotFound, "Could not find you in the lobby!", "playerId", fromId)
+	}
+	if lobby.Muted[fromId] {
+		return Session.ChatMessage{}, Session.NewGameError(Session.ErrorCode_Muted, "You've been muted by the host!")
+	}
+	if toId != "" && (toId == fromId || !slices.ContainsFunc(lobby.Players, func(p Player.Player) bool { return p.Id == toId })) {
+		return Session.ChatMessage{}, Session.NewGameError(Session.ErrorCode_PlayerNotFound, "Could not find the player to send your message to!", "playerId", toId)
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Session.ChatMessage{}, Session.NewGameError(Session.ErrorCode_MalformedRequest, "Chat messages can't be empty!")
+	}
+	if utf8.RuneCountInString(text) > MaxChatMessageLength {
+		return Session.ChatMessage{}, Session.NewGameError(Session.ErrorCode_MessageTooLong, fmt.Sprintf("Chat messages can't be longer than %d characters!", MaxChatMessageLength),
+			"maxLength", strconv.Itoa(MaxChatMessageLength))
+	}
+
+	message := Session.ChatMessage{
+		Id:      GenerateId(),
+		From:    fromId,
+		To:      toId,
+		Message: text,
+		SentAt:  time.Now().UTC(),
+	}
+
+	asJson, err := json.Marshal(message)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.ChatMessage{}, err
+	}
+
+	//Keep only the most recent messages, and only for as long as the lobby itself is kept
+	pipe := RDB.TxPipeline()
+	pipe.RPush(ctx, roomChatKey(roomCode), asJson)
+	pipe.LTrim(ctx, roomChatKey(roomCode), -ChatHistorySize, -1)
+	pipe.Expire(ctx, roomChatKey(roomCode), roomMessageExpiry)
+	if _, err := pipe.Exec(ctx); err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.ChatMessage{}, err
+	}
+
+	return message, nil
+}
+
+// Returns the recorded chat messages in the lobby with [roomCode] that [playerId] is allowed to see, oldest first
+func GetChatHistory(roomCode string, playerId string) ([]Session.ChatMessage, error) {
+	funcLogPrefix := "==GetChatHistory=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	entries, err := RDB.LRange(ctx, roomChatKey(roomCode), 0, -1).Result()
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return nil, err
+	}
+
+	history := []Session.ChatMessage{}
+	for _, entry := range entries {
+		message := Session.ChatMessage{}
+		if err := json.Unmarshal([]byte(entry), &message); err != nil {
+			log.Printf("%s Skipping malformed chat message in room {%s}: %s", funcLogPrefix, roomCode, err)
+			continue
+		}
+		if message.VisibleTo(playerId) {
+			history = append(history, message)
+		}
+	}
+	return history, nil
+}
+
+// Mutes (or unmutes) [playerId] in the lobby with [roomCode]. Only the host may do this, and the host can't mute themselves
+func SetPlayerMuted(roomCode string, hostId string, playerId string, muted bool) (Session.Lobby, error) {
+	funcLogPrefix := "==SetPlayerMuted=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, err
+	}
+
+	if lobby.Host.Id != hostId {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_NotHost, "Only the host can mute players!")
+	}
+	if playerId == hostId || !slices.ContainsFunc(lobby.Players, func(p Player.Player) bool { return p.Id == playerId }) {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_PlayerNotFound, "Could not find the player to mute!", "playerId", playerId)
+	}
+
+	if lobby.Muted == nil {
+		lobby.Muted = map[string]bool{}
+	}
+	if muted {
+		lobby.Muted[playerId] = true
+	} else {
+		delete(lobby.Muted, playerId)
+	}
+
+	log.Printf("%s Host has set Player {%s}'s muted status to %t", funcLogPrefix, playerId, muted)
+	return SaveLobbyInRedis(lobby)
+}
@@ -0,0 +1,126 @@
+package Engine
+
+import (
+	"candlelight-api/LogUtil"
+	"candlelight-models/Game"
+	"candlelight-models/Session"
+	"encoding/json"
+)
+
+// Lists every action [playerId] could submit to [gameState] right now and have accepted, so clients can highlight what's possible and bots can pick
+// from it. In a game played in SimultaneousRounds, these are the actions they could commit to instead. Nothing is listed if it's not their turn
+// (while EnforceTurnOrder is on and the game isn't played in rounds), or if they're not playing a seat in the game. Otherwise, this is:
+//   - an Insertion of every Orphan they can see into every Deck or CardPlace they can see whose TagsWhitelist allows it
+//   - a Withdrawal from every non-empty Deck or CardPlace they can see into every View they can see. These draw a random card, as WithdrawCard is left blank
+//   - a Movement of every Orphan they can see into every other View they can see, keeping its position. Orphans can always be moved around within their own View
+//   - a Cardflip of every Orphan they can see
+//   - a Reshuffle of every non-empty CardPlace they can see into every Deck they can see
+//   - an EndTurn, passing the turn to whoever is next (or, in a round, committing to do nothing)
+//
+// A player can see every public View and every View in their own hand
+func LegalActions(gameState Session.GameState, playerId string) []Session.SubmittedAction {
+	actions := []Session.SubmittedAction{}
+
+	seat := findSeat(gameState, playerId)
+	if seat == nil || !seat.TakesTurns() {
+		return actions
+	}
+	if gameState.Rules.EnforceTurnOrder && !gameState.Rules.SimultaneousRounds && gameState.CurrentPlayer != playerId {
+		return actions
+	}
+
+	visible := []*Game.View{}
+	for index := range gameState.Views {
+		visible = append(visible, &gameState.Views[index])
+	}
+	for index := range seat.Hand {
+		visible = append(visible, &seat.Hand[index])
+	}
+
+	for _, fromView := range visible {
+		for _, card := range fromView.Pieces.Orphans {
+			for _, inView := range visible {
+				for _, collection := range inView.Pieces.GetCollections() {
+					if collection.CardIsAllowed(&card) {
+						actions = append(actions, newAction(playerId, Session.ActionType_Insertion, Session.Insertion{InsertCard: card.Id, FromView: fromView.Id, ToCollection: collection.GetId(), InView: inView.Id}))
+					}
+				}
+			}
+		}
+	}
+
+	for _, inView := range visible {
+		for _, collection := range inView.Pieces.GetCollections() {
+			if collection.CollectionLength() == 0 {
+				continue
+			}
+			for _, toView := range visible {
+				actions = append(actions, newAction(playerId, Session.ActionType_Withdrawal, Session.Withdrawal{FromCollection: collection.GetId(), InView: inView.Id, ToView: toView.Id}))
+			}
+		}
+	}
+
+	for _, fromView := range visible {
+		for _, card := range fromView.Pieces.Orphans {
+			for _, toView := range visible {
+				if toView.Id != fromView.Id {
+					actions = append(actions, newAction(playerId, Session.ActionType_Movement, Session.Movement{CardId: card.Id, FromView: fromView.Id, ToView: toView.Id, AtX: card.X, AtY: card.Y}))
+				}
+			}
+		}
+	}
+
+	for _, inView := range visible {
+		for _, card := range inView.Pieces.Orphans {
+			actions = append(actions, newAction(playerId, Session.ActionType_CardFlip, Session.Cardflip{FlipCard: card.Id, InView: inView.Id}))
+		}
+	}
+
+	for _, inView := range visible {
+		for _, cardPlace := range inView.Pieces.CardPlaces {
+			if len(cardPlace.Cards) == 0 {
+				continue
+			}
+			for _, toView := range visible {
+				for _, deck := range toView.Pieces.Decks {
+					actions = append(actions, newAction(playerId, Session.ActionType_Reshuffle, Session.Reshuffle{ShuffleCardPlace: cardPlace.Id, InView: inView.Id, ToView: toView.Id, IntoDeck: deck.Id}))
+				}
+			}
+		}
+	}
+
+	if findSeat(gameState, gameState.CurrentPlayer) != nil {
+		actions = append(actions, endTurnAction(playerId))
+	}
+
+	return actions
+}
+
+// Loads the GameState with [gameId] and lists every action [playerId] could take in it right now. See LegalActions
+func GetLegalActions(gameId string, playerId string) ([]Session.SubmittedAction, error) {
+	funcLogPrefix := "==GetLegalActions=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	gameState, err := GetCachedGameStateFromRedis(gameId)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return []Session.SubmittedAction{}, err
+	}
+	if findSeat(gameState, playerId) == nil {
+		return []Session.SubmittedAction{}, Session.NewGameError(Session.ErrorCode_PlayerNotFound, "Could not find you in the game!", "playerId", playerId)
+	}
+
+	return LegalActions(gameState, playerId), nil
+}
+
+// Builds a SubmittedAction of [actionType] for [playerId] out of [turn]
+func newAction(playerId string, actionType string, turn any) Session.SubmittedAction {
+	asJson, _ := json.Marshal(turn)
+	return Session.SubmittedAction{Type: actionType, Turn: asJson, PlayerId: playerId}
+}
+
+// Builds a SubmittedAction ending [playerId]'s turn, passing it to whoever is next
+func endTurnAction(playerId string) Session.SubmittedAction {
+	return newAction(playerId, Session.ActionType_EndTurn, Session.EndTurn{})
+}
@@ -0,0 +1,309 @@
+package Engine
+
+import (
+	"candlelight-api/LogUtil"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// How many of a room's most recent messages are kept around to be replayed to reconnecting players. Anyone who has missed more than this
+// will need to be sent the full state of the game instead
+const RoomMessageBufferSize = 100
+
+// How long a room's message history is kept after its last message. Matches how long Lobbies are kept
+const roomMessageExpiry = 168 * time.Hour
+
+// A message that was sent to a room, along with its place in the room's sequence
+type RoomMessage struct {
+	//Where this message falls in the room's sequence. The first message sent in a room is 1, and every message after it is 1 higher than the last.
+	//Messages published without a sequence number have a Seq of 0
+	Seq int64
+	//The message exactly as it was sent, encoded as JSON
+	Message json.RawMessage
+}
+
+// Assigns the next sequence number, records the message and publishes it in one step, so that messages are always buffered and delivered in the
+// same order they're numbered, no matter how many servers are sending to the room. Both buffered entries and published messages are formatted as
+// "<seq>:<message>" (sorted set members must be unique, and the same message can easily be sent twice)
+var publishSequencedScript = redis.NewScript(`
+local seq = redis.call("INCR", KEYS[1])
+local entry = seq .. ":" .. ARGV[1]
+redis.call("ZADD", KEYS[2], seq, entry)
+redis.call("ZREMRANGEBYRANK", KEYS[2], 0, -(tonumber(ARGV[2]) + 1))
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+redis.call("EXPIRE", KEYS[2], ARGV[3])
+redis.call("PUBLISH", ARGV[4], entry)
+return seq
+`)
+
+// Deletes a key only if it still holds the expected value, so one connection can't untrack another that has since replaced it
+var deleteIfMatchesScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Extends a key's expiry only if it still holds the expected value, so a connection that's already been untracked can't bring itself back
+var refreshIfMatchesScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+func roomSeqKey(roomCode string) string {
+	return "roomSeq:" + roomCode
+}
+
+func roomMessagesKey(roomCode string) string {
+	return "roomMessages:" + roomCode
+}
+
+func roomChannel(roomCode string) string {
+	return "room:" + roomCode
+}
+
+func roomConnectionKey(roomCode string, playerId string) string {
+	return "roomConnection:" + roomCode + ":" + playerId
+}
+
+func roomDisconnectedKey(roomCode string) string {
+	return "roomDisconnected:" + roomCode
+}
+
+// Publishes [message] (already encoded as JSON) to every server with players in the room with [roomCode]. If [sequenced] is set, the message is also given
+// the room's next sequence number and stored so it can be replayed later (only the latest RoomMessageBufferSize are kept). Returns the assigned sequence number, or 0 if unsequenced
+func PublishRoomMessage(roomCode string, message []byte, sequenced bool) (int64, error) {
+	funcLogPrefix := "==PublishRoomMessage=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	if !sequenced {
+		if err := RDB.Publish(ctx, roomChannel(roomCode), "0:"+string(message)).Err(); err != nil {
+			LogError(funcLogPrefix, err)
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	seq, err := publishSequencedScript.Run(ctx, RDB,
+		[]string{roomSeqKey(roomCode), roomMessagesKey(roomCode)},
+		string(message), RoomMessageBufferSize, int(roomMessageExpiry.Seconds()), roomChannel(roomCode),
+	).Int64()
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return 0, err
+	}
+
+	return seq, nil
+}
+
+// A subscription to every message published to a single room. Call Close once it's no longer needed
+type RoomSubscription struct {
+	pubsub *redis.PubSub
+	//Closed by Close, so that nothing is left waiting to hand over a message nobody will read
+	stop chan struct{}
+	//Every message published to the room, in the order they were published
+	Messages <-chan RoomMessage
+}
+
+// Subscribes to every message published to the room with [roomCode]. Doesn't return until the subscription is active, so nothing published afterwards can be missed
+func SubscribeToRoom(roomCode string) (*RoomSubscription, error) {
+	funcLogPrefix := "==SubscribeToRoom=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	pubsub := RDB.Subscribe(ctx, roomChannel(roomCode))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		LogError(funcLogPrefix, err)
+		pubsub.Close()
+		return nil, err
+	}
+
+	messages := make(chan RoomMessage)
+	stop := make(chan struct{})
+	go func() {
+		defer close(messages)
+		for published := range pubsub.Channel() {
+			roomMessage, err := parseRoomMessage(published.Payload)
+			if err != nil {
+				log.Printf("%s Ignoring malformed message published to room {%s}: %s", funcLogPrefix, roomCode, err)
+				continue
+			}
+			select {
+			case messages <- roomMessage:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return &RoomSubscription{pubsub: pubsub, stop: stop, Messages: messages}, nil
+}
+
+// Stops receiving messages. [Messages] is closed shortly afterwards. Must only be called once
+func (subscription *RoomSubscription) Close() error {
+	close(subscription.stop)
+	return subscription.pubsub.Close()
+}
+
+// Splits a "<seq>:<message>" entry back into a RoomMessage
+func parseRoomMessage(entry string) (RoomMessage, error) {
+	seqString, message, found := strings.Cut(entry, ":")
+	seq, err := strconv.ParseInt(seqString, 10, 64)
+	if !found || err != nil {
+		return RoomMessage{}, fmt.Errorf("room message is not in the form <seq>:<message>")
+	}
+	return RoomMessage{Seq: seq, Message: json.RawMessage(message)}, nil
+}
+
+// Returns the sequence number of the most recent message sent in the room with [roomCode], or 0 if nothing has been sent yet
+func CurrentRoomSeq(roomCode string) (int64, error) {
+	funcLogPrefix := "==CurrentRoomSeq=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	seq, err := RDB.Get(ctx, roomSeqKey(roomCode)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	} else if err != nil {
+		LogError(funcLogPrefix, err)
+		return 0, err
+	}
+	return seq, nil
+}
+
+// Returns every message sent in the room with [roomCode] after [lastSeq], oldest first. [complete] is false if some of those messages
+// are no longer buffered (or [lastSeq] doesn't belong to this room at all), meaning the returned messages can't be used to catch someone up
+func GetRoomMessagesSince(roomCode string, lastSeq int64) (messages []RoomMessage, complete bool, err error) {
+	funcLogPrefix := "==GetRoomMessagesSince=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	latest, err := CurrentRoomSeq(roomCode)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if lastSeq < 0 || lastSeq > latest {
+		log.Printf("%s Sequence number {%d} is not valid for room {%s}, whose latest message is {%d}", funcLogPrefix, lastSeq, roomCode, latest)
+		return []RoomMessage{}, false, nil
+	}
+	if lastSeq == latest {
+		return []RoomMessage{}, true, nil
+	}
+
+	entries, err := RDB.ZRangeByScore(ctx, roomMessagesKey(roomCode), &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(lastSeq, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return nil, false, err
+	}
+
+	messages = make([]RoomMessage, 0, len(entries))
+	for _, entry := range entries {
+		roomMessage, parseErr := parseRoomMessage(entry)
+		if parseErr != nil {
+			err = fmt.Errorf("%s Buffered message for room {%s} is malformed: %s", funcLogPrefix, roomCode, parseErr)
+			LogError(funcLogPrefix, err)
+			return nil, false, err
+		}
+		messages = append(messages, roomMessage)
+	}
+
+	//If the oldest message we still have isn't the one right after [lastSeq], there's a gap we can't fill
+	complete = len(messages) > 0 && messages[0].Seq == lastSeq+1
+	return messages, complete, nil
+}
+
+// Records that [playerId] has an open connection to the room with [roomCode], identified by [connectionId]. The record expires after [ttl] unless
+// refreshed with RefreshConnection, so connections belonging to a server that has gone away are eventually forgotten
+func TrackConnection(roomCode string, playerId string, connectionId string, ttl time.Duration) error {
+	funcLogPrefix := "==TrackConnection=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	if err := RDB.Set(ctx, roomConnectionKey(roomCode, playerId), connectionId, ttl).Err(); err != nil {
+		LogError(funcLogPrefix, err)
+		return err
+	}
+	return nil
+}
+
+// Pushes back the expiry of the connection identified by [connectionId] to [ttl] from now. Does nothing if the connection is no longer tracked
+func RefreshConnection(roomCode string, playerId string, connectionId string, ttl time.Duration) error {
+	funcLogPrefix := "==RefreshConnection=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	if err := refreshIfMatchesScript.Run(ctx, RDB, []string{roomConnectionKey(roomCode, playerId)}, connectionId, ttl.Milliseconds()).Err(); err != nil {
+		LogError(funcLogPrefix, err)
+		return err
+	}
+	return nil
+}
+
+// Forgets the connection identified by [connectionId]. Does nothing if [playerId] has since opened a different connection
+func UntrackConnection(roomCode string, playerId string, connectionId string) error {
+	funcLogPrefix := "==UntrackConnection=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	if err := deleteIfMatchesScript.Run(ctx, RDB, []string{roomConnectionKey(roomCode, playerId)}, connectionId).Err(); err != nil {
+		LogError(funcLogPrefix, err)
+		return err
+	}
+	return nil
+}
+
+// Returns whether [playerId] has an open connection to the room with [roomCode] on any server
+func IsPlayerConnected(roomCode string, playerId string) (bool, error) {
+	funcLogPrefix := "==IsPlayerConnected=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	count, err := RDB.Exists(ctx, roomConnectionKey(roomCode, playerId)).Result()
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// Marks [playerId] as having lost their connection to the room with [roomCode]. Returns true if they weren't already marked as such
+func MarkPlayerDisconnected(roomCode string, playerId string) (bool, error) {
+	funcLogPrefix := "==MarkPlayerDisconnected=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	added, err := RDB.SAdd(ctx, roomDisconnectedKey(roomCode), playerId).Result()
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return false, err
+	}
+	RDB.Expire(ctx, roomDisconnectedKey(roomCode), roomMessageExpiry)
+	return added > 0, nil
+}
+
+// Clears [playerId]'s disconnected mark in the room with [roomCode], i.e. because they've reconnected or left. Returns true if they were marked as disconnected
+func MarkPlayerConnected(roomCode string, playerId string) (bool, error) {
+	funcLogPrefix := "==MarkPlayerConnected=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	removed, err := RDB.SRem(ctx, roomDisconnectedKey(roomCode), playerId).Result()
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return false, err
+	}
+	return removed > 0, nil
+}
@@ -0,0 +1,114 @@
+package Engine
+
+import (
+	"candlelight-api/LogUtil"
+	"candlelight-models/Player"
+	"candlelight-models/Session"
+	"fmt"
+	"log"
+	"slices"
+)
+
+// Bans [playerId] from the lobby with [roomCode], so they can't join or spectate it again under the same name or Id (see Lobby.IsBanned). Only the host, [hostId], may do this,
+// and the host can't ban themselves. This doesn't remove the player from the lobby. That's left to LeaveRoom, so their connection can be closed alongside it
+func BanPlayer(roomCode string, hostId string, playerId string) error {
+	funcLogPrefix := "==BanPlayer=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return err
+	}
+
+	if lobby.Host.Id != hostId {
+		return Session.NewGameError(Session.ErrorCode_NotHost, "Only the host can ban players!")
+	}
+	if playerId == hostId {
+		return Session.NewGameError(Session.ErrorCode_CantKickHost, "You can't ban yourself!", "playerId", playerId)
+	}
+	if err := banFromLobby(&lobby, playerId); err != nil {
+		return err
+	}
+
+	log.Printf("%s Host has banned Player {%s} from lobby {%s}", funcLogPrefix, playerId, roomCode)
+	_, err = SaveLobbyInRedis(lobby)
+	return err
+}
+
+// Records [voterId]'s vote to kick [targetId] from the lobby with [roomCode], or takes it back if [vote] is false. The host can't be voted against.
+// Once more than half of the players other than [targetId], and at least 2 of them, have voted, [targetId] is banned and their votes are cleared. Returns the new state of the lobby and whether [targetId] should now
+// be removed. As with BanPlayer, actually removing them is left to LeaveRoom
+func VoteKick(roomCode string, voterId string, targetId string, vote bool) (Session.Lobby, bool, error) {
+	funcLogPrefix := "==VoteKick=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, false, err
+	}
+
+	if !slices.ContainsFunc(lobby.Players, func(p Player.Player) bool { return p.Id == voterId }) {
+		return Session.Lobby{}, false, Session.NewGameError(Session.ErrorCode_PlayerNotFound, "Could not find you in the lobby!", "playerId", voterId)
+	}
+	if targetId == voterId || !slices.ContainsFunc(lobby.Players, func(p Player.Player) bool { return p.Id == targetId }) {
+		return Session.Lobby{}, false, Session.NewGameError(Session.ErrorCode_PlayerNotFound, "Could not find the player to vote against!", "playerId", targetId)
+	}
+	if targetId == lobby.Host.Id {
+		return Session.Lobby{}, false, Session.NewGameError(Session.ErrorCode_CantKickHost, "The host can't be voted out!", "playerId", targetId)
+	}
+
+	if lobby.KickVotes == nil {
+		lobby.KickVotes = map[string][]string{}
+	}
+	votes := slices.DeleteFunc(slices.Clone(lobby.KickVotes[targetId]), func(id string) bool { return id == voterId })
+	if vote {
+		votes = append(votes, voterId)
+	}
+
+	//A strict majority of everyone but the player being voted against. Bots don't vote, so they aren't counted. It always takes at least 2 votes,
+	//so no single player can throw someone out of a small lobby on their own
+	voters := 0
+	for _, player := range lobby.Players {
+		if player.Id != targetId && !player.IsBot() {
+			voters++
+		}
+	}
+	needed := max(voters/2+1, 2)
+	kicked := len(votes) >= needed
+	if kicked {
+		if err := banFromLobby(&lobby, targetId); err != nil {
+			return Session.Lobby{}, false, err
+		}
+		delete(lobby.KickVotes, targetId)
+		log.Printf("%s Players have voted Player {%s} out of lobby {%s}", funcLogPrefix, targetId, roomCode)
+	} else if len(votes) == 0 {
+		delete(lobby.KickVotes, targetId)
+	} else {
+		lobby.KickVotes[targetId] = votes
+		log.Printf("%s %d of %d needed votes to kick Player {%s} from lobby {%s}", funcLogPrefix, len(votes), needed, targetId, roomCode)
+	}
+
+	saved, err := SaveLobbyInRedis(lobby)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, false, err
+	}
+	return saved, kicked, nil
+}
+
+// Adds [playerId] to [lobby]'s ban list. They must be a player in the lobby
+func banFromLobby(lobby *Session.Lobby, playerId string) error {
+	index := slices.IndexFunc(lobby.Players, func(p Player.Player) bool { return p.Id == playerId })
+	if index < 0 {
+		return Session.NewGameError(Session.ErrorCode_PlayerNotFound, fmt.Sprintf("Could not find player {%s} in the lobby!", playerId), "playerId", playerId)
+	}
+
+	if !lobby.IsBanned(playerId, lobby.Players[index].Name) {
+		lobby.Banned = append(lobby.Banned, Player.Player{Id: playerId, Name: lobby.Players[index].Name})
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package Engine
+
+import (
+	"candlelight-models/Session"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long a lobby is kept in Redis after it was last saved
+const lobbyExpiry = 168 * time.Hour
+
+// How many room codes CreateRoom tries before giving up. Only reached if nearly every code is taken
+const maxRoomCodeAttempts = 20
+
+// Letters room codes are made of by default. Vowels are left out so codes can't spell words, along with Y (which often acts as one)
+const defaultRoomCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// Characters that are easily mistaken for one another (0 and O, 1 and I). They're never used in room codes, even if the alphabet includes them
+const ambiguousRoomCodeCharacters = "0O1I"
+
+// Room codes are never allowed to contain any of these, no matter the alphabet they're made from
+var roomCodeBlocklist = []string{
+	"ASS", "CUM", "FAG", "FUK", "FUCK", "KKK", "NAZI", "NIG", "PISS", "POO", "PORN", "RAPE", "SEX", "SHIT", "TIT", "WTF", "XXX",
+}
+
+// Produces a new, random candidate room code. It doesn't need to check whether the code is taken, since CreateRoom claims codes atomically
+type RoomCodeGenerator func() (string, error)
+
+// Generates room codes for CreateRoom. Can be replaced (i.e. in tests) to control which codes are tried
+var generateRoomCode RoomCodeGenerator = NewRoomCodeGenerator(roomCodeConfigFromEnv())
+
+// How room codes are generated
+type RoomCodeConfig struct {
+	//How many characters long every room code is
+	Length int
+	//The characters room codes may be made of
+	Alphabet string
+}
+
+// Reads the room code config from CANDLELIGHT_ROOM_CODE_LENGTH and CANDLELIGHT_ROOM_CODE_ALPHABET, using the defaults for anything missing or invalid
+func roomCodeConfigFromEnv() RoomCodeConfig {
+	config := RoomCodeConfig{Length: 4, Alphabet: defaultRoomCodeAlphabet}
+
+	if length, err := strconv.Atoi(os.Getenv("CANDLELIGHT_ROOM_CODE_LENGTH")); err == nil && length > 0 {
+		config.Length = length
+	}
+	if alphabet := os.Getenv("CANDLELIGHT_ROOM_CODE_ALPHABET"); alphabet != "" {
+		config.Alphabet = alphabet
+	}
+	return config
+}
+
+// Returns a RoomCodeGenerator that picks [config].Length characters from [config].Alphabet using a cryptographically secure source, skipping any
+// code that contains something from the blocklist. Lowercase letters in the alphabet are treated as uppercase, and ambiguous characters are dropped.
+// If that leaves fewer than 2 characters, the default alphabet is used instead
+func NewRoomCodeGenerator(config RoomCodeConfig) RoomCodeGenerator {
+	alphabet := []rune{}
+	for _, char := range strings.ToUpper(config.Alphabet) {
+		if !strings.ContainsRune(ambiguousRoomCodeCharacters, char) && !strings.ContainsRune(string(alphabet), char) {
+			alphabet = append(alphabet, char)
+		}
+	}
+	if len(alphabet) < 2 {
+		log.Printf("Room code alphabet {%s} has fewer than 2 usable characters. Using {%s} instead", config.Alphabet, defaultRoomCodeAlphabet)
+		alphabet = []rune(defaultRoomCodeAlphabet)
+	}
+	length := max(config.Length, 1)
+
+	return func() (string, error) {
+		for attempt := 0; attempt < maxRoomCodeAttempts; attempt++ {
+			code := make([]rune, length)
+			for i := range code {
+				index, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+				if err != nil {
+					return "", err
+				}
+				code[i] = alphabet[index.Int64()]
+			}
+
+			if !isBlockedRoomCode(string(code)) {
+				return string(code), nil
+			}
+		}
+		return "", fmt.Errorf("couldn't generate a room code without a blocked word after %d attempts", maxRoomCodeAttempts)
+	}
+}
+
+// Returns whether [code] contains anything from the blocklist
+func isBlockedRoomCode(code string) bool {
+	for _, blocked := range roomCodeBlocklist {
+		if strings.Contains(code, blocked) {
+			return true
+		}
+	}
+	return false
+}
+
+// Gives [lobby] a room code nobody else is using and saves it. Codes are claimed with SETNX, so two lobbies created at the same time can never end up
+// with the same code, and an existing lobby is never overwritten
+func claimRoomCode(lobby Session.Lobby) (Session.Lobby, error) {
+	funcLogPrefix := "==claimRoomCode=="
+
+	for attempt := 0; attempt < maxRoomCodeAttempts; attempt++ {
+		roomCode, err := generateRoomCode()
+		if err != nil {
+			return Session.Lobby{}, err
+		}
+		lobby.RoomCode = roomCode
+
+		asJson, err := json.Marshal(lobby)
+		if err != nil {
+			return Session.Lobby{}, err
+		}
+
+		claimed, err := RDB.SetNX(ctx, "lobby:"+roomCode, asJson, lobbyExpiry).Result()
+		if err != nil {
+			return Session.Lobby{}, err
+		}
+		if claimed {
+			//The code may have belonged to a lobby that has since expired. Make sure nothing it left behind carries over
+			if err := RDB.Del(ctx, roomSeqKey(roomCode), roomMessagesKey(roomCode), roomChatKey(roomCode), roomDisconnectedKey(roomCode), lobbyPasswordKey(roomCode)).Err(); err != nil {
+				LogError(funcLogPrefix, err)
+			}
+
+			//Save again now that the code is ours, so anything else kept alongside the lobby (i.e. the public lobby index) is filled in
+			return SaveLobbyInRedis(lobby)
+		}
+		log.Printf("%s Room code {%s} is already taken. Trying another", funcLogPrefix, roomCode)
+	}
+	return Session.Lobby{}, fmt.Errorf("%s couldn't find a free room code after %d attempts", funcLogPrefix, maxRoomCodeAttempts)
+}
@@ -0,0 +1,304 @@
+package Engine
+
+import (
+	"candlelight-api/LogUtil"
+	"candlelight-models/Game"
+	"candlelight-models/Player"
+	"candlelight-models/Session"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// How long a round's commitments are kept if the round is never revealed. Matches how long a GameState is cached for
+const roundCommitmentsExpiry = 168 * time.Hour
+
+// The most rounds commitBotActions reveals in one call: the round the bots complete, then the next one in case the people at the table have already
+// committed to it by the time the bots do. Past that, only bots could be completing rounds, so a table of nothing but bots would never stop
+const maxBotRoundsPerCall = 2
+
+// Commitments are kept apart from the GameState, since the GameState is sent to players and commitments must stay hidden until the round is revealed
+func roundCommitmentsKey(gameId string) string {
+	return "roundCommitments:" + gameId
+}
+
+// Who has and hasn't committed to an action in the current round of a game played in simultaneous rounds. What they've committed to stays hidden
+type RoundProgress struct {
+	//Ids of the players who have committed, in seat order
+	Committed []string `json:"committed"`
+	//Ids of the players still deciding, in seat order
+	Waiting []string `json:"waiting"`
+}
+
+// Commits [action] as [action.PlayerId]'s move for the current round of the GameState with [gameId], replacing anything they'd committed to before.
+// The action is checked against the game as it is now, but nothing changes until everyone playing a seat has committed. An EndTurn commits to doing
+// nothing this round. Returns who has committed so far and, if this was the last commitment, the combined Changelog of the round. See revealRound
+func CommitAction(gameId string, action Session.SubmittedAction) (RoundProgress, *Session.Changelog, error) {
+	funcLogPrefix := "==CommitAction=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	gameState, err := GetCachedGameStateFromRedis(gameId)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return RoundProgress{}, nil, err
+	}
+
+	if !gameState.Rules.SimultaneousRounds {
+		return RoundProgress{}, nil, Session.NewGameError(Session.ErrorCode_NotSimultaneous, "This game is played in turns. Submit your action instead!")
+	}
+	seat := findSeat(gameState, action.PlayerId)
+	if seat == nil {
+		return RoundProgress{}, nil, Session.NewGameError(Session.ErrorCode_PlayerNotFound, "Could not find you in the game!", "playerId", action.PlayerId)
+	}
+	if !seat.TakesTurns() {
+		return RoundProgress{}, nil, Session.NewGameError(Session.ErrorCode_SeatVacant, "You have left the game!", "playerId", action.PlayerId)
+	}
+
+	//Try the action out now, so the player finds out straight away if it could never be carried out
+	if action.Type != Session.ActionType_EndTurn {
+		trial, err := cloneGameState(gameState)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+			return RoundProgress{}, nil, err
+		}
+		if _, err := applyAction(&trial, action); err != nil {
+			log.Printf("%s Player {%s}'s %s was rejected: %s", funcLogPrefix, action.PlayerId, action.Type, err)
+			return RoundProgress{}, nil, err
+		}
+	}
+
+	asJson, err := json.Marshal(action)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return RoundProgress{}, nil, err
+	}
+	key := roundCommitmentsKey(gameId)
+	if err := RDB.HSet(ctx, key, action.PlayerId, asJson).Err(); err != nil {
+		LogError(funcLogPrefix, err)
+		return RoundProgress{}, nil, err
+	}
+	RDB.Expire(ctx, key, roundCommitmentsExpiry)
+
+	log.Printf("%s Player {%s} has committed to an action in game {%s}", funcLogPrefix, action.PlayerId, gameId)
+	return resolveRound(gameId)
+}
+
+// Returns who has and hasn't committed to an action in the current round of the GameState with [gameId]
+func GetRoundProgress(gameId string) (RoundProgress, error) {
+	funcLogPrefix := "==GetRoundProgress=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	gameState, err := GetCachedGameStateFromRedis(gameId)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return RoundProgress{}, err
+	}
+	committed, err := RDB.HKeys(ctx, roundCommitmentsKey(gameId)).Result()
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return RoundProgress{}, err
+	}
+	return roundProgress(gameState, committed), nil
+}
+
+// Sorts every seat in [gameState] that's being played into those who have [committed] and those who haven't
+func roundProgress(gameState Session.GameState, committed []string) RoundProgress {
+	progress := RoundProgress{Committed: []string{}, Waiting: []string{}}
+	for _, seat := range gameState.Players {
+		if !seat.TakesTurns() {
+			continue
+		}
+		if slices.Contains(committed, seat.Id) {
+			progress.Committed = append(progress.Committed, seat.Id)
+		} else {
+			progress.Waiting = append(progress.Waiting, seat.Id)
+		}
+	}
+	return progress
+}
+
+// Reveals the current round of the GameState with [gameId] if everyone playing a seat has committed to an action. Returns who has committed so far
+// and, if the round was revealed, its combined Changelog
+func resolveRound(gameId string) (RoundProgress, *Session.Changelog, error) {
+	funcLogPrefix := "==resolveRound=="
+
+	gameState, err := GetCachedGameStateFromRedis(gameId)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return RoundProgress{}, nil, err
+	}
+
+	key := roundCommitmentsKey(gameId)
+	committed, err := RDB.HKeys(ctx, key).Result()
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return RoundProgress{}, nil, err
+	}
+	progress := roundProgress(gameState, committed)
+	if len(progress.Waiting) > 0 || len(progress.Committed) == 0 {
+		return progress, nil, nil
+	}
+
+	//Take every commitment at once, so that if the last two players commit at the same time, only one of them reveals the round
+	var taken *redis.StringStringMapCmd
+	if _, err := RDB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		taken = pipe.HGetAll(ctx, key)
+		pipe.Del(ctx, key)
+		return nil
+	}); err != nil {
+		LogError(funcLogPrefix, err)
+		return progress, nil, err
+	}
+
+	commitments := taken.Val()
+	takenIds := []string{}
+	for playerId := range commitments {
+		takenIds = append(takenIds, playerId)
+	}
+	if progress := roundProgress(gameState, takenIds); len(progress.Waiting) > 0 {
+		//Someone else revealed the round first, so these are the start of the next one. Put them back
+		for playerId, asJson := range commitments {
+			RDB.HSetNX(ctx, key, playerId, asJson)
+		}
+		return progress, nil, nil
+	}
+
+	changelog := revealRound(&gameState, commitments)
+	if _, err := CacheGameStateInRedis(gameState); err != nil {
+		err = fmt.Errorf("%s Error trying to cache revealed round. Actions may not properly persist! %s", funcLogPrefix, err)
+		LogError(funcLogPrefix, err)
+		return progress, nil, err
+	}
+
+	log.Printf("%s Revealed a round of game {%s}", funcLogPrefix, gameId)
+	return roundProgress(gameState, []string{}), &changelog, nil
+}
+
+// Applies every action in [commitments] (keyed by the Id of the player who committed it) to [gameState], one seat at a time starting from its
+// CurrentPlayer, then passes CurrentPlayer on to lead the next round. An action that can no longer be carried out (i.e. because an earlier seat took
+// the card it wanted) is skipped. Returns one Changelog covering every action
+func revealRound(gameState *Session.GameState, commitments map[string]string) Session.Changelog {
+	funcLogPrefix := "==revealRound=="
+
+	changelog := Session.Changelog{Views: []*Game.View{}}
+	descriptions := []string{}
+
+	start := max(slices.IndexFunc(gameState.Players, func(p Player.Player) bool { return p.Id == gameState.CurrentPlayer }), 0)
+	for offset := 0; offset < len(gameState.Players); offset++ {
+		seat := gameState.Players[(start+offset)%len(gameState.Players)]
+		asJson, committed := commitments[seat.Id]
+		if !committed || !seat.TakesTurns() {
+			continue
+		}
+
+		action := Session.SubmittedAction{}
+		if err := json.Unmarshal([]byte(asJson), &action); err != nil {
+			LogError(funcLogPrefix, err)
+			continue
+		}
+		if action.Type == Session.ActionType_EndTurn {
+			descriptions = append(descriptions, fmt.Sprintf("Player '%s' passed.", seat.Name))
+			continue
+		}
+
+		applied, err := applyAction(gameState, action)
+		if err != nil {
+			descriptions = append(descriptions, fmt.Sprintf("Player '%s' couldn't carry out their %s: %s", seat.Name, action.Type, Session.AsGameError(err).Message))
+			continue
+		}
+		for _, view := range applied.Views {
+			if !slices.ContainsFunc(changelog.Views, func(v *Game.View) bool { return v.Id == view.Id }) {
+				changelog.Views = append(changelog.Views, view)
+			}
+		}
+		descriptions = append(descriptions, applied.MostRecentAction+".")
+	}
+
+	//Pass the lead on, which also restarts the clock for the next round
+	if _, err := (Session.EndTurn{}).Execute(gameState, gameState.CurrentPlayer); err != nil {
+		LogError(funcLogPrefix, err)
+	}
+	changelog.CurrentPlayer = gameState.CurrentPlayer
+	changelog.TurnDeadline = gameState.TurnDeadline
+
+	lead := gameState.CurrentPlayer
+	if seat := findSeat(*gameState, lead); seat != nil {
+		lead = seat.Name
+	}
+	changelog.MostRecentAction = fmt.Sprintf("Everyone has committed. %s Next round is led by '%s'", strings.Join(descriptions, " "), lead)
+	return changelog
+}
+
+// Has [bot] choose an action for [seat] and commits it, committing to pass instead if it can't choose one the engine accepts. Returns the round's
+// Changelog if this completed it
+func commitFor(gameState Session.GameState, seat Player.Player, bot Bot) (*Session.Changelog, error) {
+	funcLogPrefix := "==commitFor=="
+
+	action, err := bot.ChooseAction(gameState.ForPlayer(seat.Id), seat.Id)
+	if err != nil {
+		LogError(funcLogPrefix, fmt.Errorf("bot {%s} couldn't choose an action: %s", seat.Id, err))
+		action = endTurnAction(seat.Id)
+	}
+	action.PlayerId = seat.Id
+
+	_, revealed, err := CommitAction(gameState.Id, action)
+	if err != nil && action.Type != Session.ActionType_EndTurn {
+		log.Printf("%s Bot {%s}'s %s was rejected: %s", funcLogPrefix, seat.Id, action.Type, err)
+		_, revealed, err = CommitAction(gameState.Id, endTurnAction(seat.Id))
+	}
+	return revealed, err
+}
+
+// Has every bot in [gameState] that hasn't committed to an action this round commit to one. Also reveals the round if nobody is left to wait for
+// (i.e. because the last player who hadn't committed left). If the round is revealed and people are still playing, the bots commit to the next one
+// straight away, so only people are waited on. That can reveal the next round too, so up to maxBotRoundsPerCall rounds are revealed per call. A table
+// of nothing but bots only ever has one revealed per call, so it can't play forever. Returns the Changelog of each revealed round
+func commitBotActions(gameState Session.GameState) ([]Session.Changelog, error) {
+	changelogs := []Session.Changelog{}
+
+	for len(changelogs) < maxBotRoundsPerCall {
+		revealed, err := commitBotRound(gameState)
+		if err != nil || revealed == nil {
+			return changelogs, err
+		}
+		changelogs = append(changelogs, *revealed)
+
+		if !slices.ContainsFunc(gameState.Players, func(p Player.Player) bool { return p.TakesTurns() && !p.IsBot() }) {
+			break
+		}
+		if gameState, err = GetCachedGameStateFromRedis(gameState.Id); err != nil {
+			return changelogs, err
+		}
+	}
+	return changelogs, nil
+}
+
+// Has every bot in [gameState] that hasn't committed to an action this round commit to one, then reveals the round if nobody is left to wait for.
+// Returns the round's Changelog if it was revealed
+func commitBotRound(gameState Session.GameState) (*Session.Changelog, error) {
+	committed, err := RDB.HKeys(ctx, roundCommitmentsKey(gameState.Id)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, seat := range gameState.Players {
+		if seat.SeatStatus != Player.SeatStatus_Bot || slices.Contains(committed, seat.Id) {
+			continue
+		}
+		revealed, err := commitFor(gameState, seat, botFor(seat))
+		//Whoever is left would be committing to the next round, which they haven't seen yet
+		if err != nil || revealed != nil {
+			return revealed, err
+		}
+	}
+
+	_, revealed, err := resolveRound(gameState.Id)
+	return revealed, err
+}
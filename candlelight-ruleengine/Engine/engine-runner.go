@@ -6,8 +6,11 @@ import (
 	"candlelight-models/Pieces"
 	"candlelight-models/Player"
 	"candlelight-models/Session"
-	"candlelight-models/Sparks"
+	"maps"
+	"math/rand"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"context"
@@ -20,6 +23,9 @@ import (
 
 var ctx = context.Background()
 
+// Redis set holding the room code of every public lobby that's awaiting start
+const publicLobbiesKey = "publicLobbies"
+
 // Saves the given [game] in the database, which is currently Redis. If the save is successful, [error] will be nil
 func SaveGameDefToDB(game Game.Game) (Game.Game, error) {
 	defer LogUtil.EnsureLogPrefixIsReset()
@@ -243,9 +249,9 @@ func GetCachedGameStateFromRedis(id string) (Session.GameState, error) {
 	return gameState, nil
 }
 
-// Given an id to a Game defition, constructs and returns an initial GameState for it. This is essentially
-// how to start the game
-func GetInitialGameState(roomCode string) (Session.GameState, error) {
+// Given the room code of a Lobby, constructs and returns an initial GameState for the Lobby's Game definition. This is essentially
+// how to start the game. [playerId] is the player trying to start the game, and [options] are whatever they sent along with their startGame message
+func GetInitialGameState(roomCode string, playerId string, options StartOptions) (Session.GameState, error) {
 	funcLogPrefix := "==GetInitialGameState=="
 	defer LogUtil.EnsureLogPrefixIsReset()
 	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
@@ -258,9 +264,33 @@ func GetInitialGameState(roomCode string) (Session.GameState, error) {
 		return gameState, err
 	}
 
-	//Check if the lobby is already started.
-	if lobby.Status == Session.LobbyStatus_InProgress {
-		err := fmt.Errorf("tried to start game, but Lobby {%s} has been marked as In Progress and has a GameStateId == {%s}", lobby.RoomCode, lobby.GameStateId)
+	//Games can only be started from a lobby awaiting start. An ended lobby has to go through Rematch first, so the last game is cleaned up and everyone readies up again
+	if lobby.Status == Session.LobbyStatus_Ended {
+		err := Session.NewGameError(Session.ErrorCode_GameEnded, "The game has ended! Start a rematch to play again", "roomCode", lobby.RoomCode)
+		LogError(funcLogPrefix, err)
+		return gameState, err
+	}
+	if lobby.Status != Session.LobbyStatus_AwaitingStart {
+		err := Session.NewGameError(Session.ErrorCode_GameAlreadyStarted, "The game has already started!", "roomCode", lobby.RoomCode)
+		LogError(funcLogPrefix, err)
+		return gameState, err
+	}
+
+	//Only the host can start the game, and only once enough players have joined and all of them are ready
+	if lobby.Host.Id != playerId {
+		err := Session.NewGameError(Session.ErrorCode_NotHost, "Only the host can start the game!")
+		LogError(funcLogPrefix, err)
+		return gameState, err
+	}
+	fewest := max(lobby.MinPlayers, 1)
+	if lobby.NumPlayers < fewest {
+		err := Session.NewGameError(Session.ErrorCode_NotEnoughPlayers, fmt.Sprintf("This game needs at least %d players to start, but only %d have joined!", fewest, lobby.NumPlayers),
+			"minPlayers", strconv.Itoa(fewest), "numPlayers", strconv.Itoa(lobby.NumPlayers))
+		LogError(funcLogPrefix, err)
+		return gameState, err
+	}
+	if !lobby.AllPlayersReady() {
+		err := Session.NewGameError(Session.ErrorCode_PlayersNotReady, "Not every player is ready yet!")
 		LogError(funcLogPrefix, err)
 		return gameState, err
 	}
@@ -273,7 +303,7 @@ func GetInitialGameState(roomCode string) (Session.GameState, error) {
 
 	gameState.GameDefinitionId = gameDef.Id
 	gameState.GameName = gameDef.Name
-	gameState.Rules = gameDef.Rules
+	gameState.Rules = lobby.RuleOverrides.Apply(gameDef.Rules)
 	gameState.SplashText = gameDef.SplashText
 	gameState.Views = gameDef.ViewsForPlayer(0) //Player 0 == public/table-owned
 
@@ -290,18 +320,31 @@ func GetInitialGameState(roomCode string) (Session.GameState, error) {
 
 	gameState.Players = []Player.Player{}
 
-	for index, element := range lobby.Players {
-		gameState.Players = append(gameState.Players, Player.Player{
+	for index, element := range seatPlayers(lobby) {
+		seat := Player.Player{
 			Id:   element.Id,
 			Name: element.Name,
-			Hand: gameDef.ViewsForPlayer(index + 1), //TODO: Need a more in-depth discussion about what to do in terms of determining starting pieces
+			Hand: handForSeat(gameDef, index+1),
+			Bot:  element.Bot,
 			//Resources: slices.Clone(startingResources),
-		})
+		}
+		if seat.Bot != "" {
+			seat.SeatStatus = Player.SeatStatus_Bot
+		}
+		gameState.Players = append(gameState.Players, seat)
 	}
 
-	gameState.CurrentPlayer = gameState.Players[0].Id //TODO: Make a better way to determine a starting player maybe?
+	//The first seat goes first unless the game has a StartingPlayer Spark, which picks someone else below
+	gameState.CurrentPlayer = gameState.Players[0].Id
 
-	applySparks(&gameState, gameDef.Sparks)
+	err = applySparks(&gameState, SparkContext{Lobby: lobby, Options: options}, gameDef.Sparks)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return gameState, err
+	}
+
+	//Sparks may have picked someone else to go first, so only start the clock once they're done
+	gameState.StartTurnClock()
 
 	gameState, err = CacheGameStateInRedis(gameState)
 	if err != nil {
@@ -321,93 +364,239 @@ func GetInitialGameState(roomCode string) (Session.GameState, error) {
 	return gameState, nil
 }
 
-func applySparks(gameState *Session.GameState, sparks Sparks.Sparks) {
-	if sparks.Dealer.Enabled {
-		applyDealer(gameState, sparks.Dealer)
+// Returns the Lobby's players in the order they should be seated in the GameState, according to the Lobby's SeatingOrder
+func seatPlayers(lobby Session.Lobby) []Player.Player {
+	seated := slices.Clone(lobby.Players)
+
+	switch lobby.SeatingOrder {
+	case Session.SeatingOrder_Random:
+		rand.Shuffle(len(seated), func(i, j int) { seated[i], seated[j] = seated[j], seated[i] })
+	case Session.SeatingOrder_HostArranged:
+		//Anyone not in the arrangement gets an index past the end of it, and SortStableFunc keeps them in join order
+		seatOf := func(p Player.Player) int {
+			index := slices.Index(lobby.SeatArrangement, p.Id)
+			if index == -1 {
+				return len(lobby.SeatArrangement)
+			}
+			return index
+		}
+		slices.SortStableFunc(seated, func(a Player.Player, b Player.Player) int { return seatOf(a) - seatOf(b) })
 	}
-	if sparks.Flipper.Enabled {
-		applyFlipper(gameState, sparks.Flipper)
+
+	return seated
+}
+
+// Builds the starting Hand for whoever sits in seat number [seatNumber] (starting at 1). This is every View the Game definition
+// assigns to that seat directly, followed by a fresh copy of every View template
+func handForSeat(gameDef Game.Game, seatNumber int) []Game.View {
+	hand := gameDef.ViewsForPlayer(seatNumber)
+	for _, template := range gameDef.ViewTemplates() {
+		hand = append(hand, instantiateViewTemplate(template, seatNumber))
 	}
+	return hand
 }
 
-func applyDealer(gameState *Session.GameState, dealer Sparks.Dealer) {
-	var deckToUse (*Pieces.Deck) = nil
-	for _, view := range gameState.Views {
-		indexOfDeck := slices.IndexFunc(view.Pieces.Decks, func(d Pieces.Deck) bool { return d.Id == dealer.DeckToUse })
-		if indexOfDeck != -1 {
-			deckToUse = &view.Pieces.Decks[indexOfDeck]
-			break
-		}
+// Copies [template] for the player in seat number [seatNumber], giving the View and every piece within it a fresh Id
+func instantiateViewTemplate(template Game.View, seatNumber int) Game.View {
+	view := template
+	view.Id = GenerateId()
+	view.TemplateId = template.Id
+	view.PerPlayer = false
+	view.OwnerPlayerNumber = seatNumber
+
+	view.Pieces = Pieces.PieceSet{
+		Decks:      []Pieces.Deck{},
+		CardPlaces: []Pieces.CardPlace{},
+		Orphans:    cloneCards(template.Pieces.Orphans, view.Id),
+	}
+	for _, deck := range template.Pieces.Decks {
+		view.Pieces.Decks = append(view.Pieces.Decks, cloneDeck(deck, view.Id))
 	}
+	for _, cardPlace := range template.Pieces.CardPlaces {
+		clone := cardPlace
+		clone.Id = GenerateId()
+		clone.ParentView = view.Id
+		clone.Cards = cloneCards(cardPlace.Cards, view.Id)
+		view.Pieces.CardPlaces = append(view.Pieces.CardPlaces, clone)
+	}
+
+	return view
+}
+
+// Marks the player with the given [playerId] as ready (or not ready) to start the game in the Lobby with the given [roomCode]
+func SetPlayerReady(roomCode string, playerId string, ready bool) (Session.Lobby, error) {
+	funcLogPrefix := "==SetPlayerReady=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
 
-	if deckToUse == nil {
-		LogError("==applyDealer==", fmt.Errorf("could not find deck to deal from. Ignoring Dealer"))
-		return
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, err
 	}
 
-	for _, player := range gameState.Players {
-		for x := range dealer.NumToDeal {
-			cardWithdraw := deckToUse.PickRandomCardFromCollection()
-			cardCopy := *cardWithdraw
-			cardCopy.ParentView = player.Hand[0].Id
-			//Put X as 0, 20, 40, etc
-			cardCopy.X = float32(x * 20)
-			cardCopy.Y = 0
+	if !slices.ContainsFunc(lobby.Players, func(p Player.Player) bool { return p.Id == playerId }) {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_PlayerNotFound, "Could not find you in the lobby!", "playerId", playerId)
+	}
+	if lobby.Status != Session.LobbyStatus_AwaitingStart {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_GameAlreadyStarted, "The game has already started!")
+	}
 
-			deckToUse.RemoveCardFromCollection(*cardWithdraw)
-			player.Hand[0].Pieces.Orphans = append(player.Hand[0].Pieces.Orphans, cardCopy)
-		}
+	if lobby.Ready == nil {
+		lobby.Ready = map[string]bool{}
 	}
+	lobby.Ready[playerId] = ready
+
+	log.Printf("%s Player {%s} has set their ready status to %t", funcLogPrefix, playerId, ready)
+	return SaveLobbyInRedis(lobby)
 }
 
-func applyFlipper(gameState *Session.GameState, flipper Sparks.Flipper) {
-	var deckToUse (*Pieces.Deck) = nil
-	var cardPlaceToUse (*Pieces.CardPlace) = nil
-	foundDeck, foundCardPlace := false, false
-	indexOfDeck, indexOfCardPlace := -1, -1
-	for _, view := range gameState.Views {
-		if !foundDeck {
-			indexOfDeck = slices.IndexFunc(view.Pieces.Decks, func(d Pieces.Deck) bool { return d.Id == flipper.DeckToUse })
-		}
-		if !foundCardPlace {
-			indexOfCardPlace = slices.IndexFunc(view.Pieces.CardPlaces, func(cp Pieces.CardPlace) bool { return cp.Id == flipper.CardPlaceToUse })
-		}
+// Updates the seating of the Lobby with the given [roomCode]. Only the host may do this, and only before the game starts. If
+// [seatingOrder] is SeatingOrder_HostArranged, [arrangement] must only contain Ids of players in the Lobby, each at most once
+func ArrangeSeats(roomCode string, playerId string, seatingOrder string, arrangement []string) (Session.Lobby, error) {
+	funcLogPrefix := "==ArrangeSeats=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
 
-		if indexOfDeck != -1 {
-			foundDeck = true
-			deckToUse = &view.Pieces.Decks[indexOfDeck]
-		}
-		if indexOfCardPlace != -1 {
-			foundCardPlace = true
-			cardPlaceToUse = &view.Pieces.CardPlaces[indexOfCardPlace]
-		}
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, err
+	}
 
-		if foundDeck && foundCardPlace {
-			break
+	if lobby.Host.Id != playerId {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_NotHost, "Only the host can arrange seats!")
+	}
+	if lobby.Status != Session.LobbyStatus_AwaitingStart {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_GameAlreadyStarted, "Seats can't be rearranged once the game has started!")
+	}
+
+	if err := setSeating(&lobby, seatingOrder, arrangement); err != nil {
+		return Session.Lobby{}, err
+	}
+
+	return SaveLobbyInRedis(lobby)
+}
+
+// Sets [lobby]'s seating, making sure [seatingOrder] is recognized and, if it's SeatingOrder_HostArranged, that [arrangement] only contains
+// Ids of players in the Lobby, each at most once
+func setSeating(lobby *Session.Lobby, seatingOrder string, arrangement []string) error {
+	switch seatingOrder {
+	case Session.SeatingOrder_JoinOrder, Session.SeatingOrder_Random:
+		arrangement = []string{}
+	case Session.SeatingOrder_HostArranged:
+		for index, id := range arrangement {
+			if !slices.ContainsFunc(lobby.Players, func(p Player.Player) bool { return p.Id == id }) {
+				return Session.NewGameError(Session.ErrorCode_InvalidSeating, "Seat arrangement contains a player who isn't in the lobby!", "playerId", id)
+			}
+			if slices.Index(arrangement, id) != index {
+				return Session.NewGameError(Session.ErrorCode_InvalidSeating, "Seat arrangement contains the same player more than once!", "playerId", id)
+			}
 		}
+	default:
+		return Session.NewGameError(Session.ErrorCode_InvalidSeating, fmt.Sprintf("Seating order {%s} not recognized", seatingOrder), "seatingOrder", seatingOrder)
+	}
+
+	lobby.SeatingOrder = seatingOrder
+	lobby.SeatArrangement = arrangement
+	return nil
+}
+
+// Makes [newHostId] the host of the lobby with [roomCode]. Only the current host, [hostId], may do this
+func TransferHost(roomCode string, hostId string, newHostId string) (Session.Lobby, error) {
+	funcLogPrefix := "==TransferHost=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, err
 	}
 
-	if deckToUse == nil {
-		LogError("==applyFlipper==", fmt.Errorf("could not find deck to deal from. Ignoring Flipper"))
-		return
+	if lobby.Host.Id != hostId {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_NotHost, "Only the host can hand off being host!")
+	}
+	newHostIndex := slices.IndexFunc(lobby.Players, func(p Player.Player) bool { return p.Id == newHostId })
+	if newHostIndex < 0 {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_PlayerNotFound, "Could not find the player to make host!", "playerId", newHostId)
 	}
-	if cardPlaceToUse == nil {
-		LogError("==applyFlipper==", fmt.Errorf("could not find cardplace to put cards in. Ignoring Flipper"))
-		return
+	if lobby.Players[newHostIndex].IsBot() {
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_NotAPlayer, "Bots can't be made host!", "playerId", newHostId)
 	}
 
-	for range flipper.NumToFlip {
-		cardWithdraw := deckToUse.PickRandomCardFromCollection()
-		cardCopy := *cardWithdraw
-		cardCopy.ParentView = cardPlaceToUse.ParentView
+	lobby.Host = lobby.Players[newHostIndex]
+
+	log.Printf("%s Host of lobby {%s} has been transferred to Player {%s}", funcLogPrefix, roomCode, newHostId)
+	return SaveLobbyInRedis(lobby)
+}
+
+// If [hostId] is still the host of the lobby with [roomCode] and doesn't have an open connection, hands the lobby to the next connected player.
+// Returns the new state of the lobby and whether the host changed. Nothing changes if no other player is connected either
+func MigrateHostIfDisconnected(roomCode string, hostId string) (Session.Lobby, bool, error) {
+	funcLogPrefix := "==MigrateHostIfDisconnected=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
 
-		deckToUse.RemoveCardFromCollection(*cardWithdraw)
-		cardPlaceToUse.AddCardToCollection(cardCopy)
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, false, err
+	}
+
+	if lobby.Host.Id != hostId || lobby.Status == Session.LobbyStatus_Ended {
+		return lobby, false, nil
+	}
+	if connected, err := IsPlayerConnected(roomCode, hostId); err != nil || connected {
+		return lobby, false, err
 	}
 
+	newHost := nextHost(lobby, hostId)
+	if newHost.Id == "" || newHost.Id == hostId {
+		return lobby, false, nil
+	}
+	if connected, _ := IsPlayerConnected(roomCode, newHost.Id); !connected {
+		log.Printf("%s Host {%s} of lobby {%s} is gone, but so is everyone else. Leaving host as is", funcLogPrefix, hostId, roomCode)
+		return lobby, false, nil
+	}
+
+	lobby.Host = newHost
+	log.Printf("%s Host {%s} of lobby {%s} didn't come back in time. Player {%s} is the new host", funcLogPrefix, hostId, roomCode, newHost.Id)
+	saved, err := SaveLobbyInRedis(lobby)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, false, err
+	}
+	return saved, true, nil
 }
 
-func EndGame(roomCode string, playerId string) error {
+// Picks who should become host of [lobby] in place of [hostId]: the first player with an open connection after [hostId] in join order (wrapping around),
+// or simply the next player if nobody is connected. Bots are never picked. Returns an empty Player if there's nobody else in the lobby
+func nextHost(lobby Session.Lobby, hostId string) Player.Player {
+	hostIndex := slices.IndexFunc(lobby.Players, func(p Player.Player) bool { return p.Id == hostId })
+
+	candidates := []Player.Player{}
+	for offset := 1; offset <= len(lobby.Players); offset++ {
+		candidate := lobby.Players[(hostIndex+offset+len(lobby.Players))%len(lobby.Players)]
+		if candidate.Id != hostId && !candidate.IsBot() {
+			candidates = append(candidates, candidate)
+		}
+	}
+	if len(candidates) == 0 {
+		return Player.Player{}
+	}
+
+	for _, candidate := range candidates {
+		if connected, _ := IsPlayerConnected(lobby.RoomCode, candidate.Id); connected {
+			return candidate
+		}
+	}
+	return candidates[0]
+}
+
+// Marks the game in the lobby with [roomCode] as ended. Only the host, [playerId], may do this. The lobby itself is kept, so its players can
+// stay connected and start a Rematch. Returns the new state of the lobby
+func EndGame(roomCode string, playerId string) (Session.Lobby, error) {
 	funcLogPrefix := "==EndGame=="
 	defer LogUtil.EnsureLogPrefixIsReset()
 	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
@@ -415,26 +604,99 @@ func EndGame(roomCode string, playerId string) error {
 	lobby, err := LoadLobbyFromRedis(roomCode)
 	if err != nil {
 		LogError(funcLogPrefix, err)
-		return err
+		return Session.Lobby{}, err
 	}
 
 	//Make sure that A) this player is the host and therefore allowed to end the game, and B) this game isn't already ended
 
 	if lobby.Host.Id != playerId {
-		return fmt.Errorf("player trying to end game is not host of lobby")
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_NotHost, "Only the host can end the game!")
 	}
 
 	if lobby.Status == Session.LobbyStatus_Ended {
-		return fmt.Errorf("game has already been marked as ended")
+		return Session.Lobby{}, Session.NewGameError(Session.ErrorCode_GameEnded, "The game has already ended!")
 	}
 
-	//Mark Game as ended and resave
+	//Mark Game as ended and resave. Nobody can take over a seat in a game that's over
 	lobby.Status = Session.LobbyStatus_Ended
-
-	_, err = SaveLobbyInRedis(lobby)
+	lobby.SeatRequests = nil
 
 	//Return any error that occurred during saving, if any
-	return err
+	return SaveLobbyInRedis(lobby)
+}
+
+// Puts the lobby with [roomCode] back to Awaiting Start with the same players so they can play again, throwing away any GameState it had. Only the host,
+// [hostId], may do this. If [gameDefId] is set, the lobby switches to that game definition, as long as every player still fits in it. Returns the new state of
+// the lobby and any spectators who had to be removed because the new game doesn't allow spectating
+func Rematch(roomCode string, hostId string, gameDefId string) (Session.Lobby, []Player.Player, error) {
+	funcLogPrefix := "==Rematch=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, nil, err
+	}
+
+	if lobby.Host.Id != hostId {
+		return Session.Lobby{}, nil, Session.NewGameError(Session.ErrorCode_NotHost, "Only the host can start a rematch!")
+	}
+
+	removed := []Player.Player{}
+	if gameDefId != "" && gameDefId != lobby.GameDefinitionId {
+		removed, err = switchGameDefinition(&lobby, gameDefId)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+			return Session.Lobby{}, nil, err
+		}
+	}
+
+	//The old GameState is finished with, so make sure nobody can keep submitting actions to it
+	if lobby.GameStateId != "" {
+		if err := RDB.Del(ctx, "gameState:"+lobby.GameStateId).Err(); err != nil {
+			LogError(funcLogPrefix, err)
+		}
+	}
+
+	//Everyone has to ready up again for the next game
+	lobby.GameStateId = ""
+	lobby.Status = Session.LobbyStatus_AwaitingStart
+	lobby.Ready = map[string]bool{}
+
+	log.Printf("%s Lobby {%s} has been reset for a rematch of {%s}", funcLogPrefix, roomCode, lobby.GameDefinitionId)
+	saved, err := SaveLobbyInRedis(lobby)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, nil, err
+	}
+	return saved, removed, nil
+}
+
+// Switches [lobby] to the game definition with [gameDefId], as long as every player in it fits within the game's MaxPlayers. Returns any spectators
+// who had to be removed because the new game doesn't allow spectating
+func switchGameDefinition(lobby *Session.Lobby, gameDefId string) ([]Player.Player, error) {
+	gameDef, err := GetGameDefFromDB(gameDefId)
+	if err != nil {
+		return nil, err
+	}
+	if lobby.NumPlayers > gameDef.MaxPlayers {
+		return nil, Session.NewGameError(Session.ErrorCode_LobbyFull, fmt.Sprintf("%s only allows %d players, but %d are in the lobby!", gameDef.Name, gameDef.MaxPlayers, lobby.NumPlayers),
+			"maxPlayers", strconv.Itoa(gameDef.MaxPlayers), "numPlayers", strconv.Itoa(lobby.NumPlayers))
+	}
+
+	lobby.GameDefinitionId = gameDef.Id
+	lobby.GameName = gameDef.Name
+	lobby.MaxPlayers = gameDef.MaxPlayers
+	lobby.MinPlayers = gameDef.MinPlayers
+
+	removed := []Player.Player{}
+	if gameDef.Rules.SpectatorVisibility == Game.SpectatorVisibility_Disabled {
+		removed = lobby.Spectators
+		lobby.Spectators = []Player.Player{}
+		lobby.AllowSpectators = false
+	}
+	return removed, nil
 }
 
 // Submits an Action to the GameState with id == [gameId]. Will always return some GameState, even if something goes wrong, in which case [error] will not be nil.
@@ -458,74 +720,93 @@ func SubmitAction(gameId string, action Session.SubmittedAction) (Session.Change
 		CurrentPlayer: gameState.CurrentPlayer,
 	}
 
+	if gameState.Rules.SimultaneousRounds {
+		return changelog, Session.NewGameError(Session.ErrorCode_MustCommit, "This game is played in simultaneous rounds. Commit your action instead!")
+	}
+
 	//Only allow the player whose turn it is to take an action
 	if gameState.Rules.EnforceTurnOrder {
 		if gameState.CurrentPlayer != action.PlayerId {
 			log.Printf("Player %s has tried to submit an action when it's not their turn. (CurrentPlayer == %s) Ignoring action", action.PlayerId, gameState.CurrentPlayer)
-			return changelog, fmt.Errorf("Your action was rejected because it's not your turn!") //Error message formatted for display directly to user as requested by Brian
+			return changelog, Session.NewGameError(Session.ErrorCode_NotYourTurn, "Your action was rejected because it's not your turn!", "currentPlayer", gameState.CurrentPlayer) //Error message formatted for display directly to user as requested by Brian
 		}
 	}
 
+	changelog, err = applyAction(&gameState, action)
+
+	//Every Turn checks that it can be applied before changing anything, so there's nothing to undo if it was rejected
+	if err != nil {
+		log.Printf("%s Player {%s}'s %s was rejected: %s", funcLogPrefix, action.PlayerId, action.Type, err)
+		return changelog, err
+	}
+	changelog.TurnDeadline = gameState.TurnDeadline
+
+	//Cache the updated gameState in Redis
+	_, err = CacheGameStateInRedis(gameState)
+	if err != nil {
+		err = fmt.Errorf("%s Error trying to cache updated gameState. Action may not properly persist! %s", funcLogPrefix, err)
+		LogError(funcLogPrefix, err)
+		return changelog, err
+	}
+
+	return changelog, nil
+}
+
+// Applies [action] to [gameState], without checking whose turn it is. Like every Turn, nothing changes if the action is rejected
+func applyAction(gameState *Session.GameState, action Session.SubmittedAction) (Session.Changelog, error) {
+	funcLogPrefix := "==applyAction=="
+
 	switch action.Type {
 	case Session.ActionType_Insertion:
 		turn := Session.Insertion{}
-		err = json.Unmarshal(action.Turn, &turn)
-		if err != nil {
-			LogError(funcLogPrefix, err)
-			return changelog, fmt.Errorf("%s Error trying to unmarshal turn into Insertion: %s", funcLogPrefix, err)
+		if err := json.Unmarshal(action.Turn, &turn); err != nil {
+			LogError(funcLogPrefix, fmt.Errorf("error trying to unmarshal turn into Insertion: %s", err))
+			return Session.Changelog{}, malformedTurnError(action.Type)
 		}
-		changelog, _ = turn.Execute(&gameState, action.PlayerId)
+		return turn.Execute(gameState, action.PlayerId)
 	case Session.ActionType_Withdrawal:
 		turn := Session.Withdrawal{}
-		err = json.Unmarshal(action.Turn, &turn)
-		if err != nil {
-			LogError(funcLogPrefix, err)
-			return changelog, fmt.Errorf("%s Error trying to unmarshal turn into Withdrawl: %s", funcLogPrefix, err)
+		if err := json.Unmarshal(action.Turn, &turn); err != nil {
+			LogError(funcLogPrefix, fmt.Errorf("error trying to unmarshal turn into Withdrawl: %s", err))
+			return Session.Changelog{}, malformedTurnError(action.Type)
 		}
-		changelog, _ = turn.Execute(&gameState, action.PlayerId)
+		return turn.Execute(gameState, action.PlayerId)
 	case Session.ActionType_Movement:
 		turn := Session.Movement{}
-		err = json.Unmarshal(action.Turn, &turn)
-		if err != nil {
-			LogError(funcLogPrefix, err)
-			return changelog, fmt.Errorf("%s Error trying to unmarshal turn into Movement: %s", funcLogPrefix, err)
+		if err := json.Unmarshal(action.Turn, &turn); err != nil {
+			LogError(funcLogPrefix, fmt.Errorf("error trying to unmarshal turn into Movement: %s", err))
+			return Session.Changelog{}, malformedTurnError(action.Type)
 		}
-		changelog, _ = turn.Execute(&gameState, action.PlayerId)
+		return turn.Execute(gameState, action.PlayerId)
 	case Session.ActionType_EndTurn:
 		turn := Session.EndTurn{}
-		err = json.Unmarshal(action.Turn, &turn)
-		if err != nil {
-			LogError(funcLogPrefix, err)
-			return changelog, fmt.Errorf("%s Error trying to unmarshal turn into EndTurn: %s", funcLogPrefix, err)
+		if err := json.Unmarshal(action.Turn, &turn); err != nil {
+			LogError(funcLogPrefix, fmt.Errorf("error trying to unmarshal turn into EndTurn: %s", err))
+			return Session.Changelog{}, malformedTurnError(action.Type)
 		}
-		changelog, _ = turn.Execute(&gameState, action.PlayerId)
+		return turn.Execute(gameState, action.PlayerId)
 	case Session.ActionType_CardFlip:
 		turn := Session.Cardflip{}
-		err = json.Unmarshal(action.Turn, &turn)
-		if err != nil {
-			LogError(funcLogPrefix, err)
-			return changelog, fmt.Errorf("%s Error trying to unmarshal turn into Cardflip: %s", funcLogPrefix, err)
+		if err := json.Unmarshal(action.Turn, &turn); err != nil {
+			LogError(funcLogPrefix, fmt.Errorf("error trying to unmarshal turn into Cardflip: %s", err))
+			return Session.Changelog{}, malformedTurnError(action.Type)
 		}
-		changelog, _ = turn.Execute(&gameState, action.PlayerId)
+		return turn.Execute(gameState, action.PlayerId)
 	case Session.ActionType_Reshuffle:
 		turn := Session.Reshuffle{}
-		err = json.Unmarshal(action.Turn, &turn)
-		if err != nil {
-			LogError(funcLogPrefix, err)
-			return changelog, fmt.Errorf("%s Error trying to unmarshal turn into Reshuffle: %s", funcLogPrefix, err)
+		if err := json.Unmarshal(action.Turn, &turn); err != nil {
+			LogError(funcLogPrefix, fmt.Errorf("error trying to unmarshal turn into Reshuffle: %s", err))
+			return Session.Changelog{}, malformedTurnError(action.Type)
 		}
-		changelog, _ = turn.Execute(&gameState, action.PlayerId)
+		return turn.Execute(gameState, action.PlayerId)
 	default:
-		return changelog, fmt.Errorf("%s Error - Submitted Action's type {%s} not recognized", funcLogPrefix, action.Type)
-	}
-
-	//Cache the updated gameState in Redis
-	_, err = CacheGameStateInRedis(gameState)
-	if err != nil {
-		return changelog, fmt.Errorf("%s Error trying to cache updated gameState. Action may not properly persist! %s", funcLogPrefix, err)
+		return Session.Changelog{}, Session.NewGameError(Session.ErrorCode_UnknownActionType, fmt.Sprintf("Action type {%s} not recognized", action.Type), "actionType", action.Type)
 	}
+}
 
-	return changelog, nil
+// The error returned when a SubmittedAction's Turn can't be unmarshalled into the struct matching [actionType]
+func malformedTurnError(actionType string) error {
+	return Session.NewGameError(Session.ErrorCode_MalformedRequest, fmt.Sprintf("Your %s could not be read. Please check its fields", actionType), "actionType", actionType)
 }
 
 func SaveLobbyInRedis(lobby Session.Lobby) (Session.Lobby, error) {
@@ -535,24 +816,39 @@ func SaveLobbyInRedis(lobby Session.Lobby) (Session.Lobby, error) {
 
 	log.Printf("%s Recieved request to save lobby in Redis", funcLogPrefix)
 
-	asJson, err := json.Marshal(lobby)
-	if err != nil {
+	pipe := RDB.TxPipeline()
+	if err := queueLobbySave(pipe, lobby); err != nil {
 		LogError(funcLogPrefix, err)
 		return Session.Lobby{}, err
 	}
-
-	key := "lobby:" + lobby.RoomCode
-	expiry, _ := time.ParseDuration("168h")
-	err = RDB.Set(ctx, key, asJson, expiry).Err()
-	if err != nil {
+	if _, err := pipe.Exec(ctx); err != nil {
 		LogError(funcLogPrefix, err)
 		return Session.Lobby{}, err
 	}
 
-	log.Printf("%s Lobby saved in Redis with key == {%s}", funcLogPrefix, key)
+	log.Printf("%s Lobby saved in Redis with key == {%s}", funcLogPrefix, "lobby:"+lobby.RoomCode)
 	return lobby, nil
 }
 
+// Queues saving [lobby] on [pipe], so it can be saved in the same transaction as anything else that has to change with it
+func queueLobbySave(pipe redis.Pipeliner, lobby Session.Lobby) error {
+	asJson, err := json.Marshal(lobby)
+	if err != nil {
+		return err
+	}
+
+	//Keep the index of public lobbies in step with the lobby itself, so /lobbies never has to scan for them
+	pipe.Set(ctx, "lobby:"+lobby.RoomCode, asJson, lobbyExpiry)
+	//The password has to last as long as the lobby does, or a lobby still saying it HasPassword would let anyone in. Does nothing if there isn't one
+	pipe.Expire(ctx, lobbyPasswordKey(lobby.RoomCode), lobbyExpiry)
+	if lobby.Public && lobby.Status == Session.LobbyStatus_AwaitingStart {
+		pipe.SAdd(ctx, publicLobbiesKey, lobby.RoomCode)
+	} else {
+		pipe.SRem(ctx, publicLobbiesKey, lobby.RoomCode)
+	}
+	return nil
+}
+
 func LoadLobbyFromRedis(roomCode string) (Session.Lobby, error) {
 	defer LogUtil.EnsureLogPrefixIsReset()
 	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
@@ -572,7 +868,7 @@ func LoadLobbyFromRedis(roomCode string) (Session.Lobby, error) {
 	def, err := RDB.Get(ctx, "lobby:"+roomCode).Result()
 	if err == redis.Nil {
 		log.Printf("%s Could not find cached lobby for roomCode \"%s\"...Returning Empty Lobby", funcLogPrefix, roomCode)
-		return lobby, fmt.Errorf("%s No game for Id=={%s} found", funcLogPrefix, roomCode)
+		return lobby, Session.NewGameError(Session.ErrorCode_LobbyNotFound, "Could not find the requested lobby!", "roomCode", roomCode)
 	} else if err != nil {
 		LogError(funcLogPrefix, err)
 		return lobby, err
@@ -589,8 +885,8 @@ func LoadLobbyFromRedis(roomCode string) (Session.Lobby, error) {
 	return lobby, nil
 }
 
-// Given a GameDefinition's ID and a player name, creates and saves a new lobby for that player's game, returning the Lobby's room code.
-func CreateRoom(gameDefId string) (string, error) {
+// Given a GameDefinition's ID, creates and saves a new lobby for that game, returning the Lobby's room code. If [public] is true, the lobby is listed by /lobbies
+func CreateRoom(gameDefId string, public bool) (string, error) {
 	funcLogPrefix := "==CreateRoom=="
 	defer LogUtil.EnsureLogPrefixIsReset()
 	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
@@ -608,31 +904,84 @@ func CreateRoom(gameDefId string) (string, error) {
 		Status:           Session.LobbyStatus_AwaitingStart,
 		GameName:         requestedGame.Name,
 		MaxPlayers:       requestedGame.MaxPlayers,
+		MinPlayers:       requestedGame.MinPlayers,
 		NumPlayers:       0,
 		Players:          []Player.Player{},
 		Host:             Player.Player{},
+		SeatingOrder:     Session.SeatingOrder_JoinOrder,
+		SeatArrangement:  []string{},
+		Ready:            map[string]bool{},
+		Muted:            map[string]bool{},
+		AllowSpectators:  requestedGame.Rules.SpectatorVisibility != Game.SpectatorVisibility_Disabled,
+		Spectators:       []Player.Player{},
+		Public:           public,
+		Banned:           []Player.Player{},
+		KickVotes:        map[string][]string{},
+		SeatRequests:     []Session.SeatRequest{},
+	}
+
+	log.Printf("%s Claiming a Room Code and saving Lobby to Redis", funcLogPrefix)
+	lobby, err = claimRoomCode(lobby)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return "", err
 	}
 
-	log.Printf("%s Generating Room Code", funcLogPrefix)
-	roomCode := generateRoomCode()
+	log.Printf("%s Lobby Created & Saved with RoomCode {%s}. Returning RoomCode", funcLogPrefix, lobby.RoomCode)
+	return lobby.RoomCode, nil
+}
+
+// Returns every public lobby that's still awaiting start, ordered by room code
+func GetPublicLobbies() ([]Session.LobbySummary, error) {
+	funcLogPrefix := "==GetPublicLobbies=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	roomCodes, err := RDB.SMembers(ctx, publicLobbiesKey).Result()
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return nil, err
+	}
 
-	log.Printf("%s Room Code successfully generated. Assigning RoomCode {%s} to Lobby", funcLogPrefix, roomCode)
-	lobby.RoomCode = roomCode
+	lobbies := []Session.LobbySummary{}
+	if len(roomCodes) == 0 {
+		return lobbies, nil
+	}
+	slices.Sort(roomCodes)
 
-	log.Printf("%s Saving Lobby to Redis", funcLogPrefix)
-	lobby, err = SaveLobbyInRedis(lobby)
+	keys := make([]string, len(roomCodes))
+	for index, roomCode := range roomCodes {
+		keys[index] = "lobby:" + roomCode
+	}
+	found, err := RDB.MGet(ctx, keys...).Result()
 	if err != nil {
 		LogError(funcLogPrefix, err)
-		return "", err
+		return nil, err
 	}
 
-	log.Printf("%s Lobby Created & Saved. Returning RoomCode", funcLogPrefix)
-	return roomCode, nil
+	for index, asJson := range found {
+		//Lobbies expire without being saved again, so drop any the index still remembers
+		if asJson == nil {
+			RDB.SRem(ctx, publicLobbiesKey, roomCodes[index])
+			continue
+		}
+
+		lobby := Session.Lobby{}
+		if err := json.Unmarshal([]byte(asJson.(string)), &lobby); err != nil {
+			log.Printf("%s Skipping malformed lobby {%s}: %s", funcLogPrefix, roomCodes[index], err)
+			continue
+		}
+		if lobby.Public && lobby.Status == Session.LobbyStatus_AwaitingStart {
+			lobbies = append(lobbies, lobby.Summary())
+		}
+	}
+	return lobbies, nil
 }
 
-// Creates a Player object for the given PlayerName and attempts to add them to the lobby with the given RoomCode. On Success, returns the new state
+// Creates a Player object for the given PlayerName and attempts to add them to the lobby with the given RoomCode. [formerId] is the Id the player says
+// they were given the last time they were in the lobby, if any, and is checked against its bans (see Lobby.IsBanned). On Success, returns the new state
 // of the lobby, the Player's assigned Id, and any error that occurred
-func JoinRoom(roomCode string, playerName string) (Session.Lobby, string, error) {
+func JoinRoom(roomCode string, playerName string, formerId string) (Session.Lobby, string, error) {
 	funcLogPrefix := "==JoinRoom=="
 	defer LogUtil.EnsureLogPrefixIsReset()
 	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
@@ -648,15 +997,19 @@ func JoinRoom(roomCode string, playerName string) (Session.Lobby, string, error)
 	//Only allow player to join if there's room & the game hasn't started yet (i.e. Status == LobbyStatus_AwaitingStart)
 	if lobby.NumPlayers >= lobby.MaxPlayers {
 		log.Printf("%s ERROR: Lobby's max player count {%d} already reached. Player cannot join!", funcLogPrefix, lobby.MaxPlayers)
-		return Session.Lobby{}, "", fmt.Errorf("Lobby's max player count {%d} already reached", lobby.MaxPlayers)
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_LobbyFull, fmt.Sprintf("Lobby's max player count {%d} already reached", lobby.MaxPlayers), "maxPlayers", strconv.Itoa(lobby.MaxPlayers))
 	}
 	if lobby.Status != Session.LobbyStatus_AwaitingStart {
 		log.Printf("%s Error: Game has already started. Player cannot join!", funcLogPrefix)
-		return Session.Lobby{}, "", fmt.Errorf("Game has already started!")
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_GameAlreadyStarted, "Game has already started!")
+	}
+	if lobby.IsBanned(formerId, playerName) {
+		log.Printf("%s Error: Player {%s} is banned. Player cannot join!", funcLogPrefix, playerName)
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_Banned, "You've been banned from this lobby!")
 	}
-	if slices.ContainsFunc(lobby.Players, func(p Player.Player) bool { return p.Name == playerName }) {
+	if slices.ContainsFunc(lobby.Players, func(p Player.Player) bool { return strings.EqualFold(p.Name, playerName) }) {
 		log.Printf("%s Error: Player name {%s} already taken. Player cannot join!", funcLogPrefix, playerName)
-		return Session.Lobby{}, "", fmt.Errorf("Name already taken!")
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_NameTaken, "Name already taken!", "playerName", playerName)
 	}
 
 	thisPlayer := createPlayerObject(playerName)
@@ -686,7 +1039,9 @@ func JoinRoom(roomCode string, playerName string) (Session.Lobby, string, error)
 	return saved, thisPlayer.Id, nil
 }
 
-func LeaveRoom(roomCode string, playerId string) (Session.Lobby, error) {
+// Removes [playerId] from the lobby with [roomCode]. If the game is in progress, their seat is also dealt with according to the game's LeavePolicy,
+// and the resulting Changelog is returned so it can be sent to everyone still playing. Otherwise, the returned Changelog is nil
+func LeaveRoom(roomCode string, playerId string) (Session.Lobby, *Session.Changelog, error) {
 	funcLogPrefix := "==LeaveRoom=="
 	defer LogUtil.EnsureLogPrefixIsReset()
 	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
@@ -696,7 +1051,7 @@ func LeaveRoom(roomCode string, playerId string) (Session.Lobby, error) {
 	lobby, err := LoadLobbyFromRedis(roomCode)
 	if err != nil {
 		LogError(funcLogPrefix, err)
-		return Session.Lobby{}, err
+		return Session.Lobby{}, nil, err
 	}
 
 	//Create a copy, in case anything goes wrong
@@ -716,49 +1071,58 @@ func LeaveRoom(roomCode string, playerId string) (Session.Lobby, error) {
 	updatedLobby.Players = newPlayers
 	updatedLobby.NumPlayers = len(newPlayers)
 
+	//If the host is leaving, hand the lobby to whoever joined after them
+	if lobby.Host.Id == playerId {
+		updatedLobby.Host = nextHost(lobby, playerId)
+		log.Printf("%s Host is leaving. Player {%s} is the new host", funcLogPrefix, updatedLobby.Host.Id)
+	}
+	updatedLobby.Ready = maps.Clone(lobby.Ready)
+	delete(updatedLobby.Ready, playerId)
+	updatedLobby.Muted = maps.Clone(lobby.Muted)
+	delete(updatedLobby.Muted, playerId)
+
+	//Votes against the player no longer matter, and neither do any votes they cast
+	updatedLobby.KickVotes = map[string][]string{}
+	for targetId, votes := range lobby.KickVotes {
+		votes = slices.DeleteFunc(slices.Clone(votes), func(id string) bool { return id == playerId })
+		if targetId != playerId && len(votes) > 0 {
+			updatedLobby.KickVotes[targetId] = votes
+		}
+	}
+
 	log.Printf("%s Player Removed. Caching new Lobby", funcLogPrefix)
 	saved, err := SaveLobbyInRedis(updatedLobby)
 	if err != nil { //If something goes wrong, re-save and return the version without any changes
 		SaveLobbyInRedis(lobby)
-		return Session.Lobby{}, err
+		return Session.Lobby{}, nil, err
 	}
 
-	//If the game has started, we need to remove them from the GameState too
+	//If the game has started, their seat needs to be dealt with according to the game's LeavePolicy too. They've already left the lobby by now,
+	//so anything going wrong here is only logged
 	if saved.Status == Session.LobbyStatus_InProgress {
-		log.Println("Player is being removed from an in-progress game. Removing player from GameState...")
+		log.Printf("%s Player is leaving an in-progress game. Vacating their seat...", funcLogPrefix)
 		gameState, err := GetCachedGameStateFromRedis(saved.GameStateId)
 		if err != nil {
 			LogError(funcLogPrefix, err)
+			return saved, nil, nil
 		}
 
-		//If it's this player's turn, end their turn before removing them
-		if gameState.CurrentPlayer == playerId {
-			log.Println("GameState is listing Player as CurrentPlayer. Ending their turn before removal...")
-			Session.EndTurn{}.Execute(&gameState, playerId)
-		}
-
-		//Remove from Player list
-		currentPlayers := slices.Clone(gameState.Players)
-		newPlayers = []Player.Player{}
-
-		for _, player := range currentPlayers {
-			if player.Id != playerId {
-				newPlayers = append(newPlayers, player)
-			}
-		}
-
-		gameState.Players = newPlayers
-
-		log.Println("Player has been removed from GameState. (NOTE: THIS HAS ALSO REMOVED ALL PIECES IN THEIR HAND FROM THE GAME. WILL FIX LATER) Caching new GameState now...")
-		_, err = CacheGameStateInRedis(gameState)
+		changelog, err := gameState.VacateSeat(playerId)
 		if err != nil {
 			LogError(funcLogPrefix, err)
+			return saved, nil, nil
 		}
 
+		log.Printf("%s %s. Caching new GameState now...", funcLogPrefix, changelog.MostRecentAction)
+		if _, err := CacheGameStateInRedis(gameState); err != nil {
+			LogError(funcLogPrefix, err)
+			return saved, nil, nil
+		}
+		return saved, &changelog, nil
 	}
 
 	log.Printf("%s Left Lobby. Returning Lobby", funcLogPrefix)
-	return saved, nil
+	return saved, nil, nil
 }
 
 func createPlayerObject(name string) Player.Player {
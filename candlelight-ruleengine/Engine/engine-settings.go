@@ -0,0 +1,174 @@
+package Engine
+
+import (
+	"candlelight-api/LogUtil"
+	"candlelight-models/Player"
+	"candlelight-models/Session"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The longest password (in bytes) a lobby may have. bcrypt ignores anything past this
+const MaxLobbyPasswordLength = 72
+
+// The longest turn time limit (in seconds) a host may set for their lobby
+const MaxTurnTimeLimit = 3600
+
+// Everything the host may change about a lobby before the game starts. Any field left out is left as it is
+type LobbySettings struct {
+	//The Id of the game definition to play instead. The lobby's MaxPlayers and MinPlayers are reset to the new game's
+	GameDefinitionId *string `json:"gameId"`
+	//Lowers the most players allowed in the lobby. Can't be more than the game allows, or fewer than the players already in the lobby
+	MaxPlayers *int `json:"maxPlayers"`
+	//Overrides the game definition's EnforceTurnOrder rule for this lobby
+	EnforceTurnOrder *bool `json:"enforceTurnOrder"`
+	//Overrides the game definition's ShowOtherPlayerDetails rule for this lobby
+	ShowOtherPlayerDetails *bool `json:"showOtherPlayerDetails"`
+	//Overrides the game definition's TurnTimeLimit rule for this lobby. 0 means no limit
+	TurnTimeLimit *int `json:"turnTimeLimit"`
+	//The password players must give to join. An empty string removes the password
+	Password *string `json:"password"`
+	//Whether the lobby is listed by /lobbies
+	Public *bool `json:"public"`
+	//How players should be seated once the game starts. One of the Session.SeatingOrder constants
+	SeatingOrder *string `json:"seatingOrder"`
+	//Only used with SeatingOrder_HostArranged. The Ids of players in the order they should be seated
+	SeatArrangement []string `json:"seatArrangement"`
+}
+
+func lobbyPasswordKey(roomCode string) string {
+	return "lobbyPassword:" + roomCode
+}
+
+// Applies [settings] to the lobby with [roomCode]. Only the host, [hostId], may do this, and only before the game starts. Every setting is
+// checked before any of them are applied, so either all of them change or none do. Returns the new state of the lobby and any spectators who had
+// to be removed because the new game doesn't allow spectating
+func UpdateLobbySettings(roomCode string, hostId string, settings LobbySettings) (Session.Lobby, []Player.Player, error) {
+	funcLogPrefix := "==UpdateLobbySettings=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, nil, err
+	}
+
+	if lobby.Host.Id != hostId {
+		return Session.Lobby{}, nil, Session.NewGameError(Session.ErrorCode_NotHost, "Only the host can change the lobby's settings!")
+	}
+	if lobby.Status != Session.LobbyStatus_AwaitingStart {
+		return Session.Lobby{}, nil, Session.NewGameError(Session.ErrorCode_GameAlreadyStarted, "Settings can't be changed once the game has started!")
+	}
+
+	removed := []Player.Player{}
+	if settings.GameDefinitionId != nil && *settings.GameDefinitionId != lobby.GameDefinitionId {
+		removed, err = switchGameDefinition(&lobby, *settings.GameDefinitionId)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+			return Session.Lobby{}, nil, err
+		}
+	}
+
+	if settings.MaxPlayers != nil {
+		gameDef, err := GetGameDefFromDB(lobby.GameDefinitionId)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+			return Session.Lobby{}, nil, err
+		}
+
+		maxPlayers := *settings.MaxPlayers
+		lowest := max(lobby.NumPlayers, lobby.MinPlayers, 1)
+		if maxPlayers < lowest || maxPlayers > gameDef.MaxPlayers {
+			return Session.Lobby{}, nil, Session.NewGameError(Session.ErrorCode_InvalidSettings, fmt.Sprintf("Max players must be between %d and %d!", lowest, gameDef.MaxPlayers),
+				"setting", "maxPlayers", "min", strconv.Itoa(lowest), "max", strconv.Itoa(gameDef.MaxPlayers))
+		}
+		lobby.MaxPlayers = maxPlayers
+	}
+
+	if settings.EnforceTurnOrder != nil {
+		lobby.RuleOverrides.EnforceTurnOrder = settings.EnforceTurnOrder
+	}
+	if settings.ShowOtherPlayerDetails != nil {
+		lobby.RuleOverrides.ShowOtherPlayerDetails = settings.ShowOtherPlayerDetails
+	}
+	if settings.TurnTimeLimit != nil {
+		if *settings.TurnTimeLimit < 0 || *settings.TurnTimeLimit > MaxTurnTimeLimit {
+			return Session.Lobby{}, nil, Session.NewGameError(Session.ErrorCode_InvalidSettings, fmt.Sprintf("Turn time limit must be between 0 and %d seconds!", MaxTurnTimeLimit),
+				"setting", "turnTimeLimit", "min", "0", "max", strconv.Itoa(MaxTurnTimeLimit))
+		}
+		lobby.RuleOverrides.TurnTimeLimit = settings.TurnTimeLimit
+	}
+
+	if settings.SeatingOrder != nil {
+		if err := setSeating(&lobby, *settings.SeatingOrder, settings.SeatArrangement); err != nil {
+			return Session.Lobby{}, nil, err
+		}
+	}
+
+	var passwordHash []byte
+	if settings.Password != nil {
+		if len(*settings.Password) > MaxLobbyPasswordLength {
+			return Session.Lobby{}, nil, Session.NewGameError(Session.ErrorCode_InvalidSettings, fmt.Sprintf("Passwords can't be longer than %d characters!", MaxLobbyPasswordLength),
+				"setting", "password", "max", strconv.Itoa(MaxLobbyPasswordLength))
+		}
+		passwordHash, err = hashLobbyPassword(*settings.Password)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+			return Session.Lobby{}, nil, err
+		}
+		lobby.HasPassword = *settings.Password != ""
+	}
+
+	if settings.Public != nil {
+		lobby.Public = *settings.Public
+	}
+
+	log.Printf("%s Host has updated the settings of lobby {%s}", funcLogPrefix, roomCode)
+	//The password is saved in the same transaction as the lobby, so HasPassword never disagrees with whether joining actually needs one
+	pipe := RDB.TxPipeline()
+	if err := queueLobbySave(pipe, lobby); err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, nil, err
+	}
+	if settings.Password != nil {
+		queueLobbyPassword(pipe, roomCode, passwordHash)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, nil, err
+	}
+	return lobby, removed, nil
+}
+
+// Returns the hash to store for [password], or nil if [password] is empty and the lobby shouldn't have one
+func hashLobbyPassword(password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
+
+// Queues saving [hash] as the password of the lobby with [roomCode] on [pipe], or removing the lobby's password if [hash] is nil
+func queueLobbyPassword(pipe redis.Pipeliner, roomCode string, hash []byte) {
+	if hash == nil {
+		pipe.Del(ctx, lobbyPasswordKey(roomCode))
+		return
+	}
+	pipe.Set(ctx, lobbyPasswordKey(roomCode), hash, lobbyExpiry)
+}
+
+// Returns whether [password] lets a player into the lobby with [roomCode]. Any password does if the lobby doesn't have one
+func CheckLobbyPassword(roomCode string, password string) (bool, error) {
+	hash, err := RDB.Get(ctx, lobbyPasswordKey(roomCode)).Result()
+	if err == redis.Nil {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, nil
+}
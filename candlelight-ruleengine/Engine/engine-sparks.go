@@ -0,0 +1,347 @@
+package Engine
+
+import (
+	"candlelight-models/Game"
+	"candlelight-models/Pieces"
+	"candlelight-models/Player"
+	"candlelight-models/Session"
+	"candlelight-models/Sparks"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+)
+
+// Options the host can send along with their startGame message
+type StartOptions struct {
+	//Id of the player who should take the first turn. Only used by a StartingPlayer Spark in StartingPlayerMode_Chosen
+	StartingPlayer string `json:"startingPlayer"`
+}
+
+// Everything a Spark might need to know about how the game is being started, beyond the GameState itself
+type SparkContext struct {
+	//The Lobby the game is being started from
+	Lobby Session.Lobby
+	//The options the host started the game with
+	Options StartOptions
+}
+
+// The implementation of a Spark. Given the initial GameState and the Spark's raw Config, it should apply whatever
+// automation the Spark describes directly to the GameState. Any returned error prevents the game from starting, so
+// implementations should check that their config makes sense for this GameState before changing anything
+type SparkImplementation func(gameState *Session.GameState, setup SparkContext, config json.RawMessage) error
+
+// Maps each Spark Type to its implementation. Add new Sparks here (or through RegisterSpark) instead of editing applySparks
+var sparkRegistry = map[string]SparkImplementation{
+	Sparks.SparkType_Dealer:         sparkOf(applyDealer),
+	Sparks.SparkType_Flipper:        sparkOf(applyFlipper),
+	Sparks.SparkType_Shuffler:       sparkOf(applyShuffler),
+	Sparks.SparkType_StartingPlayer: sparkOf(applyStartingPlayer),
+	Sparks.SparkType_DeckBuilder:    sparkOf(applyDeckBuilder),
+}
+
+// Registers [implementation] under [sparkType], replacing any implementation already registered for that type
+func RegisterSpark(sparkType string, implementation SparkImplementation) {
+	sparkRegistry[sparkType] = implementation
+}
+
+// Wraps a function taking a typed Spark config into a SparkImplementation that unmarshals the raw Config first
+func sparkOf[T any](apply func(gameState *Session.GameState, setup SparkContext, spark T) error) SparkImplementation {
+	return func(gameState *Session.GameState, setup SparkContext, config json.RawMessage) error {
+		var spark T
+		if err := json.Unmarshal(config, &spark); err != nil {
+			return fmt.Errorf("error trying to unmarshal config into %T: %s", spark, err)
+		}
+		return apply(gameState, setup, spark)
+	}
+}
+
+// Applies each of [sparks] to [gameState] in order. Stops at the first Spark that can't be applied and returns an error
+// describing which Spark failed and why, formatted for display to the host
+func applySparks(gameState *Session.GameState, setup SparkContext, sparks Sparks.Sparks) error {
+	for index, spark := range sparks {
+		implementation, exists := sparkRegistry[spark.Type]
+		if !exists {
+			return Session.NewGameError(Session.ErrorCode_SparkFailed, fmt.Sprintf("Spark #%d has unrecognized type {%s}", index+1, spark.Type),
+				"sparkIndex", strconv.Itoa(index), "sparkType", spark.Type)
+		}
+		if err := implementation(gameState, setup, spark.Config); err != nil {
+			return Session.NewGameError(Session.ErrorCode_SparkFailed, fmt.Sprintf("Spark #%d ({%s}) could not be applied: %s", index+1, spark.Type, err),
+				"sparkIndex", strconv.Itoa(index), "sparkType", spark.Type)
+		}
+	}
+	return nil
+}
+
+func applyDealer(gameState *Session.GameState, setup SparkContext, dealer Sparks.Dealer) error {
+	deckToUse := findDeckInGameState(gameState, dealer.DeckToUse)
+	if deckToUse == nil {
+		return fmt.Errorf("could not find deck to deal from with Id == {%s}", dealer.DeckToUse)
+	}
+
+	discardPile, err := findDiscardPile(gameState, dealer.WhenEmpty, dealer.DiscardPile)
+	if err != nil {
+		return err
+	}
+
+	//Find every target View before dealing anything, so a missing View doesn't leave the GameState half-dealt
+	targetViews := []*Game.View{}
+	for index := range gameState.Players {
+		view, err := findHandView(&gameState.Players[index], dealer.ToViews)
+		if err != nil {
+			return err
+		}
+		targetViews = append(targetViews, view)
+	}
+
+	numToDeal := dealer.NumToDeal
+	if dealer.WhenEmpty == Sparks.WhenEmpty_DealEvenly && len(targetViews) > 0 {
+		numToDeal = min(numToDeal, deckToUse.CollectionLength()/len(targetViews))
+	}
+
+	//Deal one card at a time around the table, so that if the deck runs out, nobody is left with far fewer cards than anyone else
+	for x := range numToDeal {
+		for _, view := range targetViews {
+			card := drawFromDeck(deckToUse, discardPile)
+			if card == nil {
+				if dealer.WhenEmpty == "" || dealer.WhenEmpty == Sparks.WhenEmpty_Fail {
+					return fmt.Errorf("deck {%s} ran out of cards before everyone was dealt %d. Use whenEmpty {%s} to deal fewer cards to everyone instead",
+						dealer.DeckToUse, numToDeal, Sparks.WhenEmpty_DealEvenly)
+				}
+				return nil
+			}
+			card.ParentView = view.Id
+			//Put X as 0, 20, 40, etc
+			card.X = float32(x * 20)
+			card.Y = 0
+
+			view.Pieces.Orphans = append(view.Pieces.Orphans, *card)
+		}
+	}
+
+	return nil
+}
+
+func applyFlipper(gameState *Session.GameState, setup SparkContext, flipper Sparks.Flipper) error {
+	deckToUse := findDeckInGameState(gameState, flipper.DeckToUse)
+	if deckToUse == nil {
+		return fmt.Errorf("could not find deck to flip from with Id == {%s}", flipper.DeckToUse)
+	}
+
+	cardPlaceToUse := findCardPlaceInGameState(gameState, flipper.CardPlaceToUse)
+	if cardPlaceToUse == nil {
+		return fmt.Errorf("could not find cardplace to put cards in with Id == {%s}", flipper.CardPlaceToUse)
+	}
+
+	discardPile, err := findDiscardPile(gameState, flipper.WhenEmpty, flipper.DiscardPile)
+	if err != nil {
+		return err
+	}
+
+	for range flipper.NumToFlip {
+		card := drawFromDeck(deckToUse, discardPile)
+		if card == nil {
+			if flipper.WhenEmpty == Sparks.WhenEmpty_Fail {
+				return fmt.Errorf("deck {%s} ran out of cards before %d were flipped", flipper.DeckToUse, flipper.NumToFlip)
+			}
+			return nil
+		}
+		card.ParentView = cardPlaceToUse.ParentView
+
+		cardPlaceToUse.AddCardToCollection(*card)
+	}
+
+	return nil
+}
+
+// Checks that [whenEmpty] is a recognized policy and, if it's WhenEmpty_Reshuffle, finds and returns the CardPlace with an Id == [discardPileId]
+func findDiscardPile(gameState *Session.GameState, whenEmpty string, discardPileId string) (*Pieces.CardPlace, error) {
+	switch whenEmpty {
+	case "", Sparks.WhenEmpty_Fail, Sparks.WhenEmpty_Stop, Sparks.WhenEmpty_DealEvenly:
+		return nil, nil
+	case Sparks.WhenEmpty_Reshuffle:
+		discardPile := findCardPlaceInGameState(gameState, discardPileId)
+		if discardPile == nil {
+			return nil, fmt.Errorf("could not find discard pile to reshuffle from with Id == {%s}", discardPileId)
+		}
+		return discardPile, nil
+	default:
+		return nil, fmt.Errorf("whenEmpty policy {%s} not recognized", whenEmpty)
+	}
+}
+
+// Removes a random card from [deck] and returns a copy of it. If [deck] is empty and [discardPile] is given, the discard pile is
+// reshuffled into [deck] first. Returns nil if there's no card left to draw
+func drawFromDeck(deck *Pieces.Deck, discardPile *Pieces.CardPlace) *Pieces.Card {
+	if deck.CollectionLength() == 0 && discardPile != nil {
+		for _, card := range discardPile.Cards {
+			card.ParentView = deck.ParentView
+			deck.AddCardToCollection(card)
+		}
+		discardPile.Cards = []Pieces.Card{}
+	}
+
+	cardWithdraw := deck.PickRandomCardFromCollection()
+	if cardWithdraw == nil {
+		return nil
+	}
+	cardCopy := *cardWithdraw
+	deck.RemoveCardFromCollection(cardCopy)
+	return &cardCopy
+}
+
+func applyShuffler(gameState *Session.GameState, setup SparkContext, shuffler Sparks.Shuffler) error {
+	decks := []*Pieces.Deck{}
+	for _, deckId := range shuffler.DecksToShuffle {
+		deck := findDeckInGameState(gameState, deckId)
+		if deck == nil {
+			return fmt.Errorf("could not find deck to shuffle with Id == {%s}", deckId)
+		}
+		decks = append(decks, deck)
+	}
+
+	for _, deck := range decks {
+		rand.Shuffle(len(deck.Cards), func(i, j int) { deck.Cards[i], deck.Cards[j] = deck.Cards[j], deck.Cards[i] })
+	}
+
+	return nil
+}
+
+func applyStartingPlayer(gameState *Session.GameState, setup SparkContext, startingPlayer Sparks.StartingPlayer) error {
+	if len(gameState.Players) == 0 {
+		return fmt.Errorf("there are no players to choose from")
+	}
+
+	startingId := ""
+	switch startingPlayer.Mode {
+	case Sparks.StartingPlayerMode_First, "":
+		startingId = gameState.Players[0].Id
+	case Sparks.StartingPlayerMode_Random:
+		startingId = gameState.Players[rand.Intn(len(gameState.Players))].Id
+	case Sparks.StartingPlayerMode_Host:
+		startingId = setup.Lobby.Host.Id
+	case Sparks.StartingPlayerMode_Chosen:
+		startingId = setup.Options.StartingPlayer
+		if startingId == "" {
+			return fmt.Errorf("the host must choose a starting player when starting this game")
+		}
+	default:
+		return fmt.Errorf("mode {%s} not recognized", startingPlayer.Mode)
+	}
+
+	if !slices.ContainsFunc(gameState.Players, func(p Player.Player) bool { return p.Id == startingId }) {
+		return fmt.Errorf("could not find starting player with Id == {%s} in the game", startingId)
+	}
+
+	gameState.CurrentPlayer = startingId
+	return nil
+}
+
+func applyDeckBuilder(gameState *Session.GameState, setup SparkContext, deckBuilder Sparks.DeckBuilder) error {
+	template := findDeckInGameState(gameState, deckBuilder.TemplateDeck)
+	if template == nil {
+		return fmt.Errorf("could not find template deck with Id == {%s}", deckBuilder.TemplateDeck)
+	}
+
+	//Find every target View before giving anyone a deck, so a missing View doesn't leave the GameState half-built
+	targetViews := []*Game.View{}
+	for index := range gameState.Players {
+		view, err := findHandView(&gameState.Players[index], deckBuilder.ToViews)
+		if err != nil {
+			return err
+		}
+		targetViews = append(targetViews, view)
+	}
+
+	templateCopy := *template
+	for _, view := range targetViews {
+		view.Pieces.Decks = append(view.Pieces.Decks, cloneDeck(templateCopy, view.Id))
+	}
+
+	if !deckBuilder.KeepTemplate {
+		removeDeckFromGameState(gameState, templateCopy.Id)
+	}
+
+	return nil
+}
+
+// Finds the first View in [player]'s Hand whose Id (or TemplateId, if it was copied from a template) is in [viewIds], or their first View if [viewIds] is empty. Returns an error formatted for display to the host if there is no such View
+func findHandView(player *Player.Player, viewIds []string) (*Game.View, error) {
+	if len(player.Hand) == 0 {
+		return nil, fmt.Errorf("player '%s' has no Views in their hand", player.Name)
+	}
+	if len(viewIds) == 0 {
+		return &player.Hand[0], nil
+	}
+	for index := range player.Hand {
+		if slices.Contains(viewIds, player.Hand[index].Id) || slices.Contains(viewIds, player.Hand[index].TemplateId) {
+			return &player.Hand[index], nil
+		}
+	}
+	return nil, fmt.Errorf("player '%s' does not have any of the Views {%v} in their hand", player.Name, viewIds)
+}
+
+// Finds the Deck with an Id == [deckId] in any View of [gameState], public or belonging to a player. Returns nil if no such Deck exists
+func findDeckInGameState(gameState *Session.GameState, deckId string) *Pieces.Deck {
+	for _, view := range allViews(gameState) {
+		index := slices.IndexFunc(view.Pieces.Decks, func(d Pieces.Deck) bool { return d.Id == deckId })
+		if index != -1 {
+			return &view.Pieces.Decks[index]
+		}
+	}
+	return nil
+}
+
+// Finds the CardPlace with an Id == [cardPlaceId] in any View of [gameState], public or belonging to a player. Returns nil if no such CardPlace exists
+func findCardPlaceInGameState(gameState *Session.GameState, cardPlaceId string) *Pieces.CardPlace {
+	for _, view := range allViews(gameState) {
+		index := slices.IndexFunc(view.Pieces.CardPlaces, func(cp Pieces.CardPlace) bool { return cp.Id == cardPlaceId })
+		if index != -1 {
+			return &view.Pieces.CardPlaces[index]
+		}
+	}
+	return nil
+}
+
+// Removes any Deck with an Id == [deckId] from every View of [gameState]
+func removeDeckFromGameState(gameState *Session.GameState, deckId string) {
+	for _, view := range allViews(gameState) {
+		view.Pieces.Decks = slices.DeleteFunc(view.Pieces.Decks, func(d Pieces.Deck) bool { return d.Id == deckId })
+	}
+}
+
+// Returns pointers to every View in [gameState], public Views first, then each player's Hand in player order
+func allViews(gameState *Session.GameState) []*Game.View {
+	views := []*Game.View{}
+	for index := range gameState.Views {
+		views = append(views, &gameState.Views[index])
+	}
+	for playerIndex := range gameState.Players {
+		for index := range gameState.Players[playerIndex].Hand {
+			views = append(views, &gameState.Players[playerIndex].Hand[index])
+		}
+	}
+	return views
+}
+
+// Copies [deck] and all of its cards, giving each a fresh Id and setting their ParentView to [parentView]
+func cloneDeck(deck Pieces.Deck, parentView string) Pieces.Deck {
+	clone := deck
+	clone.Id = GenerateId()
+	clone.ParentView = parentView
+	clone.Cards = cloneCards(deck.Cards, parentView)
+	return clone
+}
+
+// Copies each of [cards], giving each copy a fresh Id and setting its ParentView to [parentView]
+func cloneCards(cards []Pieces.Card, parentView string) []Pieces.Card {
+	clones := []Pieces.Card{}
+	for _, card := range cards {
+		cardCopy := card
+		cardCopy.Id = GenerateId()
+		cardCopy.ParentView = parentView
+		clones = append(clones, cardCopy)
+	}
+	return clones
+}
@@ -0,0 +1,111 @@
+package Engine
+
+import (
+	"candlelight-api/LogUtil"
+	"candlelight-models/Game"
+	"candlelight-models/Player"
+	"candlelight-models/Session"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Adds a spectator with the given [name] to the lobby with [roomCode]. Spectators aren't counted against the lobby's MaxPlayers, and can join
+// whether or not the game has started. [formerId] is the Id they were given the last time they were in the lobby, if any, and is checked against
+// its bans. On success, returns the new state of the lobby and the spectator's assigned Id
+func SpectateRoom(roomCode string, name string, formerId string) (Session.Lobby, string, error) {
+	funcLogPrefix := "==SpectateRoom=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	log.Printf("%s Recieved request from {%s} to spectate lobby with RoomCode == {%s}", funcLogPrefix, name, roomCode)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, "", err
+	}
+
+	if !lobby.AllowSpectators {
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_SpectatingDisabled, "This lobby isn't accepting spectators!")
+	}
+	if lobby.Status == Session.LobbyStatus_Ended {
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_GameEnded, "Game has already ended!")
+	}
+	if strings.TrimSpace(name) == "" {
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_MalformedRequest, "Spectators need a name!")
+	}
+	if lobby.IsBanned(formerId, name) {
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_Banned, "You've been banned from this lobby!")
+	}
+
+	spectator := createPlayerObject(name)
+	lobby.Spectators = append(slices.Clone(lobby.Spectators), spectator)
+
+	saved, err := SaveLobbyInRedis(lobby)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, "", err
+	}
+	return saved, spectator.Id, nil
+}
+
+// Removes the spectator with [spectatorId] from the lobby with [roomCode], returning the new state of the lobby
+func LeaveAsSpectator(roomCode string, spectatorId string) (Session.Lobby, error) {
+	funcLogPrefix := "==LeaveAsSpectator=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, err
+	}
+
+	lobby.Spectators = slices.DeleteFunc(slices.Clone(lobby.Spectators), func(p Player.Player) bool { return p.Id == spectatorId })
+	return SaveLobbyInRedis(lobby)
+}
+
+// Lets the host of the lobby with [roomCode] turn spectating on or off. Turning it off removes everyone currently spectating, who are returned
+// along with the new state of the lobby so their connections can be closed. Spectating can't be turned on for a game whose definition disables it
+func SetSpectatorsAllowed(roomCode string, hostId string, allowed bool) (Session.Lobby, []Player.Player, error) {
+	funcLogPrefix := "==SetSpectatorsAllowed=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, nil, err
+	}
+
+	if lobby.Host.Id != hostId {
+		return Session.Lobby{}, nil, Session.NewGameError(Session.ErrorCode_NotHost, "Only the host can change who may spectate!")
+	}
+
+	if allowed {
+		gameDef, err := GetGameDefFromDB(lobby.GameDefinitionId)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+			return Session.Lobby{}, nil, err
+		}
+		if gameDef.Rules.SpectatorVisibility == Game.SpectatorVisibility_Disabled {
+			return Session.Lobby{}, nil, Session.NewGameError(Session.ErrorCode_SpectatingDisabled, "This game doesn't allow spectators!")
+		}
+	}
+
+	removed := []Player.Player{}
+	if !allowed {
+		removed = lobby.Spectators
+		lobby.Spectators = []Player.Player{}
+	}
+	lobby.AllowSpectators = allowed
+
+	log.Printf("%s Host has set spectating in lobby {%s} to %t", funcLogPrefix, roomCode, allowed)
+	saved, err := SaveLobbyInRedis(lobby)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, nil, err
+	}
+	return saved, removed, nil
+}
@@ -0,0 +1,170 @@
+package Engine
+
+import (
+	"candlelight-api/LogUtil"
+	"candlelight-models/Player"
+	"candlelight-models/Session"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Asks for a new player named [playerName] to take over a seat left open by someone who left the in-progress game in the lobby with [roomCode].
+// If [seatId] is empty, the first open seat nobody has asked for yet is picked. The request waits in the lobby's SeatRequests until the host answers
+// it with AnswerSeatRequest. [formerId] is the Id the player was given the last time they were in the lobby, if any, and is checked against its bans.
+// On success, returns the new state of the lobby and the Id the new player will have
+func RequestSeat(roomCode string, playerName string, seatId string, formerId string) (Session.Lobby, string, error) {
+	funcLogPrefix := "==RequestSeat=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	log.Printf("%s Recieved request from {%s} to take over a seat in lobby with RoomCode == {%s}", funcLogPrefix, playerName, roomCode)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, "", err
+	}
+
+	if lobby.Status == Session.LobbyStatus_Ended {
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_GameEnded, "Game has already ended!")
+	}
+	if lobby.Status != Session.LobbyStatus_InProgress {
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_NoOpenSeat, "Seats can only be taken over once the game has started!")
+	}
+	if strings.TrimSpace(playerName) == "" {
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_MalformedRequest, "Players need a name!")
+	}
+	if lobby.IsBanned(formerId, playerName) {
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_Banned, "You've been banned from this lobby!")
+	}
+	if slices.ContainsFunc(lobby.Players, func(p Player.Player) bool { return strings.EqualFold(p.Name, playerName) }) ||
+		slices.ContainsFunc(lobby.SeatRequests, func(r Session.SeatRequest) bool { return strings.EqualFold(r.Player.Name, playerName) }) {
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_NameTaken, "Name already taken!", "playerName", playerName)
+	}
+
+	gameState, err := GetCachedGameStateFromRedis(lobby.GameStateId)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, "", err
+	}
+
+	openSeats := gameState.OpenSeats()
+	if len(openSeats) == 0 {
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_GameAlreadyStarted, "Game has already started, and there are no open seats to take over!")
+	}
+	if seatId != "" && !slices.Contains(openSeats, seatId) {
+		return Session.Lobby{}, "", Session.NewGameError(Session.ErrorCode_NoOpenSeat, "That seat isn't open to take over!", "seatId", seatId)
+	}
+	if seatId == "" {
+		//Prefer a seat nobody is waiting for yet, but let them queue up for one if every seat has been asked for
+		seatId = openSeats[0]
+		for _, open := range openSeats {
+			if !slices.ContainsFunc(lobby.SeatRequests, func(r Session.SeatRequest) bool { return r.SeatId == open }) {
+				seatId = open
+				break
+			}
+		}
+	}
+
+	request := Session.SeatRequest{Player: createPlayerObject(playerName), SeatId: seatId}
+	lobby.SeatRequests = append(slices.Clone(lobby.SeatRequests), request)
+
+	log.Printf("%s {%s} is waiting for the host to let them take over seat {%s}", funcLogPrefix, playerName, seatId)
+	saved, err := SaveLobbyInRedis(lobby)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, "", err
+	}
+	return saved, request.Player.Id, nil
+}
+
+// Lets the host, [hostId], approve or decline [playerId]'s request to take over a seat in the lobby with [roomCode]. On approval, [playerId] joins the
+// lobby as a player and takes the seat's place in the GameState, inheriting its hand and position in the turn order, and anyone else waiting for the
+// same seat is declined. Returns the new state of the lobby, the new GameState (nil if nobody was seated), and the Ids of everyone who was declined
+func AnswerSeatRequest(roomCode string, hostId string, playerId string, approve bool) (Session.Lobby, *Session.GameState, []string, error) {
+	funcLogPrefix := "==AnswerSeatRequest=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, nil, nil, err
+	}
+
+	if lobby.Host.Id != hostId {
+		return Session.Lobby{}, nil, nil, Session.NewGameError(Session.ErrorCode_NotHost, "Only the host can let players take over seats!")
+	}
+	index := slices.IndexFunc(lobby.SeatRequests, func(r Session.SeatRequest) bool { return r.Player.Id == playerId })
+	if index < 0 {
+		return Session.Lobby{}, nil, nil, Session.NewGameError(Session.ErrorCode_PlayerNotFound, "Could not find that player's request to take over a seat!", "playerId", playerId)
+	}
+	request := lobby.SeatRequests[index]
+
+	if !approve {
+		lobby.SeatRequests = slices.Delete(slices.Clone(lobby.SeatRequests), index, index+1)
+		log.Printf("%s Host has declined {%s}'s request to take over seat {%s}", funcLogPrefix, playerId, request.SeatId)
+		saved, err := SaveLobbyInRedis(lobby)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+			return Session.Lobby{}, nil, nil, err
+		}
+		return saved, nil, []string{playerId}, nil
+	}
+
+	gameState, err := GetCachedGameStateFromRedis(lobby.GameStateId)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, nil, nil, err
+	}
+	if err := gameState.TakeOverSeat(request.SeatId, request.Player); err != nil {
+		return Session.Lobby{}, nil, nil, err
+	}
+	if _, err := CacheGameStateInRedis(gameState); err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, nil, nil, err
+	}
+
+	lobby.Players = append(slices.Clone(lobby.Players), request.Player)
+	lobby.NumPlayers = len(lobby.Players)
+
+	//Nobody else can have the seat now
+	declined := []string{}
+	remaining := []Session.SeatRequest{}
+	for _, other := range lobby.SeatRequests {
+		if other.Player.Id == playerId {
+			continue
+		}
+		if other.SeatId == request.SeatId {
+			declined = append(declined, other.Player.Id)
+		} else {
+			remaining = append(remaining, other)
+		}
+	}
+	lobby.SeatRequests = remaining
+
+	log.Printf("%s Host has let {%s} take over seat {%s}", funcLogPrefix, playerId, request.SeatId)
+	saved, err := SaveLobbyInRedis(lobby)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, nil, nil, err
+	}
+	return saved, &gameState, declined, nil
+}
+
+// Takes back [playerId]'s request to take over a seat in the lobby with [roomCode], i.e. because they've disconnected while waiting
+func WithdrawSeatRequest(roomCode string, playerId string) (Session.Lobby, error) {
+	funcLogPrefix := "==WithdrawSeatRequest=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return Session.Lobby{}, err
+	}
+
+	lobby.SeatRequests = slices.DeleteFunc(slices.Clone(lobby.SeatRequests), func(r Session.SeatRequest) bool { return r.Player.Id == playerId })
+	return SaveLobbyInRedis(lobby)
+}
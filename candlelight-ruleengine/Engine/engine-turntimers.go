@@ -0,0 +1,200 @@
+package Engine
+
+import (
+	"candlelight-api/LogUtil"
+	"candlelight-models/Game"
+	"candlelight-models/Session"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long before their deadline a player is warned, unless the game's rules say otherwise
+const defaultTurnWarning = 10 * time.Second
+
+// How long a claim on a turn's warning or timeout is kept. Only needs to outlive every timer that could still fire for that turn
+const turnTimerClaimExpiry = time.Hour
+
+func turnTimerClaimKey(kind string, gameId string, deadline time.Time) string {
+	return "turn" + kind + ":" + gameId + ":" + strconv.FormatInt(deadline.UnixMilli(), 10)
+}
+
+// Returns how long before a turn's deadline its player should be warned under [rules]. See GameRules.TurnWarning
+func TurnWarningLead(rules Game.GameRules) time.Duration {
+	lead := defaultTurnWarning
+	if rules.TurnWarning > 0 {
+		lead = time.Duration(rules.TurnWarning) * time.Second
+	}
+	return min(lead, time.Duration(rules.TurnTimeLimit)*time.Second/2)
+}
+
+// Claims the warning for the turn due to end at [deadline] in the lobby with [roomCode]. Returns the Ids of the players to warn: whoever's turn it is,
+// or in a game played in SimultaneousRounds, everyone who hasn't committed yet. Nobody is returned if that turn is already over, it belongs to a bot,
+// or someone else (possibly on another server) already claimed it
+func ClaimTurnWarning(roomCode string, deadline time.Time) ([]string, error) {
+	funcLogPrefix := "==ClaimTurnWarning=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	gameState, err := loadTimedTurn(roomCode, deadline)
+	if err != nil || gameState == nil {
+		return []string{}, err
+	}
+
+	warned := []string{}
+	waiting := []string{gameState.CurrentPlayer}
+	if gameState.Rules.SimultaneousRounds {
+		progress, err := GetRoundProgress(gameState.Id)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+			return warned, err
+		}
+		waiting = progress.Waiting
+	}
+	for _, playerId := range waiting {
+		if seat := findSeat(*gameState, playerId); seat != nil && seat.SeatStatus == "" {
+			warned = append(warned, playerId)
+		}
+	}
+	if len(warned) == 0 {
+		return warned, nil
+	}
+
+	claimed, err := RDB.SetNX(ctx, turnTimerClaimKey("Warning", gameState.Id, deadline), gameState.CurrentPlayer, turnTimerClaimExpiry).Result()
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return []string{}, err
+	}
+	if !claimed {
+		return []string{}, nil
+	}
+	return warned, nil
+}
+
+// Ends the turn due to end at [deadline] in the lobby with [roomCode], if it still hasn't, according to the game's TurnTimeoutAction. In a game played
+// in SimultaneousRounds, the deadline is for the whole round instead, and everyone who hasn't committed yet has an action committed for them. Safe to
+// call more than once (and from more than one server) for the same deadline, as only the first call does anything. Returns the Changelog of every
+// action taken for the players who ran out of time, in order
+func TimeOutTurn(roomCode string, deadline time.Time) ([]Session.Changelog, error) {
+	funcLogPrefix := "==TimeOutTurn=="
+	defer LogUtil.EnsureLogPrefixIsReset()
+	LogUtil.SetLogPrefix(ModuleLogPrefix, PackageLogPrefix)
+
+	changelogs := []Session.Changelog{}
+
+	gameState, err := loadTimedTurn(roomCode, deadline)
+	if err != nil || gameState == nil {
+		return changelogs, err
+	}
+	if time.Now().Before(deadline) {
+		return changelogs, nil
+	}
+
+	seat := findSeat(*gameState, gameState.CurrentPlayer)
+	if seat == nil {
+		return changelogs, nil
+	}
+
+	claimed, err := RDB.SetNX(ctx, turnTimerClaimKey("Timeout", gameState.Id, deadline), seat.Id, turnTimerClaimExpiry).Result()
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return changelogs, err
+	}
+	if !claimed {
+		return changelogs, nil
+	}
+
+	if gameState.Rules.SimultaneousRounds {
+		changelogs, err = timeOutRound(*gameState)
+		if err != nil {
+			LogError(funcLogPrefix, err)
+		}
+		return changelogs, err
+	}
+
+	log.Printf("%s Player {%s} ran out of time in game {%s}", funcLogPrefix, seat.Id, gameState.Id)
+
+	strategy := gameState.Rules.TurnTimeoutAction
+	if _, exists := botRegistry[strategy]; exists {
+		stand := *seat
+		stand.Bot = strategy
+		changelogs, err = playBotTurn(gameState.Id, stand)
+	} else {
+		var changelog Session.Changelog
+		changelog, err = SubmitAction(gameState.Id, endTurnAction(seat.Id))
+		if err == nil {
+			changelogs = append(changelogs, changelog)
+		}
+	}
+	if err != nil {
+		LogError(funcLogPrefix, err)
+	}
+
+	if len(changelogs) > 0 {
+		changelogs[0].MostRecentAction = fmt.Sprintf("Player '%s' ran out of time. %s", seat.Name, changelogs[0].MostRecentAction)
+	}
+	return changelogs, err
+}
+
+// Commits an action for everyone in [gameState] who hasn't committed to one this round: a pass, or whatever a bot playing the game's
+// TurnTimeoutAction would choose. Returns the round's Changelog once that completes it
+func timeOutRound(gameState Session.GameState) ([]Session.Changelog, error) {
+	funcLogPrefix := "==timeOutRound=="
+
+	changelogs := []Session.Changelog{}
+	progress, err := GetRoundProgress(gameState.Id)
+	if err != nil {
+		return changelogs, err
+	}
+
+	names := []string{}
+	for _, playerId := range progress.Waiting {
+		seat := findSeat(gameState, playerId)
+		if seat == nil {
+			continue
+		}
+		log.Printf("%s Player {%s} ran out of time in game {%s}", funcLogPrefix, seat.Id, gameState.Id)
+		names = append(names, "'"+seat.Name+"'")
+
+		var revealed *Session.Changelog
+		if bot, exists := botRegistry[gameState.Rules.TurnTimeoutAction]; exists {
+			revealed, err = commitFor(gameState, *seat, bot)
+		} else {
+			_, revealed, err = CommitAction(gameState.Id, endTurnAction(seat.Id))
+		}
+		if err != nil {
+			return changelogs, err
+		}
+		if revealed != nil {
+			revealed.MostRecentAction = fmt.Sprintf("Ran out of time: %s. %s", strings.Join(names, ", "), revealed.MostRecentAction)
+			return append(changelogs, *revealed), nil
+		}
+	}
+	return changelogs, nil
+}
+
+// Loads the game being played in the lobby with [roomCode], or nil if it has ended, or its current turn isn't the one due to end at [deadline]
+func loadTimedTurn(roomCode string, deadline time.Time) (*Session.GameState, error) {
+	funcLogPrefix := "==loadTimedTurn=="
+
+	lobby, err := LoadLobbyFromRedis(roomCode)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return nil, err
+	}
+	if lobby.Status != Session.LobbyStatus_InProgress {
+		return nil, nil
+	}
+
+	gameState, err := GetCachedGameStateFromRedis(lobby.GameStateId)
+	if err != nil {
+		LogError(funcLogPrefix, err)
+		return nil, err
+	}
+	if gameState.TurnDeadline == nil || !gameState.TurnDeadline.Equal(deadline) {
+		return nil, nil
+	}
+	return &gameState, nil
+}
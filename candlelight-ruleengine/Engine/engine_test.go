@@ -2,9 +2,17 @@ package Engine
 
 import (
 	"candlelight-models/Game"
+	"candlelight-models/Pieces"
 	"candlelight-models/Player"
 	"candlelight-models/Session"
+	"candlelight-models/Sparks"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 const DUMMY_ID = "dummy"
@@ -142,7 +150,7 @@ func TestCreateRoom(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			roomCode, err := CreateRoom(tt.gameDefId)
+			roomCode, err := CreateRoom(tt.gameDefId, false)
 
 			if (err != nil) != tt.shouldReturnError {
 				t.Errorf("%s -- Error value: Expected {%t}, Got {%s}", tt.name, tt.shouldReturnError, err)
@@ -188,6 +196,12 @@ func TestJoinRoom(t *testing.T) {
 			playerName:        "ryan",
 			shouldReturnError: true,
 		},
+		{
+			name:              "Name Taken Regardless Of Case",
+			lobbyRoomCode:     DUMMY_ID,
+			playerName:        "Ryan",
+			shouldReturnError: true,
+		},
 	}
 
 	//Test Setup
@@ -195,7 +209,7 @@ func TestJoinRoom(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			lobby, playerId, err := JoinRoom(tt.lobbyRoomCode, tt.playerName)
+			lobby, playerId, err := JoinRoom(tt.lobbyRoomCode, tt.playerName, "")
 
 			//Error should be received IFF shouldReturnError == true
 			if (err != nil) != tt.shouldReturnError {
@@ -231,45 +245,1598 @@ func TestJoinRoom(t *testing.T) {
 	}
 }
 
-// ==================HELPER FUNCTIONS=============================
-func playerExistsInLobby(lobby Session.Lobby, playerName string, playerId string) bool {
-	for _, player := range lobby.Players {
-		if playerId != "" {
-			if player.Id == playerId && player.Name == playerName {
-				return true
+func TestApplySparks(t *testing.T) {
+	var tests = []struct {
+		name                string
+		sparks              Sparks.Sparks
+		expectedHandSize    int
+		expectedPlacedCards int
+		expectedDeckSize    int
+		shouldReturnError   bool
+	}{
+		{
+			name: "Dealer Then Flipper",
+			sparks: Sparks.Sparks{
+				Sparks.NewSpark(Sparks.SparkType_Dealer, Sparks.Dealer{NumToDeal: 2, DeckToUse: "deck"}),
+				Sparks.NewSpark(Sparks.SparkType_Flipper, Sparks.Flipper{NumToFlip: 1, DeckToUse: "deck", CardPlaceToUse: "place"}),
+			},
+			expectedHandSize:    2,
+			expectedPlacedCards: 1,
+			expectedDeckSize:    5,
+		},
+		{
+			name: "Unknown Spark",
+			sparks: Sparks.Sparks{
+				{Type: "Juggler"},
+			},
+			shouldReturnError: true,
+		},
+		{
+			name: "Registered Spark Is Applied",
+			sparks: Sparks.Sparks{
+				{Type: "Emptier"},
+			},
+			expectedHandSize:    0,
+			expectedPlacedCards: 0,
+			expectedDeckSize:    0,
+		},
+		{
+			name: "Shuffler With Missing Deck",
+			sparks: Sparks.Sparks{
+				Sparks.NewSpark(Sparks.SparkType_Shuffler, Sparks.Shuffler{DecksToShuffle: []string{"deck", "invalid"}}),
+			},
+			shouldReturnError: true,
+		},
+	}
+
+	RegisterSpark("Emptier", func(gameState *Session.GameState, setup SparkContext, config json.RawMessage) error {
+		gameState.Views[0].Pieces.Decks[0].Cards = []Pieces.Card{}
+		return nil
+	})
+	defer delete(sparkRegistry, "Emptier")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameState := dummyGameState(2, 10)
+
+			err := applySparks(&gameState, SparkContext{}, tt.sparks)
+
+			if (err != nil) != tt.shouldReturnError {
+				t.Fatalf("%s -- Error value: Expected {%t}, Got {%s}", tt.name, tt.shouldReturnError, err)
 			}
-		} else {
-			if player.Name == playerName {
-				return true
+			if tt.shouldReturnError {
+				return
+			}
+
+			for _, player := range gameState.Players {
+				if len(player.Hand[0].Pieces.Orphans) != tt.expectedHandSize {
+					t.Errorf("%s -- Hand size mismatch! Expected {%d}, Got {%d}", tt.name, tt.expectedHandSize, len(player.Hand[0].Pieces.Orphans))
+				}
+			}
+
+			placed := gameState.Views[0].Pieces.CardPlaces[0].Cards
+			if len(placed) != tt.expectedPlacedCards {
+				t.Errorf("%s -- CardPlace size mismatch! Expected {%d}, Got {%d}", tt.name, tt.expectedPlacedCards, len(placed))
+			}
+
+			remaining := gameState.Views[0].Pieces.Decks[0].Cards
+			if len(remaining) != tt.expectedDeckSize {
+				t.Errorf("%s -- Deck size mismatch! Expected {%d}, Got {%d}", tt.name, tt.expectedDeckSize, len(remaining))
+			}
+		})
+	}
+}
+
+func TestApplyStartingPlayer(t *testing.T) {
+	var tests = []struct {
+		name              string
+		mode              string
+		chosen            string
+		expectedPlayer    string
+		shouldReturnError bool
+	}{
+		{name: "First", mode: Sparks.StartingPlayerMode_First, expectedPlayer: "player1"},
+		{name: "Host", mode: Sparks.StartingPlayerMode_Host, expectedPlayer: "player2"},
+		{name: "Chosen", mode: Sparks.StartingPlayerMode_Chosen, chosen: "player3", expectedPlayer: "player3"},
+		{name: "Chosen, But Nobody Was", mode: Sparks.StartingPlayerMode_Chosen, shouldReturnError: true},
+		{name: "Chosen Player Not In Game", mode: Sparks.StartingPlayerMode_Chosen, chosen: "invalid", shouldReturnError: true},
+		{name: "Unknown Mode", mode: "Youngest", shouldReturnError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameState := dummyGameState(3, 0)
+			setup := SparkContext{
+				Lobby:   Session.Lobby{Host: gameState.Players[1]},
+				Options: StartOptions{StartingPlayer: tt.chosen},
+			}
+
+			err := applyStartingPlayer(&gameState, setup, Sparks.StartingPlayer{Mode: tt.mode})
+
+			if (err != nil) != tt.shouldReturnError {
+				t.Fatalf("%s -- Error value: Expected {%t}, Got {%s}", tt.name, tt.shouldReturnError, err)
+			}
+			if !tt.shouldReturnError && gameState.CurrentPlayer != tt.expectedPlayer {
+				t.Errorf("%s -- Starting player mismatch! Expected {%s}, Got {%s}", tt.name, tt.expectedPlayer, gameState.CurrentPlayer)
+			}
+		})
+	}
+}
+
+func TestApplyDeckBuilder(t *testing.T) {
+	gameState := dummyGameState(3, 5)
+
+	err := applyDeckBuilder(&gameState, SparkContext{}, Sparks.DeckBuilder{TemplateDeck: "deck"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gameState.Views[0].Pieces.Decks) != 0 {
+		t.Errorf("Template deck should have been removed from the table")
+	}
+
+	seenIds := map[string]bool{}
+	for _, player := range gameState.Players {
+		decks := player.Hand[0].Pieces.Decks
+		if len(decks) != 1 {
+			t.Fatalf("Player {%s} should have 1 deck, Got %d", player.Id, len(decks))
+		}
+		if len(decks[0].Cards) != 5 {
+			t.Errorf("Player {%s}'s deck should have 5 cards, Got %d", player.Id, len(decks[0].Cards))
+		}
+		for _, card := range decks[0].Cards {
+			if seenIds[card.Id] {
+				t.Errorf("Card Id {%s} is shared between copies!", card.Id)
+			}
+			seenIds[card.Id] = true
+			if card.ParentView != player.Hand[0].Id {
+				t.Errorf("Card's ParentView mismatch! Expected {%s}, Got {%s}", player.Hand[0].Id, card.ParentView)
 			}
 		}
 	}
-	return false
+
+	//A missing target View should fail before anyone is given a deck
+	gameState = dummyGameState(2, 5)
+	err = applyDeckBuilder(&gameState, SparkContext{}, Sparks.DeckBuilder{TemplateDeck: "deck", ToViews: []string{"hand2"}})
+	if err == nil {
+		t.Fatal("Expected an error for a View only one player has")
+	}
+	if len(gameState.Players[1].Hand[0].Pieces.Decks) != 0 {
+		t.Errorf("DeckBuilder changed the GameState before failing")
+	}
 }
 
-func playerIsHost(lobby Session.Lobby, playerName string, playerId string) bool {
-	return lobby.Host.Name == playerName && lobby.Host.Id == playerId
+func TestApplyDealer(t *testing.T) {
+	var tests = []struct {
+		name               string
+		dealer             Sparks.Dealer
+		numCards           int
+		discardedCards     int
+		removeHand         bool
+		expectedHandSizes  []int
+		expectedDeckLength int
+		shouldReturnError  bool
+	}{
+		{
+			name:               "Enough Cards",
+			dealer:             Sparks.Dealer{NumToDeal: 3, DeckToUse: "deck"},
+			numCards:           10,
+			expectedHandSizes:  []int{3, 3, 3},
+			expectedDeckLength: 1,
+		},
+		{
+			name:               "Short Deck Stops",
+			dealer:             Sparks.Dealer{NumToDeal: 3, DeckToUse: "deck", WhenEmpty: Sparks.WhenEmpty_Stop},
+			numCards:           7,
+			expectedHandSizes:  []int{3, 2, 2},
+			expectedDeckLength: 0,
+		},
+		{
+			name:              "Short Deck Fails",
+			dealer:            Sparks.Dealer{NumToDeal: 3, DeckToUse: "deck", WhenEmpty: Sparks.WhenEmpty_Fail},
+			numCards:          7,
+			shouldReturnError: true,
+		},
+		{
+			name:              "Short Deck Fails By Default",
+			dealer:            Sparks.Dealer{NumToDeal: 3, DeckToUse: "deck"},
+			numCards:          8,
+			shouldReturnError: true,
+		},
+		{
+			name:               "Reshuffled Deck Still Short Stops",
+			dealer:             Sparks.Dealer{NumToDeal: 3, DeckToUse: "deck", WhenEmpty: Sparks.WhenEmpty_Reshuffle, DiscardPile: "place"},
+			numCards:           7,
+			discardedCards:     1,
+			expectedHandSizes:  []int{3, 3, 2},
+			expectedDeckLength: 0,
+		},
+		{
+			name:               "Short Deck Deals Evenly",
+			dealer:             Sparks.Dealer{NumToDeal: 3, DeckToUse: "deck", WhenEmpty: Sparks.WhenEmpty_DealEvenly},
+			numCards:           7,
+			expectedHandSizes:  []int{2, 2, 2},
+			expectedDeckLength: 1,
+		},
+		{
+			name:               "Short Deck Reshuffles Discard Pile",
+			dealer:             Sparks.Dealer{NumToDeal: 3, DeckToUse: "deck", WhenEmpty: Sparks.WhenEmpty_Reshuffle, DiscardPile: "place"},
+			numCards:           7,
+			discardedCards:     4,
+			expectedHandSizes:  []int{3, 3, 3},
+			expectedDeckLength: 2,
+		},
+		{
+			name:              "Reshuffle Without Discard Pile",
+			dealer:            Sparks.Dealer{NumToDeal: 3, DeckToUse: "deck", WhenEmpty: Sparks.WhenEmpty_Reshuffle, DiscardPile: "invalid"},
+			numCards:          7,
+			shouldReturnError: true,
+		},
+		{
+			name:              "Unknown Policy",
+			dealer:            Sparks.Dealer{NumToDeal: 3, DeckToUse: "deck", WhenEmpty: "Panic"},
+			numCards:          7,
+			shouldReturnError: true,
+		},
+		{
+			name:              "Missing Deck",
+			dealer:            Sparks.Dealer{NumToDeal: 3, DeckToUse: "invalid"},
+			numCards:          7,
+			shouldReturnError: true,
+		},
+		{
+			name:              "Player Without A Hand",
+			dealer:            Sparks.Dealer{NumToDeal: 3, DeckToUse: "deck"},
+			numCards:          10,
+			removeHand:        true,
+			shouldReturnError: true,
+		},
+		{
+			name:               "Specific Target View",
+			dealer:             Sparks.Dealer{NumToDeal: 1, DeckToUse: "deck", ToViews: []string{"hand1", "hand2", "hand3"}},
+			numCards:           10,
+			expectedHandSizes:  []int{1, 1, 1},
+			expectedDeckLength: 7,
+		},
+		{
+			name:              "Target View Missing For One Player",
+			dealer:            Sparks.Dealer{NumToDeal: 1, DeckToUse: "deck", ToViews: []string{"hand1", "hand2"}},
+			numCards:          10,
+			shouldReturnError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gameState := dummyGameState(3, tt.numCards)
+			for x := range tt.discardedCards {
+				gameState.Views[0].Pieces.CardPlaces[0].Cards = append(gameState.Views[0].Pieces.CardPlaces[0].Cards, Pieces.Card{GamePiece: Pieces.GamePiece{Id: fmt.Sprintf("discarded%d", x)}})
+			}
+			if tt.removeHand {
+				gameState.Players[2].Hand = []Game.View{}
+			}
+
+			err := applyDealer(&gameState, SparkContext{}, tt.dealer)
+
+			if (err != nil) != tt.shouldReturnError {
+				t.Fatalf("%s -- Error value: Expected {%t}, Got {%s}", tt.name, tt.shouldReturnError, err)
+			}
+			if tt.shouldReturnError {
+				return
+			}
+
+			for index, player := range gameState.Players {
+				if len(player.Hand[0].Pieces.Orphans) != tt.expectedHandSizes[index] {
+					t.Errorf("%s -- Hand size mismatch for player #%d! Expected {%d}, Got {%d}", tt.name, index+1, tt.expectedHandSizes[index], len(player.Hand[0].Pieces.Orphans))
+				}
+			}
+
+			if deckLength := len(gameState.Views[0].Pieces.Decks[0].Cards); deckLength != tt.expectedDeckLength {
+				t.Errorf("%s -- Deck size mismatch! Expected {%d}, Got {%d}", tt.name, tt.expectedDeckLength, deckLength)
+			}
+		})
+	}
+
+	//Running short surfaces to the host as a failed spark
+	gameState := dummyGameState(3, 7)
+	err := applySparks(&gameState, SparkContext{}, Sparks.Sparks{Sparks.NewSpark(Sparks.SparkType_Dealer, Sparks.Dealer{NumToDeal: 3, DeckToUse: "deck"})})
+	if Session.AsGameError(err) == nil || Session.AsGameError(err).Code != Session.ErrorCode_SparkFailed {
+		t.Errorf("Expected a short deck to fail with SparkFailed, Got %v", err)
+	}
 }
 
-func saveDummyGameDef() {
-	SaveGameDefToDB(Game.Game{
-		Id:         DUMMY_ID,
-		Name:       "dummy",
-		MaxPlayers: 4,
-		Genre:      "test",
-	})
+func TestApplyFlipper_ShortDeck(t *testing.T) {
+	gameState := dummyGameState(2, 2)
+
+	err := applyFlipper(&gameState, SparkContext{}, Sparks.Flipper{NumToFlip: 5, DeckToUse: "deck", CardPlaceToUse: "place"})
+	if err != nil {
+		t.Fatalf("Flipper should stop quietly when the deck runs out, Got error {%s}", err)
+	}
+
+	if placed := len(gameState.Views[0].Pieces.CardPlaces[0].Cards); placed != 2 {
+		t.Errorf("Expected 2 cards to be flipped, Got %d", placed)
+	}
+
+	gameState = dummyGameState(2, 2)
+	if err := applyFlipper(&gameState, SparkContext{}, Sparks.Flipper{NumToFlip: 5, DeckToUse: "deck", CardPlaceToUse: "place", WhenEmpty: Sparks.WhenEmpty_Fail}); err == nil {
+		t.Errorf("Expected Flipper to fail when the deck runs out under {%s}", Sparks.WhenEmpty_Fail)
+	}
 }
 
-func saveDummyLobby() {
-	//Make sure the dummy game exists since we use it as the game def id
+func TestSeatPlayers(t *testing.T) {
+	players := []Player.Player{{Id: "a"}, {Id: "b"}, {Id: "c"}, {Id: "d"}}
+
+	var tests = []struct {
+		name          string
+		seatingOrder  string
+		arrangement   []string
+		expectedOrder []string
+	}{
+		{name: "Default", seatingOrder: "", expectedOrder: []string{"a", "b", "c", "d"}},
+		{name: "Join Order", seatingOrder: Session.SeatingOrder_JoinOrder, expectedOrder: []string{"a", "b", "c", "d"}},
+		{name: "Host Arranged", seatingOrder: Session.SeatingOrder_HostArranged, arrangement: []string{"c", "a", "d", "b"}, expectedOrder: []string{"c", "a", "d", "b"}},
+		{name: "Host Arranged, Partial", seatingOrder: Session.SeatingOrder_HostArranged, arrangement: []string{"d", "b"}, expectedOrder: []string{"d", "b", "a", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seated := seatPlayers(Session.Lobby{Players: players, SeatingOrder: tt.seatingOrder, SeatArrangement: tt.arrangement})
+
+			for index, player := range seated {
+				if player.Id != tt.expectedOrder[index] {
+					t.Errorf("%s -- Seat #%d mismatch! Expected {%s}, Got {%s}", tt.name, index+1, tt.expectedOrder[index], player.Id)
+				}
+			}
+		})
+	}
+
+	//Random seating should still seat everyone exactly once
+	seated := seatPlayers(Session.Lobby{Players: players, SeatingOrder: Session.SeatingOrder_Random})
+	for _, player := range players {
+		if !slices.ContainsFunc(seated, func(p Player.Player) bool { return p.Id == player.Id }) {
+			t.Errorf("Random seating left out player {%s}", player.Id)
+		}
+	}
+}
+
+func TestHandForSeat(t *testing.T) {
+	gameDef := Game.Game{
+		Views: []Game.View{
+			{Id: "table", OwnerPlayerNumber: 0},
+			{Id: "fixed1", OwnerPlayerNumber: 1},
+			{
+				Id:        "handTemplate",
+				PerPlayer: true,
+				Pieces: Pieces.PieceSet{
+					Decks:   []Pieces.Deck{{GamePiece: Pieces.GamePiece{Id: "templateDeck", ParentView: "handTemplate"}, Cards: []Pieces.Card{{GamePiece: Pieces.GamePiece{Id: "templateCard"}}}}},
+					Orphans: []Pieces.Card{{GamePiece: Pieces.GamePiece{Id: "templateOrphan"}}},
+				},
+			},
+		},
+	}
+
+	if slices.ContainsFunc(gameDef.ViewsForPlayer(0), func(v Game.View) bool { return v.PerPlayer }) {
+		t.Errorf("Templates should not be treated as public Views")
+	}
+
+	firstHand := handForSeat(gameDef, 1)
+	if len(firstHand) != 2 || firstHand[0].Id != "fixed1" {
+		t.Fatalf("Seat 1 should get its fixed View followed by a template copy, Got %d Views", len(firstHand))
+	}
+
+	//Seats beyond any fixed Views should still get a copy of every template
+	for _, seat := range []int{2, 7} {
+		hand := handForSeat(gameDef, seat)
+		if len(hand) != 1 {
+			t.Fatalf("Seat %d should get exactly one View, Got %d", seat, len(hand))
+		}
+
+		copied := hand[0]
+		if copied.Id == "handTemplate" || copied.TemplateId != "handTemplate" || copied.PerPlayer || copied.OwnerPlayerNumber != seat {
+			t.Errorf("Seat %d's copy was not set up correctly: %+v", seat, copied)
+		}
+		if copied.Pieces.Decks[0].Id == "templateDeck" || copied.Pieces.Decks[0].ParentView != copied.Id {
+			t.Errorf("Seat %d's copied Deck kept its template Id or ParentView", seat)
+		}
+		if copied.Pieces.Decks[0].Cards[0].Id == "templateCard" || copied.Pieces.Orphans[0].Id == "templateOrphan" {
+			t.Errorf("Seat %d's copied Cards kept their template Ids", seat)
+		}
+		if copied.Id == firstHand[1].Id {
+			t.Errorf("Seats 1 and %d share a View Id", seat)
+		}
+	}
+
+	//Sparks should be able to find each player's copy by the template's Id
+	player := Player.Player{Name: "templated", Hand: handForSeat(gameDef, 2)}
+	if _, err := findHandView(&player, []string{"handTemplate"}); err != nil {
+		t.Errorf("Could not find copied View by template Id: %s", err)
+	}
+}
+
+func TestGetInitialGameState_StartRequirements(t *testing.T) {
+	host := Player.Player{Id: "host", Name: "host"}
+	guest := Player.Player{Id: "guest", Name: "guest"}
+
+	var tests = []struct {
+		name              string
+		players           []Player.Player
+		minPlayers        int
+		ready             map[string]bool
+		startingPlayerId  string
+		shouldReturnError bool
+	}{
+		{
+			name:              "Not The Host",
+			players:           []Player.Player{host, guest},
+			ready:             map[string]bool{"host": true, "guest": true},
+			startingPlayerId:  "guest",
+			shouldReturnError: true,
+		},
+		{
+			name:              "Too Few Players",
+			players:           []Player.Player{host},
+			minPlayers:        2,
+			ready:             map[string]bool{"host": true},
+			startingPlayerId:  "host",
+			shouldReturnError: true,
+		},
+		{
+			name:              "Not Everyone Ready",
+			players:           []Player.Player{host, guest},
+			ready:             map[string]bool{"host": true},
+			startingPlayerId:  "host",
+			shouldReturnError: true,
+		},
+		{
+			name:             "Everyone Ready",
+			players:          []Player.Player{host, guest},
+			minPlayers:       2,
+			ready:            map[string]bool{"host": true, "guest": true},
+			startingPlayerId: "host",
+		},
+	}
+
+	saveDummyGameDef()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SaveLobbyInRedis(Session.Lobby{
+				RoomCode:         DUMMY_ID,
+				GameDefinitionId: DUMMY_ID,
+				Status:           Session.LobbyStatus_AwaitingStart,
+				NumPlayers:       len(tt.players),
+				MaxPlayers:       4,
+				MinPlayers:       tt.minPlayers,
+				Players:          tt.players,
+				Host:             host,
+				Ready:            tt.ready,
+			})
+
+			gameState, err := GetInitialGameState(DUMMY_ID, tt.startingPlayerId, StartOptions{})
+			if gameState.Id != "" {
+				defer RDB.Del(RDB.Context(), "gameState:"+gameState.Id)
+			}
+
+			if (err != nil) != tt.shouldReturnError {
+				t.Fatalf("%s -- Error value: Expected {%t}, Got {%s}", tt.name, tt.shouldReturnError, err)
+			}
+
+			lobby, _ := LoadLobbyFromRedis(DUMMY_ID)
+			if tt.shouldReturnError && lobby.Status != Session.LobbyStatus_AwaitingStart {
+				t.Errorf("%s -- Lobby was marked {%s} even though the game didn't start", tt.name, lobby.Status)
+			}
+			if !tt.shouldReturnError && len(gameState.Players) != len(tt.players) {
+				t.Errorf("%s -- Expected %d players in the GameState, Got %d", tt.name, len(tt.players), len(gameState.Players))
+			}
+		})
+	}
+}
+
+func TestGetInitialGameState_EndedLobby(t *testing.T) {
 	saveDummyGameDef()
+	roomCode := DUMMY_ID + "ended"
+	host := Player.Player{Id: "host", Name: "host"}
+	oldGame, _ := CacheGameStateInRedis(Session.GameState{})
+	defer RDB.Del(RDB.Context(), "gameState:"+oldGame.Id)
 	SaveLobbyInRedis(Session.Lobby{
-		RoomCode:         DUMMY_ID,
+		RoomCode:         roomCode,
 		GameDefinitionId: DUMMY_ID,
-		Status:           Session.LobbyStatus_AwaitingStart,
-		NumPlayers:       0,
+		GameStateId:      oldGame.Id,
+		Status:           Session.LobbyStatus_Ended,
+		Players:          []Player.Player{host},
+		NumPlayers:       1,
 		MaxPlayers:       4,
-		Players:          []Player.Player{},
-		Host:             Player.Player{},
+		Host:             host,
+		Ready:            map[string]bool{"host": true},
 	})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	gameState, err := GetInitialGameState(roomCode, "host", StartOptions{})
+	if gameState.Id != "" {
+		defer RDB.Del(RDB.Context(), "gameState:"+gameState.Id)
+	}
+	if Session.AsGameError(err) == nil || Session.AsGameError(err).Code != Session.ErrorCode_GameEnded {
+		t.Fatalf("Expected starting an ended lobby to be refused, Got %v", err)
+	}
+	if lobby, _ := LoadLobbyFromRedis(roomCode); lobby.Status != Session.LobbyStatus_Ended || lobby.GameStateId != oldGame.Id {
+		t.Errorf("Expected the ended lobby to be left alone, Got status {%s} and game {%s}", lobby.Status, lobby.GameStateId)
+	}
+}
+
+func TestGetRoomMessagesSince(t *testing.T) {
+	roomCode := DUMMY_ID + "messages"
+	defer RDB.Del(RDB.Context(), roomSeqKey(roomCode), roomMessagesKey(roomCode))
+
+	//Send more messages than can be buffered, so the oldest ones fall out
+	total := RoomMessageBufferSize + 5
+	for i := 1; i <= total; i++ {
+		seq, err := PublishRoomMessage(roomCode, []byte(`{"type":"Test"}`), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seq != int64(i) {
+			t.Fatalf("Expected message to be given sequence number %d, got %d", i, seq)
+		}
+	}
+
+	var tests = []struct {
+		name             string
+		lastSeq          int64
+		expectedCount    int
+		expectedComplete bool
+	}{
+		{
+			name:             "Up To Date",
+			lastSeq:          int64(total),
+			expectedCount:    0,
+			expectedComplete: true,
+		},
+		{
+			name:             "Missed A Few",
+			lastSeq:          int64(total - 3),
+			expectedCount:    3,
+			expectedComplete: true,
+		},
+		{
+			name:             "Missed Exactly The Buffer",
+			lastSeq:          int64(total - RoomMessageBufferSize),
+			expectedCount:    RoomMessageBufferSize,
+			expectedComplete: true,
+		},
+		{
+			name:             "Missed Too Many",
+			lastSeq:          1,
+			expectedCount:    RoomMessageBufferSize,
+			expectedComplete: false,
+		},
+		{
+			name:             "Sequence From The Future",
+			lastSeq:          int64(total + 10),
+			expectedCount:    0,
+			expectedComplete: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, complete, err := GetRoomMessagesSince(roomCode, tt.lastSeq)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if complete != tt.expectedComplete {
+				t.Errorf("Complete mismatch! Expected {%t}, Got {%t}", tt.expectedComplete, complete)
+			}
+			if len(messages) != tt.expectedCount {
+				t.Fatalf("Expected %d messages, Got %d", tt.expectedCount, len(messages))
+			}
+			for index, message := range messages {
+				if index > 0 && message.Seq != messages[index-1].Seq+1 {
+					t.Errorf("Messages are out of order! {%d} came after {%d}", message.Seq, messages[index-1].Seq)
+				}
+				if string(message.Message) != `{"type":"Test"}` {
+					t.Errorf("Message was not stored as-is. Got %s", message.Message)
+				}
+			}
+		})
+	}
+}
+
+func TestSendChatMessage(t *testing.T) {
+	roomCode := DUMMY_ID + "chat"
+	host := Player.Player{Id: "host", Name: "host"}
+	guest := Player.Player{Id: "guest", Name: "guest"}
+	muted := Player.Player{Id: "muted", Name: "muted"}
+	SaveLobbyInRedis(Session.Lobby{
+		RoomCode:   roomCode,
+		Status:     Session.LobbyStatus_AwaitingStart,
+		NumPlayers: 3,
+		MaxPlayers: 4,
+		Players:    []Player.Player{host, guest, muted},
+		Host:       host,
+		Muted:      map[string]bool{"muted": true},
+	})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode, roomChatKey(roomCode))
+
+	var tests = []struct {
+		name         string
+		from         string
+		to           string
+		message      string
+		expectedCode string
+	}{
+		{name: "Room-Wide", from: "host", message: "  hello everyone  "},
+		{name: "Private", from: "host", to: "guest", message: "psst"},
+		{name: "Empty", from: "guest", message: "   ", expectedCode: Session.ErrorCode_MalformedRequest},
+		{name: "Too Long", from: "guest", message: strings.Repeat("a", MaxChatMessageLength+1), expectedCode: Session.ErrorCode_MessageTooLong},
+		{name: "Muted", from: "muted", message: "let me talk", expectedCode: Session.ErrorCode_Muted},
+		{name: "Unknown Recipient", from: "guest", to: "nobody", message: "hi", expectedCode: Session.ErrorCode_PlayerNotFound},
+		{name: "To Themselves", from: "guest", to: "guest", message: "hi", expectedCode: Session.ErrorCode_PlayerNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := SendChatMessage(roomCode, tt.from, tt.to, tt.message)
+			if tt.expectedCode == "" {
+				if err != nil {
+					t.Fatalf("Expected message to be sent, Got %s", err)
+				}
+				if message.Message != strings.TrimSpace(tt.message) || message.From != tt.from || message.Id == "" {
+					t.Errorf("Message wasn't recorded correctly. Got %+v", message)
+				}
+				return
+			}
+			if code := Session.AsGameError(err); code == nil || code.Code != tt.expectedCode {
+				t.Errorf("Expected error code {%s}, Got {%v}", tt.expectedCode, err)
+			}
+		})
+	}
+
+	//Private messages should only show up in the history of the two players involved
+	for playerId, expected := range map[string]int{"host": 2, "guest": 2, "muted": 1} {
+		history, err := GetChatHistory(roomCode, playerId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != expected {
+			t.Errorf("Expected %s to see %d messages, Got %d", playerId, expected, len(history))
+		}
+	}
+
+	//Only the most recent messages are kept
+	for i := 0; i < ChatHistorySize+5; i++ {
+		SendChatMessage(roomCode, "guest", "", strconv.Itoa(i))
+	}
+	history, _ := GetChatHistory(roomCode, "guest")
+	if len(history) != ChatHistorySize || history[len(history)-1].Message != strconv.Itoa(ChatHistorySize+4) {
+		t.Errorf("Expected only the last %d messages to be kept, Got %d ending with {%s}", ChatHistorySize, len(history), history[len(history)-1].Message)
+	}
+}
+
+func TestSetPlayerMuted(t *testing.T) {
+	roomCode := DUMMY_ID + "mute"
+	host := Player.Player{Id: "host", Name: "host"}
+	guest := Player.Player{Id: "guest", Name: "guest"}
+	SaveLobbyInRedis(Session.Lobby{RoomCode: roomCode, Players: []Player.Player{host, guest}, NumPlayers: 2, Host: host})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	if _, err := SetPlayerMuted(roomCode, "guest", "host", true); err == nil {
+		t.Errorf("Expected a non-host to be unable to mute anyone")
+	}
+	if _, err := SetPlayerMuted(roomCode, "host", "host", true); err == nil {
+		t.Errorf("Expected the host to be unable to mute themselves")
+	}
+
+	lobby, err := SetPlayerMuted(roomCode, "host", "guest", true)
+	if err != nil || !lobby.Muted["guest"] {
+		t.Fatalf("Expected guest to be muted. Got %v (%s)", lobby.Muted, err)
+	}
+	lobby, err = SetPlayerMuted(roomCode, "host", "guest", false)
+	if err != nil || lobby.Muted["guest"] {
+		t.Fatalf("Expected guest to be unmuted. Got %v (%s)", lobby.Muted, err)
+	}
+}
+
+func TestSpectateRoom(t *testing.T) {
+	roomCode := DUMMY_ID + "spectate"
+	host := Player.Player{Id: "host", Name: "host"}
+	SaveLobbyInRedis(Session.Lobby{
+		RoomCode:        roomCode,
+		Status:          Session.LobbyStatus_InProgress,
+		NumPlayers:      1,
+		MaxPlayers:      1,
+		Players:         []Player.Player{host},
+		Host:            host,
+		AllowSpectators: true,
+	})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	//A full, in-progress lobby can still be watched
+	lobby, spectatorId, err := SpectateRoom(roomCode, "watcher", "")
+	if err != nil {
+		t.Fatalf("Expected to be able to spectate, Got %s", err)
+	}
+	if lobby.NumPlayers != 1 || len(lobby.Spectators) != 1 || lobby.Spectators[0].Id != spectatorId {
+		t.Errorf("Spectator wasn't added correctly. Got %d players and spectators %+v", lobby.NumPlayers, lobby.Spectators)
+	}
+
+	if _, _, err := SetSpectatorsAllowed(roomCode, "watcher", false); err == nil {
+		t.Errorf("Expected only the host to be able to turn off spectating")
+	}
+	lobby, removed, err := SetSpectatorsAllowed(roomCode, "host", false)
+	if err != nil || len(removed) != 1 || len(lobby.Spectators) != 0 {
+		t.Fatalf("Expected turning off spectating to remove the spectator. Got removed %+v, lobby spectators %+v (%v)", removed, lobby.Spectators, err)
+	}
+
+	if _, _, err := SpectateRoom(roomCode, "late", ""); Session.AsGameError(err) == nil || Session.AsGameError(err).Code != Session.ErrorCode_SpectatingDisabled {
+		t.Errorf("Expected spectating to be refused once turned off, Got %v", err)
+	}
+}
+
+func TestTransferHost(t *testing.T) {
+	roomCode := DUMMY_ID + "transfer"
+	host := Player.Player{Id: "host", Name: "host"}
+	guest := Player.Player{Id: "guest", Name: "guest"}
+	third := Player.Player{Id: "third", Name: "third"}
+	SaveLobbyInRedis(Session.Lobby{RoomCode: roomCode, Players: []Player.Player{host, guest, third}, NumPlayers: 3, Host: host})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	if _, err := TransferHost(roomCode, "guest", "guest"); Session.AsGameError(err).Code != Session.ErrorCode_NotHost {
+		t.Errorf("Expected a non-host to be unable to transfer host, Got %v", err)
+	}
+	if _, err := TransferHost(roomCode, "host", "nobody"); Session.AsGameError(err).Code != Session.ErrorCode_PlayerNotFound {
+		t.Errorf("Expected transferring to an unknown player to fail, Got %v", err)
+	}
+
+	lobby, err := TransferHost(roomCode, "host", "third")
+	if err != nil || lobby.Host.Id != "third" {
+		t.Fatalf("Expected third to be host. Got {%s} (%v)", lobby.Host.Id, err)
+	}
+
+	//The host leaving hands the lobby to whoever joined after them
+	lobby, _, err = LeaveRoom(roomCode, "third")
+	if err != nil || lobby.Host.Id != "host" {
+		t.Errorf("Expected host to be host again after third left. Got {%s} (%v)", lobby.Host.Id, err)
+	}
+}
+
+func TestMigrateHostIfDisconnected(t *testing.T) {
+	roomCode := DUMMY_ID + "migrate"
+	host := Player.Player{Id: "host", Name: "host"}
+	guest := Player.Player{Id: "guest", Name: "guest"}
+	third := Player.Player{Id: "third", Name: "third"}
+	SaveLobbyInRedis(Session.Lobby{RoomCode: roomCode, Players: []Player.Player{host, guest, third}, NumPlayers: 3, Host: host})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	//Nobody else is connected, so there's nobody to hand the lobby to
+	if _, changed, err := MigrateHostIfDisconnected(roomCode, "host"); err != nil || changed {
+		t.Errorf("Expected host not to change while nobody is connected. Got changed=%t (%v)", changed, err)
+	}
+
+	TrackConnection(roomCode, "third", "conn3", time.Minute)
+	defer UntrackConnection(roomCode, "third", "conn3")
+	TrackConnection(roomCode, "host", "conn1", time.Minute)
+	if _, changed, _ := MigrateHostIfDisconnected(roomCode, "host"); changed {
+		t.Errorf("Expected a connected host to stay host")
+	}
+	UntrackConnection(roomCode, "host", "conn1")
+
+	//guest is next in line, but only third is connected
+	lobby, changed, err := MigrateHostIfDisconnected(roomCode, "host")
+	if err != nil || !changed || lobby.Host.Id != "third" {
+		t.Errorf("Expected third to become host. Got {%s}, changed=%t (%v)", lobby.Host.Id, changed, err)
+	}
+}
+
+func TestRematch(t *testing.T) {
+	saveDummyGameDef()
+	SaveGameDefToDB(Game.Game{Id: DUMMY_ID + "duel", Name: "duel", MaxPlayers: 2, Rules: Game.GameRules{SpectatorVisibility: Game.SpectatorVisibility_Disabled}})
+	defer RDB.Del(RDB.Context(), "game:"+DUMMY_ID+"duel")
+
+	roomCode := DUMMY_ID + "rematch"
+	host := Player.Player{Id: "host", Name: "host"}
+	guest := Player.Player{Id: "guest", Name: "guest"}
+	oldGame, _ := CacheGameStateInRedis(Session.GameState{})
+	SaveLobbyInRedis(Session.Lobby{
+		RoomCode:         roomCode,
+		GameDefinitionId: DUMMY_ID,
+		GameStateId:      oldGame.Id,
+		Status:           Session.LobbyStatus_Ended,
+		Players:          []Player.Player{host, guest},
+		NumPlayers:       2,
+		MaxPlayers:       4,
+		Host:             host,
+		Ready:            map[string]bool{"host": true, "guest": true},
+		AllowSpectators:  true,
+		Spectators:       []Player.Player{{Id: "watcher", Name: "watcher"}},
+	})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	if _, _, err := Rematch(roomCode, "guest", ""); Session.AsGameError(err).Code != Session.ErrorCode_NotHost {
+		t.Errorf("Expected only the host to be able to start a rematch, Got %v", err)
+	}
+
+	lobby, removed, err := Rematch(roomCode, "host", DUMMY_ID+"duel")
+	if err != nil {
+		t.Fatalf("Expected rematch to succeed, Got %s", err)
+	}
+	if lobby.Status != Session.LobbyStatus_AwaitingStart || lobby.GameStateId != "" || len(lobby.Ready) != 0 {
+		t.Errorf("Lobby wasn't reset. Got status {%s}, gameStateId {%s}, ready %v", lobby.Status, lobby.GameStateId, lobby.Ready)
+	}
+	if lobby.GameDefinitionId != DUMMY_ID+"duel" || lobby.MaxPlayers != 2 || lobby.NumPlayers != 2 {
+		t.Errorf("Lobby didn't switch games. Got game {%s} with %d/%d players", lobby.GameDefinitionId, lobby.NumPlayers, lobby.MaxPlayers)
+	}
+	if len(removed) != 1 || len(lobby.Spectators) != 0 || lobby.AllowSpectators {
+		t.Errorf("Expected the spectator to be removed since the new game disables spectating. Got removed %+v, spectators %+v", removed, lobby.Spectators)
+	}
+	if _, err := GetCachedGameStateFromRedis(oldGame.Id); err == nil {
+		t.Errorf("Expected the old GameState to be thrown away")
+	}
+
+	//The dummy game allows 4 players, so 3 can't fit back into the duel
+	lobby.Players = append(lobby.Players, Player.Player{Id: "third", Name: "third"})
+	lobby.NumPlayers = 3
+	lobby.GameDefinitionId = DUMMY_ID
+	SaveLobbyInRedis(lobby)
+	if _, _, err := Rematch(roomCode, "host", DUMMY_ID+"duel"); Session.AsGameError(err).Code != Session.ErrorCode_LobbyFull {
+		t.Errorf("Expected switching to a game too small for the lobby to fail, Got %v", err)
+	}
+}
+
+func TestUpdateLobbySettings(t *testing.T) {
+	saveDummyGameDef()
+	SaveGameDefToDB(Game.Game{Id: DUMMY_ID + "solo", Name: "solo", MaxPlayers: 1})
+	defer RDB.Del(RDB.Context(), "game:"+DUMMY_ID+"solo")
+
+	roomCode := DUMMY_ID + "settings"
+	host := Player.Player{Id: "host", Name: "host"}
+	guest := Player.Player{Id: "guest", Name: "guest"}
+	SaveLobbyInRedis(Session.Lobby{
+		RoomCode:         roomCode,
+		GameDefinitionId: DUMMY_ID,
+		Status:           Session.LobbyStatus_AwaitingStart,
+		Players:          []Player.Player{host, guest},
+		NumPlayers:       2,
+		MaxPlayers:       4,
+		Host:             host,
+		SeatingOrder:     Session.SeatingOrder_JoinOrder,
+	})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode, "lobbyPassword:"+roomCode)
+
+	intPtr := func(i int) *int { return &i }
+	boolPtr := func(b bool) *bool { return &b }
+	stringPtr := func(s string) *string { return &s }
+
+	var tests = []struct {
+		name         string
+		playerId     string
+		settings     LobbySettings
+		expectedCode string
+	}{
+		{name: "Not Host", playerId: "guest", settings: LobbySettings{MaxPlayers: intPtr(3)}, expectedCode: Session.ErrorCode_NotHost},
+		{name: "Max Players Below Joined", playerId: "host", settings: LobbySettings{MaxPlayers: intPtr(1)}, expectedCode: Session.ErrorCode_InvalidSettings},
+		{name: "Max Players Above Game", playerId: "host", settings: LobbySettings{MaxPlayers: intPtr(5)}, expectedCode: Session.ErrorCode_InvalidSettings},
+		{name: "Game Too Small", playerId: "host", settings: LobbySettings{GameDefinitionId: stringPtr(DUMMY_ID + "solo")}, expectedCode: Session.ErrorCode_LobbyFull},
+		{name: "Unknown Seating Order", playerId: "host", settings: LobbySettings{SeatingOrder: stringPtr("Alphabetical")}, expectedCode: Session.ErrorCode_InvalidSeating},
+		//Nothing should be applied if any one setting is invalid
+		{name: "Partly Invalid", playerId: "host", settings: LobbySettings{EnforceTurnOrder: boolPtr(true), Password: stringPtr("hunter2"), MaxPlayers: intPtr(9)}, expectedCode: Session.ErrorCode_InvalidSettings},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := UpdateLobbySettings(roomCode, tt.playerId, tt.settings)
+			if gameError := Session.AsGameError(err); gameError == nil || gameError.Code != tt.expectedCode {
+				t.Errorf("Expected error code {%s}, Got %v", tt.expectedCode, err)
+			}
+		})
+	}
+
+	lobby, _ := LoadLobbyFromRedis(roomCode)
+	if lobby.MaxPlayers != 4 || lobby.RuleOverrides.EnforceTurnOrder != nil {
+		t.Fatalf("Invalid settings were applied. Got max players %d, overrides %+v", lobby.MaxPlayers, lobby.RuleOverrides)
+	}
+	if open, _ := CheckLobbyPassword(roomCode, ""); !open || lobby.HasPassword {
+		t.Fatalf("Expected the password from the invalid settings not to be set")
+	}
+
+	lobby, _, err := UpdateLobbySettings(roomCode, "host", LobbySettings{
+		MaxPlayers:       intPtr(3),
+		EnforceTurnOrder: boolPtr(true),
+		Password:         stringPtr("hunter2"),
+		SeatingOrder:     stringPtr(Session.SeatingOrder_HostArranged),
+		SeatArrangement:  []string{"guest", "host"},
+	})
+	if err != nil {
+		t.Fatalf("Expected settings to be updated, Got %s", err)
+	}
+	if lobby.MaxPlayers != 3 || !*lobby.RuleOverrides.EnforceTurnOrder || !lobby.HasPassword || !slices.Equal(lobby.SeatArrangement, []string{"guest", "host"}) {
+		t.Errorf("Settings weren't applied. Got %+v", lobby)
+	}
+
+	if ok, _ := CheckLobbyPassword(roomCode, "hunter2"); !ok {
+		t.Errorf("Expected the right password to be accepted")
+	}
+	if ok, _ := CheckLobbyPassword(roomCode, "hunter3"); ok {
+		t.Errorf("Expected the wrong password to be refused")
+	}
+
+	//The password should be kept for as long as the lobby is, however long ago it was set
+	RDB.Expire(RDB.Context(), "lobbyPassword:"+roomCode, time.Minute)
+	SaveLobbyInRedis(lobby)
+	if ttl := RDB.TTL(RDB.Context(), "lobbyPassword:"+roomCode).Val(); ttl <= time.Minute {
+		t.Errorf("Expected saving the lobby to keep its password around too, Got a TTL of %s", ttl)
+	}
+
+	//Clearing the password lets anyone in again
+	lobby, _, _ = UpdateLobbySettings(roomCode, "host", LobbySettings{Password: stringPtr("")})
+	if ok, _ := CheckLobbyPassword(roomCode, "anything"); !ok || lobby.HasPassword {
+		t.Errorf("Expected the password to be removed")
+	}
+}
+
+func TestGetPublicLobbies(t *testing.T) {
+	host := Player.Player{Id: "host", Name: "hostName"}
+	public := Session.Lobby{RoomCode: DUMMY_ID + "public", GameName: "dummy", Status: Session.LobbyStatus_AwaitingStart, Public: true, Players: []Player.Player{host}, NumPlayers: 1, MaxPlayers: 4, Host: host}
+	private := Session.Lobby{RoomCode: DUMMY_ID + "private", Status: Session.LobbyStatus_AwaitingStart}
+	SaveLobbyInRedis(public)
+	SaveLobbyInRedis(private)
+	defer RDB.Del(RDB.Context(), "lobby:"+public.RoomCode, "lobby:"+private.RoomCode)
+
+	isListed := func(roomCode string) bool {
+		t.Helper()
+		lobbies, err := GetPublicLobbies()
+		if err != nil {
+			t.Fatalf("Error getting public lobbies: %s", err)
+		}
+		return slices.ContainsFunc(lobbies, func(summary Session.LobbySummary) bool { return summary.RoomCode == roomCode })
+	}
+
+	lobbies, _ := GetPublicLobbies()
+	index := slices.IndexFunc(lobbies, func(summary Session.LobbySummary) bool { return summary.RoomCode == public.RoomCode })
+	if index < 0 || lobbies[index].HostName != "hostName" || lobbies[index].NumPlayers != 1 || lobbies[index].GameName != "dummy" {
+		t.Errorf("Expected the public lobby to be listed with its details. Got %+v", lobbies)
+	}
+	if isListed(private.RoomCode) {
+		t.Errorf("Expected the private lobby not to be listed")
+	}
+
+	//Starting the game takes it off the list
+	public.Status = Session.LobbyStatus_InProgress
+	SaveLobbyInRedis(public)
+	if isListed(public.RoomCode) {
+		t.Errorf("Expected a lobby in progress not to be listed")
+	}
+
+	//Lobbies that expire are dropped from the index
+	public.Status = Session.LobbyStatus_AwaitingStart
+	SaveLobbyInRedis(public)
+	RDB.Del(RDB.Context(), "lobby:"+public.RoomCode)
+	if isListed(public.RoomCode) || RDB.SIsMember(RDB.Context(), publicLobbiesKey, public.RoomCode).Val() {
+		t.Errorf("Expected an expired lobby to be dropped from the index")
+	}
+}
+
+func TestNewRoomCodeGenerator(t *testing.T) {
+	var tests = []struct {
+		name             string
+		config           RoomCodeConfig
+		expectedLength   int
+		expectedAlphabet string
+	}{
+		{name: "Default", config: roomCodeConfigFromEnv(), expectedLength: 4, expectedAlphabet: defaultRoomCodeAlphabet},
+		{name: "Custom", config: RoomCodeConfig{Length: 6, Alphabet: "abc"}, expectedLength: 6, expectedAlphabet: "ABC"},
+		{name: "Ambiguous Characters Dropped", config: RoomCodeConfig{Length: 5, Alphabet: "0O1IXY"}, expectedLength: 5, expectedAlphabet: "XY"},
+		{name: "Unusable Alphabet", config: RoomCodeConfig{Length: 4, Alphabet: "O0"}, expectedLength: 4, expectedAlphabet: defaultRoomCodeAlphabet},
+		//The only 3-letter codes from this alphabet that aren't blocked are the ones without "ASS" in them
+		{name: "Blocklist Avoided", config: RoomCodeConfig{Length: 3, Alphabet: "AS"}, expectedLength: 3, expectedAlphabet: "AS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generate := NewRoomCodeGenerator(tt.config)
+			for range 100 {
+				code, err := generate()
+				if err != nil {
+					t.Fatalf("Expected a room code, Got %s", err)
+				}
+				if len(code) != tt.expectedLength || strings.Trim(code, tt.expectedAlphabet) != "" {
+					t.Fatalf("Expected a %d-character code from {%s}, Got {%s}", tt.expectedLength, tt.expectedAlphabet, code)
+				}
+				if isBlockedRoomCode(code) {
+					t.Fatalf("Got blocked room code {%s}", code)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateRoom_Collisions(t *testing.T) {
+	saveDummyGameDef()
+
+	defaultGenerator := generateRoomCode
+	defer func() { generateRoomCode = defaultGenerator }()
+
+	//Hands out the given codes in order, then repeats the last one forever
+	generateFrom := func(codes ...string) RoomCodeGenerator {
+		return func() (string, error) {
+			code := codes[0]
+			if len(codes) > 1 {
+				codes = codes[1:]
+			}
+			return code, nil
+		}
+	}
+
+	taken := Session.Lobby{RoomCode: "TAKEN", GameName: "Already here"}
+	SaveLobbyInRedis(taken)
+	defer RDB.Del(RDB.Context(), "lobby:TAKEN", "lobby:FRESH")
+
+	t.Run("Taken Code Skipped", func(t *testing.T) {
+		generateRoomCode = generateFrom("TAKEN", "TAKEN", "FRESH")
+		RDB.Set(RDB.Context(), "roomSeq:FRESH", 42, 0) //Left behind by an expired lobby
+
+		roomCode, err := CreateRoom(DUMMY_ID, false)
+		if err != nil || roomCode != "FRESH" {
+			t.Fatalf("Expected to be given room code {FRESH}, Got {%s} (%v)", roomCode, err)
+		}
+
+		existing, _ := LoadLobbyFromRedis("TAKEN")
+		if existing.GameName != "Already here" {
+			t.Errorf("Existing lobby was overwritten. Got %+v", existing)
+		}
+		if RDB.Exists(RDB.Context(), "roomSeq:FRESH").Val() != 0 {
+			t.Errorf("Expected anything left behind under the new room code to be cleared")
+		}
+	})
+
+	t.Run("Every Code Taken", func(t *testing.T) {
+		generateRoomCode = generateFrom("TAKEN")
+
+		if roomCode, err := CreateRoom(DUMMY_ID, false); err == nil {
+			t.Errorf("Expected CreateRoom to give up, Got room code {%s}", roomCode)
+		}
+	})
+
+	t.Run("Concurrent Creates", func(t *testing.T) {
+		generateRoomCode = NewRoomCodeGenerator(RoomCodeConfig{Length: 1, Alphabet: "XYZ"})
+
+		codes := make(chan string, 3)
+		for range 3 {
+			go func() {
+				roomCode, _ := CreateRoom(DUMMY_ID, false)
+				codes <- roomCode
+			}()
+		}
+
+		seen := map[string]bool{}
+		for range 3 {
+			roomCode := <-codes
+			defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+			if roomCode == "" || seen[roomCode] {
+				t.Errorf("Expected 3 different room codes, Got {%s} after %v", roomCode, seen)
+			}
+			seen[roomCode] = true
+		}
+	})
+}
+
+func TestBanPlayer(t *testing.T) {
+	saveDummyLobby()
+	defer RDB.Del(RDB.Context(), "lobby:"+DUMMY_ID)
+
+	lobby, hostId, _ := JoinRoom(DUMMY_ID, "host", "")
+	lobby, troublemakerId, _ := JoinRoom(DUMMY_ID, "troublemaker", "")
+	if lobby.Host.Id != hostId {
+		t.Fatalf("Expected {%s} to be host", hostId)
+	}
+
+	if err := BanPlayer(DUMMY_ID, troublemakerId, hostId); Session.AsGameError(err).Code != Session.ErrorCode_NotHost {
+		t.Errorf("Expected only the host to be able to ban, Got %v", err)
+	}
+	if err := BanPlayer(DUMMY_ID, hostId, hostId); Session.AsGameError(err) == nil || Session.AsGameError(err).Code != Session.ErrorCode_CantKickHost {
+		t.Errorf("Expected the host to be unable to ban themselves, Got %v", err)
+	}
+	if err := BanPlayer(DUMMY_ID, hostId, troublemakerId); err != nil {
+		t.Fatalf("Expected ban to succeed, Got %s", err)
+	}
+	LeaveRoom(DUMMY_ID, troublemakerId)
+
+	for _, name := range []string{"troublemaker", "TroubleMaker"} {
+		if _, _, err := JoinRoom(DUMMY_ID, name, ""); Session.AsGameError(err).Code != Session.ErrorCode_Banned {
+			t.Errorf("Expected {%s} to be refused, Got %v", name, err)
+		}
+	}
+	if _, _, err := JoinRoom(DUMMY_ID, "innocent", troublemakerId); Session.AsGameError(err).Code != Session.ErrorCode_Banned {
+		t.Errorf("Expected a banned player to be refused under a new name, Got %v", err)
+	}
+	if _, _, err := JoinRoom(DUMMY_ID, "someoneElse", ""); err != nil {
+		t.Errorf("Expected anyone else to still be able to join, Got %s", err)
+	}
+}
+
+func TestVoteKick(t *testing.T) {
+	roomCode := DUMMY_ID + "votekick"
+	players := []Player.Player{{Id: "a", Name: "a"}, {Id: "b", Name: "b"}, {Id: "c", Name: "c"}, {Id: "d", Name: "d"}}
+	SaveLobbyInRedis(Session.Lobby{RoomCode: roomCode, Status: Session.LobbyStatus_InProgress, Players: players, NumPlayers: 4, MaxPlayers: 4, Host: players[0]})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	if _, _, err := VoteKick(roomCode, "a", "a", true); err == nil {
+		t.Errorf("Expected players to be unable to vote against themselves")
+	}
+
+	//With 3 other players, 2 votes is a majority
+	lobby, kicked, err := VoteKick(roomCode, "a", "d", true)
+	if err != nil || kicked || !slices.Equal(lobby.KickVotes["d"], []string{"a"}) {
+		t.Fatalf("Expected a single recorded vote. Got votes %v, kicked=%t (%v)", lobby.KickVotes, kicked, err)
+	}
+	lobby, _, _ = VoteKick(roomCode, "a", "d", false)
+	if len(lobby.KickVotes["d"]) != 0 {
+		t.Errorf("Expected the vote to be taken back. Got %v", lobby.KickVotes)
+	}
+	VoteKick(roomCode, "a", "d", true)
+	VoteKick(roomCode, "a", "d", true) //Voting twice doesn't count twice
+	if _, kicked, _ := VoteKick(roomCode, "a", "d", true); kicked {
+		t.Fatalf("Expected one player's repeated votes to count once")
+	}
+
+	lobby, kicked, err = VoteKick(roomCode, "b", "d", true)
+	if err != nil || !kicked || !lobby.IsBanned("d", "") || len(lobby.KickVotes["d"]) != 0 {
+		t.Errorf("Expected d to be kicked and banned. Got kicked=%t, banned %+v, votes %v (%v)", kicked, lobby.Banned, lobby.KickVotes, err)
+	}
+
+	if _, _, err := VoteKick(roomCode, "b", "a", true); Session.AsGameError(err) == nil || Session.AsGameError(err).Code != Session.ErrorCode_CantKickHost {
+		t.Errorf("Expected the host to be safe from votes, Got %v", err)
+	}
+
+	//Votes cast by someone who leaves are dropped
+	VoteKick(roomCode, "c", "b", true)
+	lobby, _, _ = LeaveRoom(roomCode, "c")
+	if len(lobby.KickVotes) != 0 {
+		t.Errorf("Expected votes from a player who left to be dropped. Got %v", lobby.KickVotes)
+	}
+}
+
+func TestVoteKick_TwoPlayers(t *testing.T) {
+	roomCode := DUMMY_ID + "votekick2"
+	players := []Player.Player{{Id: "host", Name: "host"}, {Id: "guest", Name: "guest"}, {Id: "bot", Name: "bot", Bot: BotStrategy_Random}}
+	SaveLobbyInRedis(Session.Lobby{RoomCode: roomCode, Status: Session.LobbyStatus_AwaitingStart, Players: players, NumPlayers: 3, MaxPlayers: 4, Host: players[0]})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	//The host is a majority of everyone but the guest (the bot doesn't count), but one vote is never enough
+	lobby, kicked, err := VoteKick(roomCode, "host", "guest", true)
+	if err != nil || kicked || len(lobby.Banned) != 0 {
+		t.Errorf("Expected one vote not to be enough to kick someone. Got kicked=%t, banned %+v (%v)", kicked, lobby.Banned, err)
+	}
+	if _, kicked, err := VoteKick(roomCode, "guest", "host", true); Session.AsGameError(err) == nil || kicked {
+		t.Errorf("Expected the guest to be unable to vote out the host. Got kicked=%t (%v)", kicked, err)
+	}
+}
+
+func TestLeaveRoom_InProgress(t *testing.T) {
+	roomCode := DUMMY_ID + "leaving"
+	players := []Player.Player{
+		{Id: "stayer", Name: "stayer", Hand: []Game.View{{Id: "stayerHand"}}},
+		{Id: "leaver", Name: "leaver", Hand: []Game.View{{Id: "leaverHand", Pieces: Pieces.PieceSet{Orphans: []Pieces.Card{{GamePiece: Pieces.GamePiece{Id: "card"}}}}}}},
+	}
+	gameState, _ := CacheGameStateInRedis(Session.GameState{
+		Players:       players,
+		CurrentPlayer: "leaver",
+		Rules:         Game.GameRules{LeavePolicy: Game.LeavePolicy_Distribute},
+	})
+	defer RDB.Del(RDB.Context(), "gameState:"+gameState.Id)
+	SaveLobbyInRedis(Session.Lobby{RoomCode: roomCode, GameStateId: gameState.Id, Status: Session.LobbyStatus_InProgress, Players: players, NumPlayers: 2, MaxPlayers: 4, Host: players[0]})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	lobby, changelog, err := LeaveRoom(roomCode, "leaver")
+	if err != nil || lobby.NumPlayers != 1 {
+		t.Fatalf("Expected the player to leave the lobby. Got %d players (%v)", lobby.NumPlayers, err)
+	}
+	if changelog == nil || changelog.Seats["leaver"] != Player.SeatStatus_Removed || changelog.CurrentPlayer != "stayer" {
+		t.Fatalf("Expected a Changelog removing the seat and passing the turn. Got %+v", changelog)
+	}
+
+	cached, err := GetCachedGameStateFromRedis(gameState.Id)
+	if err != nil {
+		t.Fatalf("Couldn't load GameState: %s", err)
+	}
+	if len(cached.Players) != 1 || len(cached.Players[0].Hand[0].Pieces.Orphans) != 1 {
+		t.Errorf("Expected the leaver's card to be dealt to the remaining player. Got %+v", cached.Players)
+	}
+
+	//Leaving before the game starts doesn't touch any GameState
+	SaveLobbyInRedis(Session.Lobby{RoomCode: roomCode, Status: Session.LobbyStatus_AwaitingStart, Players: players, NumPlayers: 2, MaxPlayers: 4, Host: players[0]})
+	if _, changelog, err := LeaveRoom(roomCode, "leaver"); err != nil || changelog != nil {
+		t.Errorf("Expected no Changelog leaving a game that hasn't started. Got %+v (%v)", changelog, err)
+	}
+}
+
+func TestSeatTakeover(t *testing.T) {
+	roomCode := DUMMY_ID + "takeover"
+	host := Player.Player{Id: "host", Name: "host"}
+	gameState, _ := CacheGameStateInRedis(Session.GameState{
+		Players: []Player.Player{
+			host,
+			{Id: "leaver", Name: "leaver", Hand: []Game.View{{Id: "leaverHand"}}, SeatStatus: Player.SeatStatus_Open},
+		},
+		CurrentPlayer: "leaver",
+	})
+	defer RDB.Del(RDB.Context(), "gameState:"+gameState.Id)
+	SaveLobbyInRedis(Session.Lobby{RoomCode: roomCode, GameStateId: gameState.Id, Status: Session.LobbyStatus_InProgress, Players: []Player.Player{host}, NumPlayers: 1, MaxPlayers: 4, Host: host})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	if _, _, err := RequestSeat(roomCode, "host", "", ""); Session.AsGameError(err).Code != Session.ErrorCode_NameTaken {
+		t.Errorf("Expected a player's name to be taken, Got %v", err)
+	}
+	if _, _, err := RequestSeat(roomCode, "newcomer", "host", ""); Session.AsGameError(err).Code != Session.ErrorCode_NoOpenSeat {
+		t.Errorf("Expected a seat that's still played to be refused, Got %v", err)
+	}
+
+	lobby, firstId, err := RequestSeat(roomCode, "first", "", "")
+	if err != nil || len(lobby.SeatRequests) != 1 || lobby.SeatRequests[0].SeatId != "leaver" {
+		t.Fatalf("Expected a request for the open seat. Got %+v (%v)", lobby.SeatRequests, err)
+	}
+	for _, name := range []string{"HOST", "First"} {
+		if _, _, err := RequestSeat(roomCode, name, "", ""); Session.AsGameError(err).Code != Session.ErrorCode_NameTaken {
+			t.Errorf("Expected names to be taken regardless of case, Got %v for {%s}", err, name)
+		}
+	}
+	_, secondId, _ := RequestSeat(roomCode, "second", "leaver", "")
+	_, declinedId, _ := RequestSeat(roomCode, "declined", "", "")
+
+	if _, _, _, err := AnswerSeatRequest(roomCode, firstId, firstId, true); Session.AsGameError(err).Code != Session.ErrorCode_NotHost {
+		t.Errorf("Expected only the host to be able to answer, Got %v", err)
+	}
+
+	lobby, seated, declined, err := AnswerSeatRequest(roomCode, "host", declinedId, false)
+	if err != nil || seated != nil || !slices.Equal(declined, []string{declinedId}) || len(lobby.SeatRequests) != 2 {
+		t.Errorf("Expected the request to be declined. Got declined %v, requests %+v (%v)", declined, lobby.SeatRequests, err)
+	}
+
+	lobby, seated, declined, err = AnswerSeatRequest(roomCode, "host", firstId, true)
+	if err != nil {
+		t.Fatalf("Expected the request to be approved, Got %s", err)
+	}
+	if lobby.NumPlayers != 2 || !playerExistsInLobby(lobby, "first", firstId) || len(lobby.SeatRequests) != 0 {
+		t.Errorf("Expected the new player to join the lobby. Got players %+v, requests %+v", lobby.Players, lobby.SeatRequests)
+	}
+	if !slices.Equal(declined, []string{secondId}) {
+		t.Errorf("Expected everyone else waiting for the seat to be declined. Got %v", declined)
+	}
+	if seated == nil || seated.Players[1].Id != firstId || seated.Players[1].Hand[0].Id != "leaverHand" || seated.CurrentPlayer != firstId {
+		t.Errorf("Expected the new player to inherit the seat. Got %+v", seated)
+	}
+
+	cached, _ := GetCachedGameStateFromRedis(gameState.Id)
+	if cached.Players[1].Id != firstId {
+		t.Errorf("Expected the takeover to be cached. Got %+v", cached.Players)
+	}
+	if _, _, err := RequestSeat(roomCode, "late", "", ""); Session.AsGameError(err).Code != Session.ErrorCode_GameAlreadyStarted {
+		t.Errorf("Expected no open seats to be left, Got %v", err)
+	}
+}
+
+func TestAddBot(t *testing.T) {
+	roomCode := DUMMY_ID + "bots"
+	host := Player.Player{Id: "host", Name: "host"}
+	SaveLobbyInRedis(Session.Lobby{RoomCode: roomCode, Status: Session.LobbyStatus_AwaitingStart, Players: []Player.Player{host}, NumPlayers: 1, MaxPlayers: 3, Host: host})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	if _, err := AddBot(roomCode, "notHost", "", ""); Session.AsGameError(err).Code != Session.ErrorCode_NotHost {
+		t.Errorf("Expected only the host to be able to add bots, Got %v", err)
+	}
+	if _, err := AddBot(roomCode, "host", "Clairvoyant", ""); Session.AsGameError(err).Code != Session.ErrorCode_UnknownBotStrategy {
+		t.Errorf("Expected an unknown strategy to be refused, Got %v", err)
+	}
+
+	lobby, err := AddBot(roomCode, "host", "", "")
+	if err != nil || lobby.NumPlayers != 2 {
+		t.Fatalf("Expected the bot to join. Got %d players (%v)", lobby.NumPlayers, err)
+	}
+	bot := lobby.Players[1]
+	if bot.Bot != BotStrategy_Random || bot.Name == "" || !lobby.Ready[bot.Id] {
+		t.Errorf("Expected a ready Random bot with a name. Got %+v (ready: %t)", bot, lobby.Ready[bot.Id])
+	}
+
+	if _, err := AddBot(roomCode, "host", BotStrategy_Greedy, bot.Name); Session.AsGameError(err).Code != Session.ErrorCode_NameTaken {
+		t.Errorf("Expected the bot's name to be taken, Got %v", err)
+	}
+	lobby, _ = AddBot(roomCode, "host", BotStrategy_Greedy, "")
+	if _, err := AddBot(roomCode, "host", "", ""); Session.AsGameError(err).Code != Session.ErrorCode_LobbyFull {
+		t.Errorf("Expected a full lobby to refuse bots, Got %v", err)
+	}
+
+	//Bots can't be handed the lobby
+	if _, err := TransferHost(roomCode, "host", bot.Id); err == nil {
+		t.Errorf("Expected a bot not to be made host")
+	}
+	if newHost := nextHost(lobby, "host"); newHost.Id != "" {
+		t.Errorf("Expected nobody to take over from the host, Got %+v", newHost)
+	}
+}
+
+func TestRunBotTurns(t *testing.T) {
+	scoredCard := func(id string, score string) Pieces.Card {
+		return Pieces.Card{GamePiece: Pieces.GamePiece{Id: id, Tags: map[string]string{"score": score}}}
+	}
+	gameState, _ := CacheGameStateInRedis(Session.GameState{
+		Players: []Player.Player{
+			{Id: "greedy", Name: "greedy", Hand: []Game.View{{Id: "greedyHand"}}, SeatStatus: Player.SeatStatus_Bot, Bot: BotStrategy_Greedy},
+			{Id: "random", Name: "random", Hand: []Game.View{{Id: "randomHand"}}, SeatStatus: Player.SeatStatus_Bot},
+			{Id: "person", Name: "person", Hand: []Game.View{{Id: "personHand"}}},
+		},
+		CurrentPlayer: "greedy",
+		Rules:         Game.GameRules{EnforceTurnOrder: true},
+		Views: []Game.View{{Id: "table", Pieces: Pieces.PieceSet{CardPlaces: []Pieces.CardPlace{
+			{GamePiece: Pieces.GamePiece{Id: "good"}, Cards: []Pieces.Card{scoredCard("five", "5")}},
+			{GamePiece: Pieces.GamePiece{Id: "bad"}, Cards: []Pieces.Card{scoredCard("minusTwo", "-2")}},
+		}}}},
+	})
+	defer RDB.Del(RDB.Context(), "gameState:"+gameState.Id)
+
+	changelogs, err := RunBotTurns(gameState.Id)
+	if err != nil {
+		t.Fatalf("Expected the bots to play, Got %s", err)
+	}
+	if len(changelogs) < 3 || changelogs[len(changelogs)-1].CurrentPlayer != "person" {
+		t.Fatalf("Expected both bots to play until it's the person's turn. Got %+v", changelogs)
+	}
+
+	cached, _ := GetCachedGameStateFromRedis(gameState.Id)
+	greedyHand := cached.Players[0].Hand[0].Pieces.Orphans
+	if len(greedyHand) != 1 || greedyHand[0].Id != "five" {
+		t.Errorf("Expected the greedy bot to draw only the card worth the most. Got %+v", greedyHand)
+	}
+
+	//Nothing happens on a person's turn
+	if changelogs, err := RunBotTurns(gameState.Id); err != nil || len(changelogs) != 0 {
+		t.Errorf("Expected the bots to wait for the person. Got %+v (%v)", changelogs, err)
+	}
+}
+
+func TestLegalActions(t *testing.T) {
+	card := func(id string, suit string) Pieces.Card {
+		return Pieces.Card{GamePiece: Pieces.GamePiece{Id: id, Tags: map[string]string{"suit": suit}}}
+	}
+	gameState := Session.GameState{
+		Players: []Player.Player{
+			{Id: "me", Hand: []Game.View{{Id: "myHand", Pieces: Pieces.PieceSet{Orphans: []Pieces.Card{card("heart", "hearts"), card("spade", "spades")}}}}},
+			{Id: "you", Hand: []Game.View{{Id: "yourHand", Pieces: Pieces.PieceSet{Orphans: []Pieces.Card{card("secret", "hearts")}}}}},
+			{Id: "gone", SeatStatus: Player.SeatStatus_Frozen},
+		},
+		CurrentPlayer: "me",
+		Rules:         Game.GameRules{EnforceTurnOrder: true},
+		Views: []Game.View{{Id: "table", Pieces: Pieces.PieceSet{
+			Decks:      []Pieces.Deck{{GamePiece: Pieces.GamePiece{Id: "deck"}, Cards: []Pieces.Card{card("drawn", "clubs")}}},
+			CardPlaces: []Pieces.CardPlace{{GamePiece: Pieces.GamePiece{Id: "hearts"}, PieceContainer: Pieces.PieceContainer{TagsWhitelist: map[string][]string{"suit": {"hearts"}}}}},
+		}}},
+	}
+
+	actions := LegalActions(gameState, "me")
+	counts := map[string]int{}
+	for _, action := range actions {
+		counts[action.Type]++
+
+		//Everything listed has to be accepted by the engine
+		trial, _ := cloneGameState(gameState)
+		if _, err := applyAction(&trial, action); err != nil {
+			t.Errorf("Expected listed %s %s to be accepted, Got %s", action.Type, action.Turn, err)
+		}
+		if strings.Contains(string(action.Turn), "secret") || strings.Contains(string(action.Turn), "yourHand") {
+			t.Errorf("Expected nothing in another player's hand to be listed, Got %s", action.Turn)
+		}
+	}
+
+	//Both cards fit in the deck, but only the heart fits in the whitelisted CardPlace
+	expected := map[string]int{
+		Session.ActionType_Insertion:  3,
+		Session.ActionType_Withdrawal: 2,
+		Session.ActionType_Movement:   2,
+		Session.ActionType_CardFlip:   2,
+		Session.ActionType_Reshuffle:  0,
+		Session.ActionType_EndTurn:    1,
+	}
+	for actionType, count := range expected {
+		if counts[actionType] != count {
+			t.Errorf("Expected %d %s actions, Got %d", count, actionType, counts[actionType])
+		}
+	}
+
+	if actions := LegalActions(gameState, "you"); len(actions) != 0 {
+		t.Errorf("Expected nothing to be allowed out of turn, Got %d actions", len(actions))
+	}
+	if actions := LegalActions(gameState, "gone"); len(actions) != 0 {
+		t.Errorf("Expected nothing to be allowed for a player who left, Got %d actions", len(actions))
+	}
+	gameState.Rules.EnforceTurnOrder = false
+	if actions := LegalActions(gameState, "you"); len(actions) == 0 {
+		t.Errorf("Expected anyone to be able to act without turn order")
+	}
+}
+
+func TestTimeOutTurn(t *testing.T) {
+	roomCode := DUMMY_ID + "timer"
+	deadline := time.Now().Add(-time.Second).UTC().Truncate(time.Millisecond)
+	gameState, _ := CacheGameStateInRedis(Session.GameState{
+		Players: []Player.Player{
+			{Id: "slow", Name: "slow", Hand: []Game.View{{Id: "slowHand"}}},
+			{Id: "next", Name: "next", Hand: []Game.View{{Id: "nextHand"}}},
+		},
+		CurrentPlayer: "slow",
+		TurnDeadline:  &deadline,
+		Rules:         Game.GameRules{EnforceTurnOrder: true, TurnTimeLimit: 30},
+	})
+	defer RDB.Del(RDB.Context(), "gameState:"+gameState.Id, turnTimerClaimKey("Warning", gameState.Id, deadline), turnTimerClaimKey("Timeout", gameState.Id, deadline))
+	SaveLobbyInRedis(Session.Lobby{RoomCode: roomCode, Status: Session.LobbyStatus_InProgress, GameStateId: gameState.Id})
+	defer RDB.Del(RDB.Context(), "lobby:"+roomCode)
+
+	//Only the first claim on a turn's warning gets it
+	if warned, err := ClaimTurnWarning(roomCode, deadline); err != nil || !slices.Equal(warned, []string{"slow"}) {
+		t.Errorf("Expected the current player to be warned, Got %v (%v)", warned, err)
+	}
+	if warned, _ := ClaimTurnWarning(roomCode, deadline); len(warned) != 0 {
+		t.Errorf("Expected a turn to only be warned about once, Got %v", warned)
+	}
+	if changelogs, _ := TimeOutTurn(roomCode, deadline.Add(time.Second)); len(changelogs) != 0 {
+		t.Errorf("Expected a different deadline to leave the turn alone, Got %+v", changelogs)
+	}
+
+	changelogs, err := TimeOutTurn(roomCode, deadline)
+	if err != nil || len(changelogs) != 1 {
+		t.Fatalf("Expected the slow player's turn to be ended, Got %+v (%v)", changelogs, err)
+	}
+	if changelogs[0].CurrentPlayer != "next" || !strings.Contains(changelogs[0].MostRecentAction, "ran out of time") {
+		t.Errorf("Expected the turn to pass on, saying why. Got %+v", changelogs[0])
+	}
+	if changelogs[0].TurnDeadline == nil || !changelogs[0].TurnDeadline.After(time.Now().Add(20*time.Second)) {
+		t.Errorf("Expected the next player to get a fresh deadline, Got %v", changelogs[0].TurnDeadline)
+	}
+	if changelogs, _ := TimeOutTurn(roomCode, deadline); len(changelogs) != 0 {
+		t.Errorf("Expected a turn to only be timed out once, Got %+v", changelogs)
+	}
+
+	//A bot strategy plays out the rest of the turn instead
+	cached, _ := GetCachedGameStateFromRedis(gameState.Id)
+	cached.Rules.TurnTimeoutAction = BotStrategy_Random
+	cached.TurnDeadline = &deadline
+	CacheGameStateInRedis(cached)
+	RDB.Del(RDB.Context(), turnTimerClaimKey("Timeout", gameState.Id, deadline))
+
+	changelogs, err = TimeOutTurn(roomCode, deadline)
+	if err != nil || len(changelogs) == 0 || changelogs[len(changelogs)-1].CurrentPlayer != "slow" {
+		t.Errorf("Expected a bot to finish the next player's turn, Got %+v (%v)", changelogs, err)
+	}
+
+	if lead := TurnWarningLead(Game.GameRules{TurnTimeLimit: 8}); lead != 4*time.Second {
+		t.Errorf("Expected the warning to come no earlier than halfway through the turn, Got %s", lead)
+	}
+}
+
+func TestCommitAction(t *testing.T) {
+	gameState, _ := CacheGameStateInRedis(Session.GameState{
+		Players: []Player.Player{
+			{Id: "first", Name: "first", Hand: []Game.View{{Id: "firstHand"}}},
+			{Id: "second", Name: "second", Hand: []Game.View{{Id: "secondHand"}}},
+			{Id: "bot", Name: "bot", Hand: []Game.View{{Id: "botHand"}}, SeatStatus: Player.SeatStatus_Bot, Bot: BotStrategy_Greedy},
+		},
+		CurrentPlayer: "first",
+		Rules:         Game.GameRules{EnforceTurnOrder: true, SimultaneousRounds: true},
+		Views: []Game.View{{Id: "table", Pieces: Pieces.PieceSet{CardPlaces: []Pieces.CardPlace{
+			{GamePiece: Pieces.GamePiece{Id: "pile"}, Cards: []Pieces.Card{{GamePiece: Pieces.GamePiece{Id: "prize", Tags: map[string]string{"score": "5"}}}}},
+		}}}},
+	})
+	defer RDB.Del(RDB.Context(), "gameState:"+gameState.Id, roundCommitmentsKey(gameState.Id))
+
+	draw := func(playerId string) Session.SubmittedAction {
+		return newAction(playerId, Session.ActionType_Withdrawal, Session.Withdrawal{FromCollection: "pile", InView: "table", ToView: playerId + "Hand"})
+	}
+
+	if _, err := SubmitAction(gameState.Id, draw("first")); Session.AsGameError(err).Code != Session.ErrorCode_MustCommit {
+		t.Errorf("Expected submitting to a game played in rounds to be rejected, Got %v", err)
+	}
+
+	//The bot commits on its own, without revealing anything
+	if changelogs, err := RunBotTurns(gameState.Id); err != nil || len(changelogs) != 0 {
+		t.Fatalf("Expected the bot to commit without the round being revealed, Got %+v (%v)", changelogs, err)
+	}
+
+	progress, revealed, err := CommitAction(gameState.Id, draw("first"))
+	if err != nil || revealed != nil {
+		t.Fatalf("Expected the round to wait for everyone, Got %+v (%v)", revealed, err)
+	}
+	if !slices.Equal(progress.Committed, []string{"first", "bot"}) || !slices.Equal(progress.Waiting, []string{"second"}) {
+		t.Errorf("Expected only the second player to be waited on, Got %+v", progress)
+	}
+	if cached, _ := GetCachedGameStateFromRedis(gameState.Id); len(cached.Views[0].Pieces.CardPlaces[0].Cards) != 1 {
+		t.Errorf("Expected nothing to change until the round is revealed")
+	}
+
+	progress, revealed, err = CommitAction(gameState.Id, draw("second"))
+	if err != nil || revealed == nil {
+		t.Fatalf("Expected the last commitment to reveal the round, Got %+v (%v)", progress, err)
+	}
+	if !strings.Contains(revealed.MostRecentAction, "'second' couldn't carry out") || revealed.CurrentPlayer != "second" {
+		t.Errorf("Expected the first seat to draw the card, the rest to miss out, and the lead to move on. Got %+v", revealed)
+	}
+	if len(progress.Committed) != 0 || len(progress.Waiting) != 3 {
+		t.Errorf("Expected a fresh round to start, Got %+v", progress)
+	}
+
+	cached, _ := GetCachedGameStateFromRedis(gameState.Id)
+	if hand := cached.Players[0].Hand[0].Pieces.Orphans; len(hand) != 1 || hand[0].Id != "prize" {
+		t.Errorf("Expected the first player to have drawn the prize, Got %+v", hand)
+	}
+}
+
+// ==================HELPER FUNCTIONS=============================
+func playerExistsInLobby(lobby Session.Lobby, playerName string, playerId string) bool {
+	for _, player := range lobby.Players {
+		if playerId != "" {
+			if player.Id == playerId && player.Name == playerName {
+				return true
+			}
+		} else {
+			if player.Name == playerName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func playerIsHost(lobby Session.Lobby, playerName string, playerId string) bool {
+	return lobby.Host.Name == playerName && lobby.Host.Id == playerId
+}
+
+func saveDummyGameDef() {
+	SaveGameDefToDB(Game.Game{
+		Id:         DUMMY_ID,
+		Name:       "dummy",
+		MaxPlayers: 4,
+		Genre:      "test",
+	})
+}
+
+func saveDummyLobby() {
+	//Make sure the dummy game exists since we use it as the game def id
+	saveDummyGameDef()
+	SaveLobbyInRedis(Session.Lobby{
+		RoomCode:         DUMMY_ID,
+		GameDefinitionId: DUMMY_ID,
+		Status:           Session.LobbyStatus_AwaitingStart,
+		NumPlayers:       0,
+		MaxPlayers:       4,
+		Players:          []Player.Player{},
+		Host:             Player.Player{},
+	})
+}
+
+// Builds a GameState with [numPlayers] players (each with one empty hand View) and one public View holding a Deck of [numCards] cards and an empty CardPlace
+func dummyGameState(numPlayers int, numCards int) Session.GameState {
+	cards := []Pieces.Card{}
+	for x := range numCards {
+		cards = append(cards, Pieces.Card{GamePiece: Pieces.GamePiece{Id: fmt.Sprintf("card%d", x), ParentView: "table"}})
+	}
+
+	gameState := Session.GameState{
+		Views: []Game.View{
+			{
+				Id: "table",
+				Pieces: Pieces.PieceSet{
+					Decks:      []Pieces.Deck{{GamePiece: Pieces.GamePiece{Id: "deck", ParentView: "table"}, Cards: cards}},
+					CardPlaces: []Pieces.CardPlace{{GamePiece: Pieces.GamePiece{Id: "place", ParentView: "table"}}},
+				},
+			},
+		},
+		Players: []Player.Player{},
+	}
+
+	for x := range numPlayers {
+		gameState.Players = append(gameState.Players, Player.Player{
+			Id:   fmt.Sprintf("player%d", x+1),
+			Name: fmt.Sprintf("Player %d", x+1),
+			Hand: []Game.View{{Id: fmt.Sprintf("hand%d", x+1), OwnerPlayerNumber: x + 1}},
+		})
+	}
+	gameState.CurrentPlayer = gameState.Players[0].Id
+
+	return gameState
 }